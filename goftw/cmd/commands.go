@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"goftw/internal/entity"
+	"goftw/internal/environ"
+	"goftw/internal/middleware"
+)
+
+// runCommand dispatches a goftw subcommand and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "gc":
+		return runGC(args[1:])
+	case "hash-key":
+		return runHashKey(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\nusage: goftw [gc|hash-key]\n", args[0])
+		return 2
+	}
+}
+
+// runGC prints the unused apps plan and removes them after confirmation
+func runGC(args []string) int {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the plan without removing anything")
+	yes := flags.Bool("yes", false, "remove without asking for confirmation")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	instanceCfx, err := entity.LoadInstance(environ.GetInstanceFile())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] failed to load instance.json: %v\n", err)
+		return 1
+	}
+	bench := newBench(instanceCfx)
+
+	plan, err := bench.PlanGC(instanceCfx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] gc plan failed: %v\n", err)
+		return 1
+	}
+
+	if len(plan.Apps) == 0 {
+		fmt.Println("[GC] No unused apps")
+		return 0
+	}
+	fmt.Println("[GC] Unused apps:")
+	for _, app := range plan.Apps {
+		fmt.Printf("  %-24s %s\n", app.Name, app.Size)
+	}
+	fmt.Printf("[GC] Would reclaim %s\n", plan.Reclaim)
+
+	if *dryRun {
+		return 0
+	}
+	if !*yes {
+		fmt.Printf("Remove %d apps? [y/N] ", len(plan.Apps))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("[GC] Aborted")
+			return 1
+		}
+	}
+
+	confirmed := make([]string, 0, len(plan.Apps))
+	for _, app := range plan.Apps {
+		confirmed = append(confirmed, app.Name)
+	}
+	if _, _, err := bench.RunGC(instanceCfx, confirmed); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runHashKey prints the stored hash of an API key read from the arguments or stdin
+func runHashKey(args []string) int {
+	secret := strings.Join(args, " ")
+	if secret == "" {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		secret = strings.TrimSpace(line)
+	}
+	if secret == "" {
+		fmt.Fprintln(os.Stderr, "usage: goftw hash-key <secret>")
+		return 2
+	}
+	fmt.Println(middleware.HashKey(secret))
+	return 0
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	internalBench "goftw/internal/bench"
 	"goftw/internal/db"
 	"goftw/internal/entity"
+	"goftw/internal/health"
+	"goftw/internal/metrics"
 	internalMiddleware "goftw/internal/middleware"
 
 	"goftw/internal/environ"
@@ -20,7 +26,23 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+// newBench returns the bench described by instance.json
+func newBench(instanceCfx *entity.Instance) *internalBench.Bench {
+	return &internalBench.Bench{
+		Name:       "frappe-bench",
+		Path:       environ.GetBenchPath(),
+		Branch:     instanceCfx.FrappeBranch,
+		ServerName: instanceCfx.ServerName,
+		Instance:   instanceCfx,
+	}
+}
+
 func main() {
+	// Subcommands run against an existing bench and exit
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// COST OPTIMIZATION: SSH key-based authentication setup
 	// if err := ssh.Setup(); err != nil {
 	// 	log.Fatalf("SSH setup failed: %v", err)
@@ -49,6 +71,34 @@ func main() {
 	}
 	deployment := instanceCfx.Deployment
 
+	// Load API keys, refusing to serve an open API unless explicitly allowed
+	apiKeys, err := internalMiddleware.LoadKeyStore(environ.GetAPIKeysFile(), os.Getenv("GOFTW_API_KEYS"))
+	if err != nil {
+		log.Fatalf("failed to load api keys: %v", err)
+	}
+	insecureAPI := os.Getenv("GOFTW_API_INSECURE") == "1"
+	if apiKeys.Len() == 0 && !insecureAPI {
+		log.Fatalf("no api keys configured: set GOFTW_API_KEYS or %s, or GOFTW_API_INSECURE=1 to run without authentication", environ.GetAPIKeysFile())
+	}
+	policy, err := internalMiddleware.LoadPolicy(environ.GetAPIPolicyFile())
+	if err != nil {
+		log.Fatalf("failed to load api policy: %v", err)
+	}
+	corsCfg := internalMiddleware.CORSFromEnv(instanceCfx.CORS)
+	cors, err := internalMiddleware.NewCORS(corsCfg)
+	if err != nil {
+		log.Fatalf("invalid cors policy: %v", err)
+	}
+	trustedOrigins, err := internalMiddleware.TrustedOrigins(corsCfg)
+	if err != nil {
+		log.Fatalf("invalid cors policy: %v", err)
+	}
+	// The key cookie only reaches dashboards on other sites through CORS credentials
+	cookieSameSite := http.SameSiteStrictMode
+	if corsCfg.AllowCredentials {
+		cookieSameSite = http.SameSiteNoneMode
+	}
+
 	// Wait for DB
 	if err := db.WaitForDB(dbCfg); err != nil {
 		log.Fatalf("database check failed: %v", err)
@@ -64,12 +114,14 @@ func main() {
 		}
 	}
 	// Initialize Bench if not exists
-	bench := &internalBench.Bench{
-		Name:       "frappe-bench",
-		Path:       environ.GetBenchPath(),
-		Branch:     instanceCfx.FrappeBranch,
-		ServerName: instanceCfx.ServerName,
-	}
+	bench := newBench(instanceCfx)
+	bench.DBRootUser = dbCfg.User
+	bench.DBRootPassword = dbCfg.Password
+
+	// Stop accepting jobs as soon as SIGTERM/SIGINT arrives, even while booting
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	context.AfterFunc(ctx, bench.StopAcceptingJobs)
 
 	if _, err := os.Stat(bench.Path); os.IsNotExist(err) {
 		log.Printf("[BENCH] Bench directory %s does not exist, initializing...", bench.Path)
@@ -86,7 +138,7 @@ func main() {
 	}
 	// Checkout sites for anomalies and missing sites
 	if instanceCfx.RunSitesManager {
-		if err := bench.CheckoutSites(instanceCfx, dbCfg.User, dbCfg.Password); err != nil {
+		if err := bench.Reconcile(instanceCfx, dbCfg.User, dbCfg.Password); err != nil && !bench.ShuttingDown() {
 			log.Fatalf("sites sync failed: %v", err)
 		}
 	}
@@ -97,8 +149,10 @@ func main() {
 	// sites.MigrateAll(benchDir)
 
 	// Deployment
-	switch deployment {
-	case "production":
+	switch {
+	case bench.ShuttingDown():
+		fmt.Println("[SHUTDOWN] Signal received during boot, skipping deployment")
+	case deployment == "production":
 		if err := bench.RunSupervisorNginx(); err != nil {
 			fmt.Printf("[ERROR] Production mode failed: %v", err)
 		}
@@ -107,22 +161,143 @@ func main() {
 			fmt.Printf("[ERROR] Development mode failed: %v", err)
 		}
 	}
+	// Certificates for site names and custom domains, when tls is enabled,
+	// and their expiry checks
+	if deployment == "production" {
+		go bench.RunCertificateManager(ctx)
+		go bench.RunCertificateChecker(ctx)
+	}
+	// Path-based proxy on :2020, built in or the nginx-dynamic-proxy container
+	proxyServer, err := bench.BuiltinProxyServer()
+	if err != nil {
+		fmt.Printf("[ERROR] Built-in proxy failed: %v\n", err)
+	}
+	if err := bench.UpdateDynamicProxy(); err != nil {
+		fmt.Printf("[ERROR] Failed to update the dynamic proxy: %v\n", err)
+	}
+	if proxyServer != nil {
+		go func() {
+			fmt.Printf("[PROXY] Built-in proxy running on %s\n", proxyServer.Addr)
+			if err := proxyServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("[ERROR] Built-in proxy stopped: %v\n", err)
+			}
+		}()
+	}
+
+	// Readiness probes: MariaDB, every Redis and the WSGI process goftw manages
+	checks := []health.Check{{Name: "mariadb", Probe: func(ctx context.Context) error { return db.Ping(ctx, dbCfg) }}}
+	for _, rc := range []struct{ name, url string }{
+		{"redis_queue", commonCfg.RedisQueue},
+		{"redis_cache", commonCfg.RedisCache},
+		{"redis_socketio", commonCfg.RedisSocketIO},
+	} {
+		checks = append(checks, health.Check{Name: rc.name, Probe: func(ctx context.Context) error {
+			return redis.Ping(ctx, redis.Config{URL: rc.url})
+		}})
+	}
+	checks = append(checks, health.Check{Name: "deployment", Probe: func(context.Context) error {
+		name, running := bench.DeploymentProcess()
+		if !running {
+			return fmt.Errorf("deployment process %q is not running", name)
+		}
+		return nil
+	}})
+	checker := health.NewChecker(5*time.Second, checks...)
+	checker.Reconcile = func() interface{} { return bench.LastReconcile() }
 
-	// COST OPTIMIZATION: API restricted to sites-only for demo instance
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
-	r.Use(internalMiddleware.CORS)
+	r.Use(cors)
+
+	// Metrics refreshed on scrape
+	metrics.Default.OnCollect(bench.CollectMetrics)
+	metrics.Default.OnCollect(func() {
+		results, _ := checker.Run(context.Background())
+		for _, result := range results {
+			ready := 0.0
+			if result.OK {
+				ready = 1
+			}
+			metrics.DependencyReady.Set(ready, result.Name)
+		}
+	})
+
+	// Unauthenticated probes for Docker and load balancers
+	r.Get("/healthz", checker.HealthzHandler)
+	r.Get("/readyz", checker.ReadyzHandler)
+
+	// Prometheus scrapes authenticate like any reader unless metrics are made public
+	if os.Getenv("GOFTW_METRICS_PUBLIC") == "1" || insecureAPI {
+		r.Method("GET", "/metrics", metrics.Default.Handler())
+	} else {
+		r.With(internalMiddleware.Auth(apiKeys, trustedOrigins), policy.Require(internalMiddleware.RoleReader)).
+			Method("GET", "/metrics", metrics.Default.Handler())
+	}
+
+	// Browser dashboards trade a key for the HttpOnly goftw_api_key cookie
+	r.Post("/api/goftw/login", internalMiddleware.LoginHandler(apiKeys, trustedOrigins, cookieSameSite))
+	r.Post("/api/goftw/logout", internalMiddleware.LogoutHandler(cookieSameSite))
 
 	r.Route("/api/goftw", func(r chi.Router) {
-		// Sites management endpoints only (apps endpoint disabled)
-		r.Get("/sites", bench.ListSitesHandler)
-		r.Get("/site/{name}", bench.GetSitesHandler)
-		r.Put("/site/{name}", bench.PutSitesHandler)
+		if insecureAPI {
+			fmt.Println("[WARN] API authentication disabled (GOFTW_API_INSECURE=1)")
+		} else {
+			r.Use(internalMiddleware.Auth(apiKeys, trustedOrigins))
+		}
+
+		reader := policy.Require(internalMiddleware.RoleReader)
+		operator := policy.Require(internalMiddleware.RoleOperator)
+		admin := policy.Require(internalMiddleware.RoleAdmin)
+
+		r.With(reader).Get("/status", checker.StatusHandler)
+		r.With(reader).Get("/deployment", bench.DeploymentHandler)
+		r.With(reader).Get("/apps", bench.ListAppsHandler)
+		r.With(reader).Get("/catalog", bench.CatalogHandler)
+		r.With(reader).Get("/sites", bench.ListSitesHandler)
+		r.With(reader).Get("/site/{name}", bench.GetSitesHandler)
+		r.With(operator).Post("/site/{name}/apps", bench.InstallAppsHandler)
+		r.With(operator).Post("/site/{name}/migrate", bench.MigrateSiteHandler)
+		r.With(operator).Post("/site/{name}/domains", bench.AddDomainHandler)
+		r.With(operator).Delete("/site/{name}/domains", bench.RemoveDomainHandler)
+		// Sites are created under their normalized name, which scoped keys must be allowed
+		r.With(policy.RequireSite(internalMiddleware.RoleAdmin, internalBench.NormalizeSiteName)).Put("/site/{name}", bench.PutSitesHandler)
+		r.With(admin).Delete("/site/{name}", bench.DeleteSiteHandler)
+		r.With(admin).Post("/update", bench.UpdateHandler)
+		r.With(admin).Post("/deployment/restart", bench.RestartDeploymentHandler)
+		r.With(admin).Post("/deployment/reload", bench.ReloadDeploymentHandler)
+		r.With(reader).Get("/nginx", bench.NginxHandler)
+		r.With(reader).Get("/certificates", bench.CertificatesHandler)
+		r.With(admin).Post("/certificates/renew", bench.RenewCertificatesHandler)
+		r.With(reader).Get("/scaling", bench.ScalingHandler)
+		r.With(admin).Put("/scaling", bench.PutScalingHandler)
+		r.With(reader).Get("/programs", bench.ProgramsHandler)
+		r.With(operator).Get("/programs/{program}/log", bench.ProgramLogHandler)
+		r.With(operator).Post("/programs/{program}/{action}", bench.ProgramActionHandler)
+		r.With(admin).Get("/gc", bench.GCPlanHandler)
+		r.With(admin).Post("/gc", bench.GCHandler)
 	})
 
-	fmt.Printf("[SERVER] Server running on :3000")
-	err = http.ListenAndServe(":3000", r)
+	server := &http.Server{Addr: ":3000", Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Println("[SERVER] Server running on :3000")
+		serverErr <- server.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		fmt.Println("[SHUTDOWN] Signal received, shutting down...")
+	case err := <-serverErr:
+		fmt.Printf("[ERROR] Could not start server %v\n", err)
+		exitCode = 1
+	}
+	stop()
+
+	timeout, err := time.ParseDuration(environ.GetEnv("GOFTW_SHUTDOWN_TIMEOUT", "90s"))
 	if err != nil {
-		fmt.Printf("[ERROR] Could not start server %v", err)
+		fmt.Printf("[WARN] Invalid GOFTW_SHUTDOWN_TIMEOUT, using 90s: %v\n", err)
+		timeout = 90 * time.Second
 	}
+	os.Exit(max(exitCode, shutdown(bench, timeout, server, proxyServer)))
 }
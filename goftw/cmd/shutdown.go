@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	internalBench "goftw/internal/bench"
+)
+
+// shutdown drains running jobs, stops the deployment and then the servers,
+// all within timeout. It returns 0 if everything stopped cleanly, 1 otherwise.
+func shutdown(bench *internalBench.Bench, timeout time.Duration, servers ...*http.Server) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	exitCode := 0
+
+	// 1. Refuse new jobs and let running ones (migrations, installs) finish
+	fmt.Printf("[SHUTDOWN] Waiting up to %s for running jobs...\n", timeout)
+	if err := bench.WaitForJobs(ctx); err != nil {
+		fmt.Printf("[ERROR] %v\n", err)
+		exitCode = 1
+	}
+
+	// 2. Stop the deployment; kill it if it outlives the deadline. Keep a short
+	// grace period if the jobs used it all up.
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), max(time.Until(deadline(ctx)), 10*time.Second))
+	defer stopCancel()
+	if err := bench.StopDeployment(stopCtx); err != nil {
+		fmt.Printf("[ERROR] Failed to stop deployment: %v\n", err)
+		exitCode = 1
+	}
+
+	// 3. Finish in-flight API and proxy requests and close the listeners
+	serverCtx, serverCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer serverCancel()
+	for _, server := range servers {
+		if server == nil {
+			continue
+		}
+		if err := server.Shutdown(serverCtx); err != nil {
+			fmt.Printf("[ERROR] Server %s shutdown: %v\n", server.Addr, err)
+			exitCode = 1
+		}
+	}
+
+	fmt.Printf("[SHUTDOWN] Done (exit code %d)\n", exitCode)
+	return exitCode
+}
+
+// deadline returns ctx's deadline, or now if it has none
+func deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now()
+}
@@ -2,4 +2,8 @@ module goftw
 
 go 1.24.0
 
-require github.com/go-chi/chi/v5 v5.2.3
+require (
+	github.com/go-chi/chi/v5 v5.2.3
+	golang.org/x/crypto v0.36.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -6,7 +6,9 @@ import (
 	"strings"
 
 	// "goftw/internal/deploy"
+	"goftw/internal/entity"
 	"goftw/internal/environ"
+	"goftw/internal/utils"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -49,16 +51,71 @@ func (b *Bench) ListAppsHandler(w http.ResponseWriter, r *http.Request) {
 	benchDir := environ.GetBenchPath()
 	fmt.Printf("[API] Bench directory: %s\n", benchDir)
 
-	apps, err := b.ListApps()
+	apps, err := b.DescribeApps()
 	if err != nil {
 		writeError(w, 500, fmt.Sprintf("failed to list apps: %v", err))
 		return
 	}
 
-	fmt.Printf("[API] Found apps: %v\n", apps)
+	fmt.Printf("[API] Found %d apps\n", len(apps))
 	writeJSON(w, 200, apps)
 }
 
+// CatalogHandler lists the app catalog annotated with bench state
+func (b *Bench) CatalogHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] CatalogHandler called")
+
+	apps, err := b.Catalog()
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to load catalog: %v", err))
+		return
+	}
+
+	fmt.Printf("[API] Catalog has %d apps\n", len(apps))
+	writeJSON(w, 200, apps)
+}
+
+// GCPlanHandler returns the apps a garbage collection would remove
+func (b *Bench) GCPlanHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] GCPlanHandler called")
+
+	instance := b.instance()
+	plan, err := b.PlanGC(&instance)
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to plan gc: %v", err))
+		return
+	}
+	writeJSON(w, 200, plan)
+}
+
+// GCHandler removes unused apps, requiring {"confirm": true}.
+// An optional "apps" list restricts removal to those planned apps.
+func (b *Bench) GCHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] GCHandler called")
+
+	var body struct {
+		Confirm bool     `json:"confirm"`
+		Apps    []string `json:"apps"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, 400, "invalid JSON body")
+		return
+	}
+	if !body.Confirm {
+		writeError(w, 400, "gc requires confirm: true")
+		return
+	}
+
+	// RunGC plans again inside the job so only currently unused apps are removed
+	instance := b.instance()
+	plan, removed, err := b.RunGC(&instance, body.Apps)
+	if err != nil {
+		writeJSON(w, jobStatus(err), map[string]interface{}{"error": err.Error(), "removed": removed})
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"removed": removed, "reclaimed": plan.Reclaim})
+}
+
 // GetSitesHandler returns a single site and its apps
 func (b *Bench) GetSitesHandler(w http.ResponseWriter, r *http.Request) {
 	siteName := chi.URLParam(r, "name")
@@ -66,15 +123,7 @@ func (b *Bench) GetSitesHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Verify site exists
 	fmt.Println("[API] Verifying site existence...")
-	sites, _ := b.ListSites()
-	found := false
-	for _, s := range sites {
-		if s == siteName {
-			found = true
-			break
-		}
-	}
-	if !found {
+	if !b.siteExists(siteName) {
 		writeError(w, 404, "site not found")
 		return
 	}
@@ -89,15 +138,47 @@ func (b *Bench) GetSitesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Printf("[API] Apps for site %s: %v\n", siteName, apps)
 
+	domains, err := b.SiteDomains(siteName)
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to read site domains: %v", err))
+		return
+	}
+
 	resp := map[string]interface{}{
-		"site": siteName,
-		"apps": apps,
-		"url":  fmt.Sprintf("http://%s", siteName),
+		"site":    siteName,
+		"apps":    apps,
+		"domains": domainNames(domains),
+		"url":     siteURL(siteName, domains),
 	}
 	writeJSON(w, 200, resp)
 }
 
-func normalizeSiteName(siteName string) string {
+// siteURL is where a site is reached: its first custom domain, over https
+// when that domain has a certificate, or the site name itself
+func siteURL(siteName string, domains []entity.SiteDomain) string {
+	if len(domains) == 0 {
+		return fmt.Sprintf("http://%s", siteName)
+	}
+	if domains[0].SSLCertificate != "" {
+		return fmt.Sprintf("https://%s", domains[0].Domain)
+	}
+	return fmt.Sprintf("http://%s", domains[0].Domain)
+}
+
+// siteExists reports whether a site exists in the bench
+func (b *Bench) siteExists(siteName string) bool {
+	sites, _ := b.ListSites()
+	for _, s := range sites {
+		if s == siteName {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeSiteName returns the name a site requested as siteName is created
+// under: the last dot suffix is replaced by .localhost
+func NormalizeSiteName(siteName string) string {
 	if i := strings.LastIndex(siteName, "."); i != -1 {
 		siteName = siteName[:i]
 	}
@@ -112,7 +193,7 @@ func (b *Bench) PutSitesHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, 400, "no site name")
 		return
 	}
-	siteName = normalizeSiteName(siteName)
+	siteName = NormalizeSiteName(siteName)
 
 	// Parse body for apps list
 	var body struct {
@@ -124,38 +205,116 @@ func (b *Bench) PutSitesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Printf("[API] Requested apps to install: %v\n", body.Apps)
 
-	// Create site
-	fmt.Printf("[API] Creating site %s...\n", siteName)
-	if err := b.NewSite(siteName, "root", "root"); err != nil {
-		fmt.Printf("[ERROR] Could not create new site: %s %v", siteName, err)
-		writeError(w, 500, fmt.Sprintf("failed to create site: %v", err))
+	// Resolve dependencies before touching the site
+	installOrder, err := b.PlanAppInstall(body.Apps)
+	if err != nil {
+		writeError(w, 422, fmt.Sprintf("failed to resolve apps: %v", err))
 		return
 	}
-	fmt.Printf("[API] Site %s created successfully\n", siteName)
-
-	// Apply apps
-	for _, app := range body.Apps {
-		fmt.Printf("[API] Installing app %s on site %s...\n", app, siteName)
-		if err := b.InstallApp(siteName, app); err != nil {
-			fmt.Printf("[API] Fail to install app:%s on site: %s %v", app, siteName, err)
-			writeError(w, 500, fmt.Sprintf("failed to install app %s: %v", app, err))
-			b.DropSite(siteName, "root", "root")
-			return
-		}
-		fmt.Printf("[API] App %s installed successfully\n", app)
-	}
 
-	// Restart deployment
-	fmt.Println("[API] Restarting deployment services...")
-	if err := b.RestartDeployment(); err != nil {
-		fmt.Printf("[ERROR] Deployment restart failed: %v\n", err)
+	// Create site and apply apps, dropping the site again if an app fails
+	fmt.Printf("[API] Creating site %s with apps %v...\n", siteName, installOrder)
+	if err := b.CreateSite(siteName, installOrder, b.DBRootUser, b.DBRootPassword); err != nil {
+		fmt.Printf("[ERROR] Could not create site %s: %v\n", siteName, err)
+		writeError(w, jobStatus(err), err.Error())
+		return
 	}
+	fmt.Printf("[API] Site %s created successfully\n", siteName)
 
+	// Reload deployment so the new site is served without dropping requests
+	fmt.Println("[API] Reloading deployment services...")
 	resp := map[string]interface{}{
 		"site": siteName,
-		"apps": body.Apps,
+		"apps": installOrder,
 		"url":  fmt.Sprintf("http://%s", siteName),
 	}
+	if err := b.ReloadDeployment(); err != nil {
+		fmt.Printf("[ERROR] Deployment reload failed: %v\n", err)
+		resp["reload_error"] = err.Error()
+	}
+	b.renewCertificatesAsync()
 	writeJSON(w, 201, resp)
 	fmt.Printf("[API] Site %s creation & apps applied successfully\n", siteName)
 }
+
+// DeleteSiteHandler drops a site
+func (b *Bench) DeleteSiteHandler(w http.ResponseWriter, r *http.Request) {
+	siteName := chi.URLParam(r, "name")
+	fmt.Printf("[API] DeleteSiteHandler called for site: %s\n", siteName)
+	if !b.siteExists(siteName) {
+		writeError(w, 404, "site not found")
+		return
+	}
+
+	if err := b.DropSite(siteName, b.DBRootUser, b.DBRootPassword); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to drop site: %v", err))
+		return
+	}
+	resp := map[string]interface{}{"site": siteName, "dropped": true}
+	if err := b.ReloadDeployment(); err != nil {
+		fmt.Printf("[ERROR] Deployment reload failed: %v\n", err)
+		resp["reload_error"] = err.Error()
+	}
+	writeJSON(w, 200, resp)
+}
+
+// InstallAppsHandler installs apps and their dependencies on an existing site
+func (b *Bench) InstallAppsHandler(w http.ResponseWriter, r *http.Request) {
+	siteName := chi.URLParam(r, "name")
+	fmt.Printf("[API] InstallAppsHandler called for site: %s\n", siteName)
+	if !b.siteExists(siteName) {
+		writeError(w, 404, "site not found")
+		return
+	}
+
+	var body struct {
+		Apps []string `json:"apps"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, 400, "invalid JSON body")
+		return
+	}
+
+	installOrder, err := b.PlanAppInstall(body.Apps)
+	if err != nil {
+		writeError(w, 422, fmt.Sprintf("failed to resolve apps: %v", err))
+		return
+	}
+	current, err := b.ListAppsOnSite(siteName)
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to get site apps: %v", err))
+		return
+	}
+	if err := b.installMissingApps(siteName, installOrder, utils.ExtractAppNames(current)); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to install apps: %v", err))
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"site": siteName, "apps": installOrder})
+}
+
+// MigrateSiteHandler runs migrations on a site
+func (b *Bench) MigrateSiteHandler(w http.ResponseWriter, r *http.Request) {
+	siteName := chi.URLParam(r, "name")
+	fmt.Printf("[API] MigrateSiteHandler called for site: %s\n", siteName)
+	if !b.siteExists(siteName) {
+		writeError(w, 404, "site not found")
+		return
+	}
+
+	if err := b.Migrate(siteName); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to migrate site: %v", err))
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"site": siteName, "migrated": true})
+}
+
+// UpdateHandler pulls apps, updates dependencies, migrates all sites and rebuilds assets
+func (b *Bench) UpdateHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] UpdateHandler called")
+
+	if err := b.ManualUpdate(); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("update failed: %v", err))
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"updated": true})
+}
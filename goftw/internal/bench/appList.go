@@ -1,57 +1,160 @@
 package bench
 
-import "goftw/internal/entity"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"goftw/internal/entity"
+	"goftw/internal/environ"
+)
 
 var (
-	appsForReact = []entity.AppForReact{
+	// Built-in catalog entries, extended or overridden by the catalog file
+	defaultCatalog = []entity.CatalogApp{
 		{
 			Name:        "erpnext",
 			Description: "ERPNext is a comprehensive open source ERP system for businesses.",
-			// Asset:       "/assets/erpnext/logo.png", // or whatever frappe provides
+			Repo:        "https://github.com/frappe/erpnext",
+			Asset:       "/assets/erpnext/images/erpnext-logo.svg",
+			Category:    "erp",
 		},
 		{
 			Name:        "builder",
 			Description: "Builder helps you visually design and customize Frappe apps.",
-			// Asset:       "/assets/builder/logo.png",
+			Repo:        "https://github.com/frappe/builder",
+			Category:    "website",
 		},
 		{
 			Name:        "frappe",
 			Description: "Frappe Framework is a full-stack web application framework in Python & JS.",
-			// Asset:       "/assets/frappe/logo.png",
+			Repo:        "https://github.com/frappe/frappe",
+			Asset:       "/assets/frappe/images/frappe-framework-logo.svg",
+			Category:    "framework",
 		},
 		{
 			Name:        "hrms",
 			Description: "HRMS provides human resource management features like payroll, leave, and attendance.",
-			// Asset:       "/assets/hrms/logo.png",
+			Repo:        "https://github.com/frappe/hrms",
+			Asset:       "/assets/hrms/images/frappe-hr-logo.svg",
+			Category:    "hr",
 		},
 		{
 			Name:        "lending",
 			Description: "Lending app for managing loan requests, approvals, and repayments.",
-			// Asset:       "/assets/lending/logo.png",
+			Repo:        "https://github.com/frappe/lending",
+			Category:    "finance",
 		},
 		{
 			Name:        "helpdesk",
 			Description: "Helpdesk app to manage support tickets and customer queries.",
-			// Asset:       "/assets/helpdesk/logo.png",
+			Repo:        "https://github.com/frappe/helpdesk",
+			Category:    "support",
 		},
 		{
 			Name:        "crm",
 			Description: "CRM app to manage leads, opportunities, and customer relationships.",
-			// Asset:       "/assets/crm/logo.png",
+			Repo:        "https://github.com/frappe/crm",
+			Category:    "sales",
 		},
 		{
 			Name:        "insights",
 			Description: "Insights provides analytics and reporting tools within the Frappe ecosystem.",
-			// Asset:       "/assets/insights/logo.png",
+			Repo:        "https://github.com/frappe/insights",
+			Category:    "analytics",
 		},
 		{
 			Name:        "blog",
 			Description: "Blog app for publishing articles and managing content.",
-			// Asset:       "/assets/blog/logo.png",
+			Repo:        "https://github.com/frappe/blog",
+			Category:    "website",
 		},
 	}
 )
 
-func GetAppsForReact() []entity.AppForReact {
-	return appsForReact
+// LoadCatalog merges the built-in catalog with the operator catalog file.
+// File entries override built-in entries of the same name, new entries are appended.
+func LoadCatalog() ([]entity.CatalogApp, error) {
+	apps := make([]entity.CatalogApp, len(defaultCatalog))
+	copy(apps, defaultCatalog)
+
+	path := environ.GetCatalogFile()
+	catalog, err := entity.LoadCatalog(path)
+	if os.IsNotExist(err) {
+		return apps, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load catalog %s: %w", path, err)
+	}
+	return mergeCatalog(apps, catalog.Apps, path), nil
+}
+
+// mergeCatalog overrides the entries of apps with the file's entries of the
+// same name and appends the others; entries without a name are skipped
+func mergeCatalog(apps, file []entity.CatalogApp, path string) []entity.CatalogApp {
+	index := map[string]int{}
+	for i, app := range apps {
+		index[app.Name] = i
+	}
+	for _, app := range file {
+		if app.Name == "" {
+			fmt.Printf("[WARN] Skipping catalog entry without a name in %s\n", path)
+			continue
+		}
+		if i, ok := index[app.Name]; ok {
+			apps[i] = app
+			continue
+		}
+		index[app.Name] = len(apps)
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// catalogEntry returns the catalog entry for an app, if any
+func catalogEntry(app string) (entity.CatalogApp, bool) {
+	apps, err := LoadCatalog()
+	if err != nil {
+		fmt.Printf("[WARN] %v\n", err)
+		return entity.CatalogApp{}, false
+	}
+	for _, entry := range apps {
+		if entry.Name == app {
+			return entry, true
+		}
+	}
+	return entity.CatalogApp{}, false
+}
+
+// Catalog returns the app catalog annotated with whether each app is fetched
+// into the bench and how many sites it is installed on.
+func (b *Bench) Catalog() ([]entity.CatalogApp, error) {
+	apps, err := LoadCatalog()
+	if err != nil {
+		return nil, err
+	}
+
+	sitesByApp, err := b.SitesByApp()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range apps {
+		appPath := filepath.Join(b.Path, "apps", apps[i].Name)
+		_, statErr := os.Stat(appPath)
+		apps[i].Fetched = statErr == nil
+		apps[i].Installs = len(sitesByApp[apps[i].Name])
+
+		// Prefer what the fetched app says about itself over handwritten entries
+		if apps[i].Fetched {
+			meta := ReadAppMeta(appPath, apps[i].Name)
+			if meta.Description != "" {
+				apps[i].Description = meta.Description
+			}
+			if meta.LogoURL != "" {
+				apps[i].Asset = meta.LogoURL
+			}
+		}
+	}
+	return apps, nil
 }
@@ -0,0 +1,86 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"goftw/internal/entity"
+)
+
+// TestMergeCatalog tests how catalog file entries merge with the built-in entries
+func TestMergeCatalog(t *testing.T) {
+	builtin := []entity.CatalogApp{
+		{Name: "erpnext", Repo: "https://github.com/frappe/erpnext", Category: "erp"},
+		{Name: "hrms", Repo: "https://github.com/frappe/hrms", Category: "hr"},
+	}
+
+	tests := []struct {
+		name string
+		file []entity.CatalogApp
+		want []entity.CatalogApp
+	}{
+		{
+			name: "empty file keeps the built-in entries",
+			want: builtin,
+		},
+		{
+			name: "same name replaces the whole entry in place",
+			file: []entity.CatalogApp{{Name: "erpnext", Repo: "https://github.com/acme/erpnext", Branch: "version-15"}},
+			want: []entity.CatalogApp{
+				{Name: "erpnext", Repo: "https://github.com/acme/erpnext", Branch: "version-15"},
+				builtin[1],
+			},
+		},
+		{
+			name: "new names are appended in file order",
+			file: []entity.CatalogApp{{Name: "lms"}, {Name: "crm"}},
+			want: append(append([]entity.CatalogApp{}, builtin...), entity.CatalogApp{Name: "lms"}, entity.CatalogApp{Name: "crm"}),
+		},
+		{
+			name: "entries without a name are skipped",
+			file: []entity.CatalogApp{{Repo: "https://github.com/acme/unnamed"}, {Name: "crm"}},
+			want: append(append([]entity.CatalogApp{}, builtin...), entity.CatalogApp{Name: "crm"}),
+		},
+		{
+			name: "a repeated new name overrides its earlier file entry",
+			file: []entity.CatalogApp{{Name: "crm", Branch: "develop"}, {Name: "crm", Branch: "main"}},
+			want: append(append([]entity.CatalogApp{}, builtin...), entity.CatalogApp{Name: "crm", Branch: "main"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apps := append([]entity.CatalogApp{}, builtin...)
+			got := mergeCatalog(apps, tt.file, "catalog.json")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("UNEXPECTED CATALOG\nEXPECTED: %+v\nGOT:      %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestLoadCatalogFile tests that JSON and YAML catalog files parse the same
+func TestLoadCatalogFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"catalog.json": `{"apps": [{"name": "crm", "repo": "https://github.com/frappe/crm", "branch": "main", "category": "sales"}]}`,
+		"catalog.yaml": "apps:\n  - name: crm\n    repo: https://github.com/frappe/crm\n    branch: main\n    category: sales\n",
+	}
+	want := []entity.CatalogApp{{Name: "crm", Repo: "https://github.com/frappe/crm", Branch: "main", Category: "sales"}}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		catalog, err := entity.LoadCatalog(path)
+		if err != nil {
+			t.Fatalf("EXPECTED no error for %s GOT %v", name, err)
+		}
+		if !reflect.DeepEqual(catalog.Apps, want) {
+			t.Fatalf("UNEXPECTED CATALOG FROM %s\nEXPECTED: %+v\nGOT:      %+v", name, want, catalog.Apps)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"goftw/internal/entity"
+)
+
+var (
+	versionRegex      = regexp.MustCompile(`(?m)^__version__\s*=\s*["']([^"']+)["']`)
+	hooksStringRegex  = regexp.MustCompile(`(?m)^(app_name|app_title|app_publisher|app_description|app_logo_url)\s*=\s*["']([^"']*)["']`)
+	requiredAppsRegex = regexp.MustCompile(`(?ms)^required_apps\s*=\s*\[(.*?)\]`)
+	quotedRegex       = regexp.MustCompile(`["']([^"']+)["']`)
+	setupFieldRegex   = regexp.MustCompile(`\b(name|version|description)\s*=\s*["']([^"']*)["']`)
+	tomlFieldRegex    = regexp.MustCompile(`^(name|version|description)\s*=\s*["']([^"']*)["']`)
+	logoExtensions    = map[string]struct{}{".svg": {}, ".png": {}, ".jpg": {}, ".jpeg": {}, ".webp": {}}
+)
+
+// ReadAppMeta reads an app's metadata from pyproject.toml or setup.py, hooks.py
+// and its public folder. Missing files are skipped, so partial metadata is normal.
+func ReadAppMeta(appPath, app string) entity.AppMeta {
+	var meta entity.AppMeta
+
+	if fields, err := readPyprojectFields(filepath.Join(appPath, "pyproject.toml")); err == nil {
+		applyPackageFields(&meta, fields)
+	}
+	if fields, err := readSetupFields(filepath.Join(appPath, "setup.py")); err == nil {
+		applyPackageFields(&meta, fields)
+	}
+	if meta.Name == "" {
+		meta.Name = app
+	}
+	if meta.Version == "" {
+		meta.Version = appVersion(appPath, app)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(appPath, app, "hooks.py")); err == nil {
+		applyHooks(&meta, data)
+	}
+	if meta.LogoURL == "" {
+		meta.LogoURL = findLogo(appPath, app)
+	}
+	if meta.RequiredApps == nil {
+		meta.RequiredApps = []string{}
+	}
+	return meta
+}
+
+// applyPackageFields fills fields that are still empty, so earlier sources take precedence
+func applyPackageFields(meta *entity.AppMeta, fields map[string]string) {
+	if meta.Name == "" {
+		meta.Name = fields["name"]
+	}
+	if meta.Version == "" {
+		meta.Version = fields["version"]
+	}
+	if meta.Description == "" {
+		meta.Description = fields["description"]
+	}
+}
+
+// readPyprojectFields reads string fields from the [project] table of a pyproject.toml
+func readPyprojectFields(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{}
+	inProject := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inProject = line == "[project]"
+			continue
+		}
+		if !inProject {
+			continue
+		}
+		if m := tomlFieldRegex.FindStringSubmatch(line); m != nil {
+			fields[m[1]] = m[2]
+		}
+	}
+	return fields, scanner.Err()
+}
+
+// readSetupFields reads literal string arguments passed to setup() in a setup.py
+func readSetupFields(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]string{}
+	for _, m := range setupFieldRegex.FindAllSubmatch(data, -1) {
+		if _, ok := fields[string(m[1])]; !ok {
+			fields[string(m[1])] = string(m[2])
+		}
+	}
+	return fields, nil
+}
+
+// applyHooks reads app_* strings and required_apps from hooks.py
+func applyHooks(meta *entity.AppMeta, data []byte) {
+	for _, m := range hooksStringRegex.FindAllSubmatch(data, -1) {
+		value := string(m[2])
+		switch string(m[1]) {
+		case "app_title":
+			meta.Title = value
+		case "app_publisher":
+			meta.Publisher = value
+		case "app_description":
+			if meta.Description == "" {
+				meta.Description = value
+			}
+		case "app_logo_url":
+			meta.LogoURL = value
+		}
+	}
+
+	if m := requiredAppsRegex.FindSubmatch(data); m != nil {
+		apps := []string{}
+		for _, q := range quotedRegex.FindAllSubmatch(stripPythonComments(m[1]), -1) {
+			apps = append(apps, string(q[1]))
+		}
+		meta.RequiredApps = apps
+	}
+}
+
+// stripPythonComments drops # comments so commented-out list items are ignored
+func stripPythonComments(data []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if i := bytes.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// findLogo returns the asset URL of the first logo image in the app's public/images folder
+func findLogo(appPath, app string) string {
+	imagesDir := filepath.Join(appPath, app, "public", "images")
+	entries, err := os.ReadDir(imagesDir)
+	if err != nil {
+		return ""
+	}
+
+	var logos []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.Contains(strings.ToLower(name), "logo") {
+			continue
+		}
+		if _, ok := logoExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+			logos = append(logos, name)
+		}
+	}
+	if len(logos) == 0 {
+		return ""
+	}
+	sort.Strings(logos)
+	return "/assets/" + app + "/images/" + logos[0]
+}
+
+// appVersion reads __version__ from the app's python package __init__.py.
+func appVersion(appPath, app string) string {
+	data, err := os.ReadFile(filepath.Join(appPath, app, "__init__.py"))
+	if err != nil {
+		return ""
+	}
+	if m := versionRegex.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeAppFile writes a file relative to an app fixture directory
+func writeAppFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReadAppMetaPyproject reads metadata from a modern app layout
+func TestReadAppMetaPyproject(t *testing.T) {
+	root := t.TempDir()
+	writeAppFile(t, root, "pyproject.toml", `[project]
+name = "hrms"
+authors = [{ name = "Frappe Technologies Pvt. Ltd.", email = "contact@frappe.io" }]
+description = "Modern HR and Payroll Software"
+dynamic = ["version"]
+
+[build-system]
+name = "flit_core"
+`)
+	writeAppFile(t, root, "hrms/__init__.py", `__version__ = "16.0.0-dev"`+"\n")
+	writeAppFile(t, root, "hrms/hooks.py", `app_name = "hrms"
+app_title = "Frappe HR"
+app_publisher = "Frappe Technologies Pvt. Ltd."
+app_description = "Modern HR and Payroll Software"
+app_logo_url = "/assets/hrms/images/frappe-hr-logo.svg"
+
+required_apps = [
+	"frappe/erpnext",
+	# "frappe/payments",
+]
+`)
+
+	got := ReadAppMeta(root, "hrms")
+	want := struct {
+		Name, Title, Version, Description, Publisher, LogoURL string
+		RequiredApps                                          []string
+	}{
+		"hrms", "Frappe HR", "16.0.0-dev", "Modern HR and Payroll Software",
+		"Frappe Technologies Pvt. Ltd.", "/assets/hrms/images/frappe-hr-logo.svg",
+		[]string{"frappe/erpnext"},
+	}
+
+	if got.Name != want.Name || got.Title != want.Title || got.Version != want.Version ||
+		got.Description != want.Description || got.Publisher != want.Publisher || got.LogoURL != want.LogoURL {
+		t.Fatalf("UNEXPECTED METADATA\nEXPECTED: %+v\nGOT:      %+v", want, got)
+	}
+	if !reflect.DeepEqual(got.RequiredApps, want.RequiredApps) {
+		t.Fatalf("REQUIRED APPS MISMATCH\nEXPECTED: %v\nGOT:      %v", want.RequiredApps, got.RequiredApps)
+	}
+}
+
+// TestReadAppMetaSetupPy falls back to setup.py and discovers the logo in public/images
+func TestReadAppMetaSetupPy(t *testing.T) {
+	root := t.TempDir()
+	writeAppFile(t, root, "setup.py", `from setuptools import setup, find_packages
+from legacy import __version__ as version
+
+setup(
+	name="legacy",
+	version=version,
+	description="A legacy app",
+	packages=find_packages(),
+)
+`)
+	writeAppFile(t, root, "legacy/__init__.py", "__version__ = '1.2.3'\n")
+	writeAppFile(t, root, "legacy/hooks.py", "app_title = \"Legacy\"\n")
+	writeAppFile(t, root, "legacy/public/images/legacy-logo.svg", "<svg/>")
+	writeAppFile(t, root, "legacy/public/images/banner.png", "")
+
+	got := ReadAppMeta(root, "legacy")
+
+	if got.Name != "legacy" || got.Version != "1.2.3" || got.Description != "A legacy app" || got.Title != "Legacy" {
+		t.Fatalf("UNEXPECTED METADATA: %+v", got)
+	}
+	if got.LogoURL != "/assets/legacy/images/legacy-logo.svg" {
+		t.Fatalf("EXPECTED LOGO FROM PUBLIC FOLDER, GOT: %q", got.LogoURL)
+	}
+	if got.RequiredApps == nil || len(got.RequiredApps) != 0 {
+		t.Fatalf("EXPECTED EMPTY REQUIRED APPS, GOT: %#v", got.RequiredApps)
+	}
+}
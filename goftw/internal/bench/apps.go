@@ -2,7 +2,6 @@ package bench
 
 import (
 	"fmt"
-	"goftw/internal/entity"
 	"goftw/internal/fns"
 	"goftw/internal/utils"
 	"os"
@@ -11,8 +10,15 @@ import (
 
 // GetApp fetches an app from branch, auto-healing if a previous fetch was incomplete
 func (b *Bench) GetApp(app string) error {
-	// First attempt: try to get by name directly
-	if err := b.ExecRunInBenchPrintIO("bench", "get-app", "--branch", b.Branch, app); err == nil {
+	// First attempt: use the catalog source if any, else get by name directly
+	source, branch := app, b.Branch
+	if entry, ok := catalogEntry(app); ok && entry.Repo != "" {
+		source = entry.Repo
+		if entry.Branch != "" {
+			branch = entry.Branch
+		}
+	}
+	if err := b.ExecRunInBenchPrintIO("bench", "get-app", "--branch", branch, source); err == nil {
 		return nil
 	}
 
@@ -36,25 +42,7 @@ func (b *Bench) GetApp(app string) error {
 	return nil
 }
 
-// fetchMissingApps ensures that every app in instance.json exists in bench/apps
-func (b *Bench) fetchMissingApps(site entity.Site) error {
-	for _, app := range site.Apps {
-		if app == "frappe" {
-			continue
-		}
-		appPath := filepath.Join(b.Path, "apps", app)
-		if _, err := os.Stat(appPath); os.IsNotExist(err) {
-			fmt.Printf("[APP] Fetching missing app: %s\n", app)
-			if err := b.GetApp(app); err != nil {
-				fmt.Printf("[ERROR] Failed to fetch app %s: %v\n", app, err)
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-// installMissingApps installs apps that are expected but not currently present
+// installMissingApps installs apps that are expected but not currently present, in the order given
 func (b *Bench) installMissingApps(siteName string, expected, current []string) error {
 	for _, app := range utils.Difference(expected, current) {
 		if app != "frappe" {
@@ -89,6 +77,13 @@ func (b *Bench) UninstallApp(site, app string) error {
 
 // InstallApp installs an app on a site
 func (b *Bench) InstallApp(site, app string) error {
+	return b.runJob("app_install", func() error {
+		return b.installApp(site, app)
+	})
+}
+
+// installApp installs an app on a site, fetching it first if the direct install fails
+func (b *Bench) installApp(site, app string) error {
 	fmt.Printf("[APPS] Installing app: %s on site: %s\n", app, site)
 
 	// First attempt: direct install
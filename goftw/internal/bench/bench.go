@@ -6,9 +6,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"goftw/internal/certs"
+	"goftw/internal/entity"
 	"goftw/internal/environ"
 	internalExec "goftw/internal/fns"
+	"goftw/internal/proxy"
+	"goftw/internal/supervisor"
 	"goftw/internal/whoiam"
 )
 
@@ -18,6 +24,39 @@ type Bench struct {
 	Path       string `json:"path"`
 	Branch     string `json:"branch"`
 	ServerName string `json:"server_name"`
+
+	// Instance is the loaded instance.json, used by handlers that reconcile against it
+	Instance *entity.Instance `json:"-"`
+	// Database root credentials used by API handlers to create and drop sites
+	DBRootUser     string `json:"-"`
+	DBRootPassword string `json:"-"`
+
+	mu            sync.Mutex
+	lastReconcile *entity.ReconcileStatus
+	siteAppCounts map[string]int
+	jobs          sync.WaitGroup
+	draining      bool
+	deployment    *Deployment
+	controller    supervisor.Controller
+	programs      *supervisor.Config // programs the running supervisor was started with
+	nginxStatus   *entity.NginxStatus
+	proxy         *proxy.Proxy // the built-in dynamic proxy, when enabled
+	certMu        sync.Mutex   // serialises certificate renewals
+	certificates  map[string]*entity.CertificateStatus
+	alerted       map[string]bool // active alert conditions, by name/reason
+	alerts        []entity.CertificateAlert
+	acme          *certs.ACME
+}
+
+// instance returns a copy of the loaded instance.json, empty when none was
+// loaded, so it can be read while API requests change domains or scaling
+func (b *Bench) instance() entity.Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Instance == nil {
+		return entity.Instance{}
+	}
+	return b.Instance.Copy()
 }
 
 // CopyCommonSitesConfig ensures sites/ exists and copies common_sites_config.json
@@ -102,7 +141,9 @@ func (b *Bench) ExecRunInBenchSwallowIO(args ...string) ([]byte, error) {
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
 
+	start := time.Now()
 	err := cmd.Run()
+	observeCommand(args, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("bench failed: %s, stderr: %s", err, stderr.String())
 	}
@@ -120,7 +161,9 @@ func (b *Bench) ExecRunInBenchPrintIO(args ...string) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	start := time.Now()
 	err := cmd.Run()
+	observeCommand(args, start, err)
 	if err != nil {
 		return fmt.Errorf("bench failed: %v", err)
 	}
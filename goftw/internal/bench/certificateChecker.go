@@ -0,0 +1,224 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"goftw/internal/entity"
+	"goftw/internal/metrics"
+)
+
+const (
+	certificateCheckInterval = time.Hour
+	defaultAlertDays         = 14
+	defaultAlertFailures     = 3
+	maxCertificateAlerts     = 50
+)
+
+// liveTLSAddr is where nginx serves TLS, dialled with each name as SNI
+var liveTLSAddr = "127.0.0.1:443"
+
+// CheckCertificates inspects the certificate of every site name and custom
+// domain, the file on disk and the one nginx serves on 443, updates the
+// expiry metrics and raises alerts
+func (b *Bench) CheckCertificates(ctx context.Context) error {
+	names, err := b.certificateNames(certStore())
+	if err != nil {
+		return err
+	}
+	settings := b.tlsSettings()
+	for _, n := range names {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, err := os.Stat(n.Certificate); err != nil && n.Managed {
+			// Not issued yet: nginx serves no TLS for it, renewal reports why
+			continue
+		}
+		live := checkLiveCertificate(n.Name)
+		status := b.updateCertificate(n, func(status *entity.CertificateStatus) {
+			status.Live = live
+		})
+		b.raiseAlerts(status, settings)
+	}
+	b.pruneCertificates(names)
+	b.recordCertificateMetrics()
+	return nil
+}
+
+// checkLiveCertificate handshakes with nginx as name and describes the
+// certificate it serves. Self-signed certificates are expected, so the chain
+// is not verified, only that the certificate covers name.
+func checkLiveCertificate(name string) *entity.CertificateCheck {
+	check := &entity.CertificateCheck{CheckedAt: time.Now()}
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", liveTLSAddr, &tls.Config{ServerName: name, InsecureSkipVerify: true})
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	defer conn.Close()
+	peers := conn.ConnectionState().PeerCertificates
+	if len(peers) == 0 {
+		check.Error = "no certificate served"
+		return check
+	}
+	notAfter := peers[0].NotAfter
+	check.NotAfter = &notAfter
+	if err := peers[0].VerifyHostname(name); err != nil {
+		check.Error = fmt.Sprintf("served certificate does not cover %s", name)
+	}
+	return check
+}
+
+// daysLeft returns the whole days until the file's or the served
+// certificate expires, whichever is first, or nil when neither is known
+func daysLeft(now time.Time, notAfter *time.Time, live *entity.CertificateCheck) *int {
+	var first *time.Time
+	if notAfter != nil {
+		first = notAfter
+	}
+	if live != nil && live.NotAfter != nil && (first == nil || live.NotAfter.Before(*first)) {
+		first = live.NotAfter
+	}
+	if first == nil {
+		return nil
+	}
+	days := int(math.Floor(first.Sub(now).Hours() / 24))
+	return &days
+}
+
+// raiseAlerts raises an alert when status expires within alert_days or its
+// renewal failed alert_failures times in a row
+func (b *Bench) raiseAlerts(status entity.CertificateStatus, settings entity.TLS) {
+	alertDays, alertFailures := settings.AlertDays, settings.AlertFailures
+	if alertDays <= 0 {
+		alertDays = defaultAlertDays
+	}
+	if alertFailures <= 0 {
+		alertFailures = defaultAlertFailures
+	}
+
+	expiring := status.DaysLeft != nil && *status.DaysLeft <= alertDays
+	b.alertOnce(expiring, entity.CertificateAlert{
+		Name: status.Name, Site: status.Site, Reason: "expiring", DaysLeft: status.DaysLeft,
+		Message: fmt.Sprintf("certificate of %s expires in %s", status.Name, formatDays(status.DaysLeft)),
+	}, settings.AlertWebhook)
+	failing := status.Failures >= alertFailures
+	b.alertOnce(failing, entity.CertificateAlert{
+		Name: status.Name, Site: status.Site, Reason: "renewal_failing", Failures: status.Failures,
+		Message: fmt.Sprintf("renewing the certificate of %s failed %d times in a row: %s", status.Name, status.Failures, status.Error),
+	}, settings.AlertWebhook)
+}
+
+// formatDays renders days for an alert message
+func formatDays(days *int) string {
+	switch {
+	case days == nil:
+		return "an unknown number of days"
+	case *days < 0:
+		return "0 days (expired)"
+	case *days == 1:
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", *days)
+}
+
+// alertOnce emits alert when active and it was not active before; it is
+// raised again once the condition cleared and returned
+func (b *Bench) alertOnce(active bool, alert entity.CertificateAlert, webhook string) {
+	key := alert.Name + "/" + alert.Reason
+	b.mu.Lock()
+	if !active || b.alerted[key] {
+		if !active {
+			delete(b.alerted, key)
+		}
+		b.mu.Unlock()
+		return
+	}
+	if b.alerted == nil {
+		b.alerted = map[string]bool{}
+	}
+	b.alerted[key] = true
+	alert.At = time.Now()
+	b.alerts = append(b.alerts, alert)
+	if len(b.alerts) > maxCertificateAlerts {
+		b.alerts = b.alerts[len(b.alerts)-maxCertificateAlerts:]
+	}
+	b.mu.Unlock()
+
+	fmt.Printf("[ALERT] %s\n", alert.Message)
+	metrics.CertificateAlerts.Inc(alert.Reason)
+	if webhook != "" {
+		go postAlert(webhook, alert)
+	}
+}
+
+// postAlert sends alert to the webhook as JSON
+func postAlert(webhook string, alert entity.CertificateAlert) {
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(webhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("[WARN] Failed to send alert to webhook: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		fmt.Printf("[WARN] Alert webhook answered %s\n", resp.Status)
+	}
+}
+
+// CertificateAlerts returns the most recent alerts, oldest first
+func (b *Bench) CertificateAlerts() []entity.CertificateAlert {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]entity.CertificateAlert{}, b.alerts...)
+}
+
+// recordCertificateMetrics exports the days until expiry and the renewal
+// failures of every checked certificate. The series are built first and
+// swapped in, so a scrape meanwhile still sees the previous check.
+func (b *Bench) recordCertificateMetrics() {
+	now := time.Now()
+	expiry := metrics.CertificateExpiryDays.NewSet()
+	failures := metrics.CertificateRenewalFailures.NewSet()
+	for _, status := range b.Certificates() {
+		if status.NotAfter != nil {
+			expiry.Set(status.NotAfter.Sub(now).Hours()/24, status.Name, "file")
+		}
+		if status.Live != nil && status.Live.NotAfter != nil {
+			expiry.Set(status.Live.NotAfter.Sub(now).Hours()/24, status.Name, "live")
+		}
+		failures.Set(float64(status.Failures), status.Name)
+	}
+	metrics.CertificateExpiryDays.Replace(expiry)
+	metrics.CertificateRenewalFailures.Replace(failures)
+}
+
+// RunCertificateChecker checks certificates now and every hour until ctx is done
+func (b *Bench) RunCertificateChecker(ctx context.Context) {
+	ticker := time.NewTicker(certificateCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := b.CheckCertificates(ctx); err != nil && ctx.Err() == nil {
+			fmt.Printf("[CERTS] Certificate check failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
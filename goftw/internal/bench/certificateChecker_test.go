@@ -0,0 +1,135 @@
+package bench
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"goftw/internal/certs"
+	"goftw/internal/entity"
+	"goftw/internal/metrics"
+)
+
+// writeCertificate writes a self-signed certificate for name valid for days into dir
+func writeCertificate(t *testing.T, dir, name string, days int) (string, tls.Certificate) {
+	t.Helper()
+	certPEM, keyPEM, err := certs.SelfSigned([]string{name}, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(path, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, pair
+}
+
+// TestCheckCertificates tests expiry from the files and the live handshake, metrics and alerts
+func TestCheckCertificates(t *testing.T) {
+	benchPath := t.TempDir()
+	sslDir := t.TempDir()
+	aPath, aPair := writeCertificate(t, sslDir, "a.localhost", 10)
+	shopPath, _ := writeCertificate(t, sslDir, "shop.example.com", 100)
+	// nginx still serves an older certificate for shop.example.com
+	_, shopServed := writeCertificate(t, t.TempDir(), "shop.example.com", 5)
+
+	siteConfig := `{"db_name": "a", "ssl_certificate": "` + aPath + `", "ssl_certificate_key": "/ssl/a.key",
+		"domains": [{"domain": "shop.example.com", "ssl_certificate": "` + shopPath + `", "ssl_certificate_key": "/ssl/shop.key"}]}`
+	if err := os.MkdirAll(filepath.Join(benchPath, "sites", "a.localhost"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(benchPath, "sites", "a.localhost", "site_config.json"), []byte(siteConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName == "shop.example.com" {
+			return &shopServed, nil
+		}
+		return &aPair, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				_ = conn.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+	defer func(addr string) { liveTLSAddr = addr }(liveTLSAddr)
+	liveTLSAddr = listener.Addr().String()
+
+	b := &Bench{Path: benchPath, Instance: &entity.Instance{TLS: entity.TLS{AlertDays: 7}}}
+	if err := b.CheckCertificates(context.Background()); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	statuses := b.Certificates()
+	if len(statuses) != 2 {
+		t.Fatalf("EXPECTED 2 certificates GOT %+v", statuses)
+	}
+	a, shop := statuses[0], statuses[1]
+	if a.Name != "a.localhost" || a.Issuer != "site_config" || a.DaysLeft == nil || *a.DaysLeft != 9 || a.Live == nil || a.Live.Error != "" {
+		t.Fatalf("EXPECTED a.localhost with 9 days left GOT %+v (live %+v)", a, a.Live)
+	}
+	if shop.Name != "shop.example.com" || shop.DaysLeft == nil || *shop.DaysLeft != 4 || shop.Live == nil || shop.Live.NotAfter == nil {
+		t.Fatalf("EXPECTED shop.example.com with the served certificate's 4 days left GOT %+v (live %+v)", shop, shop.Live)
+	}
+
+	// Only the served certificate is within 7 days
+	alerts := b.CertificateAlerts()
+	if len(alerts) != 1 || alerts[0].Name != "shop.example.com" || alerts[0].Reason != "expiring" {
+		t.Fatalf("EXPECTED one expiring alert for shop.example.com GOT %+v", alerts)
+	}
+	if err := b.CheckCertificates(context.Background()); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if alerts := b.CertificateAlerts(); len(alerts) != 1 {
+		t.Fatalf("EXPECTED no repeated alert GOT %+v", alerts)
+	}
+
+	var out strings.Builder
+	metrics.Default.WriteTo(&out)
+	for _, line := range []string{
+		`goftw_certificate_expiry_days{name="a.localhost",source="file"} 9.`,
+		`goftw_certificate_expiry_days{name="shop.example.com",source="live"} 4.`,
+		`goftw_certificate_expiry_days{name="shop.example.com",source="file"} 99.`,
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("EXPECTED metrics to contain %s GOT\n%s", line, out.String())
+		}
+	}
+}
+
+// TestRaiseAlerts tests that renewal failures alert once per streak
+func TestRaiseAlerts(t *testing.T) {
+	b := &Bench{}
+	settings := entity.TLS{AlertFailures: 2}
+	failures := []int{1, 2, 3, 0, 2}
+	expected := []int{0, 1, 1, 1, 2}
+	for i, n := range failures {
+		b.raiseAlerts(entity.CertificateStatus{Name: "shop.example.com", Failures: n, Error: "acme unreachable"}, settings)
+		if got := len(b.CertificateAlerts()); got != expected[i] {
+			t.Fatalf("EXPECTED %d alerts after %d failures GOT %d", expected[i], n, got)
+		}
+	}
+	if alert := b.CertificateAlerts()[0]; alert.Reason != "renewal_failing" || !strings.Contains(alert.Message, "acme unreachable") {
+		t.Fatalf("EXPECTED a renewal_failing alert with the error GOT %+v", alert)
+	}
+}
@@ -0,0 +1,372 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"goftw/internal/certs"
+	"goftw/internal/entity"
+	"goftw/internal/environ"
+)
+
+const (
+	defaultRenewBefore  = 30 * 24 * time.Hour
+	selfSignedValidity  = 365 * 24 * time.Hour
+	certificateInterval = 12 * time.Hour
+	issueTimeout        = 5 * time.Minute
+	retryBackoff        = 5 * time.Minute
+	maxRetryBackoff     = 6 * time.Hour // below certificateInterval, so every periodic run retries
+)
+
+// certificateName is a site name or custom domain and the certificate it is served with
+type certificateName struct {
+	Site        string
+	Name        string
+	Certificate string // the chain nginx is pointed at, or would be once issued
+	Managed     bool   // whether goftw issues it, or the site_config.json brings it
+}
+
+// tlsSettings returns the certificate settings of instance.json
+func (b *Bench) tlsSettings() entity.TLS {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Instance == nil {
+		return entity.TLS{}
+	}
+	return b.Instance.TLS
+}
+
+// certStore is where managed certificates are kept
+func certStore() certs.Store {
+	return certs.Store{Dir: environ.GetCertsDir()}
+}
+
+// acmeWebroot is the directory nginx serves HTTP-01 challenges from. It is
+// kept apart from the certificates, which nginx's workers must not read.
+func (b *Bench) acmeWebroot() string {
+	return filepath.Join(b.Path, "config", "acme")
+}
+
+// isLocalName reports whether name only resolves locally, so no public CA
+// can validate it and it gets a self-signed certificate instead
+func isLocalName(name string) bool {
+	return name == "localhost" || strings.HasSuffix(name, ".localhost")
+}
+
+// certificateNames returns every site name and custom domain with its
+// certificate: the one of site_config.json, or the one goftw manages
+func (b *Bench) certificateNames(store certs.Store) ([]certificateName, error) {
+	sites, err := b.ListSites()
+	if err != nil {
+		return nil, err
+	}
+	var names []certificateName
+	add := func(site, name, certificate string) {
+		n := certificateName{Site: site, Name: name, Certificate: certificate}
+		if n.Certificate == "" {
+			n.Certificate, _ = store.Paths(name)
+			n.Managed = true
+		}
+		names = append(names, n)
+	}
+	for _, site := range sites {
+		siteCfg, err := entity.LoadSiteConfig(filepath.Join(b.Path, "sites", site, "site_config.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read site_config.json of %s: %v", site, err)
+		}
+		add(site, site, siteCfg.SSLCertificate)
+		for _, domain := range siteCfg.Domains {
+			add(site, domain.Domain, domain.SSLCertificate)
+		}
+	}
+	return names, nil
+}
+
+// managedCertificate returns the stored certificate paths for name when TLS
+// is enabled and one was issued, so nginx serves it on 443
+func (b *Bench) managedCertificate(name string) (string, string, bool) {
+	store := certStore()
+	if !b.tlsSettings().Enabled || !store.Has(name) {
+		return "", "", false
+	}
+	cert, key := store.Paths(name)
+	return cert, key, true
+}
+
+// RenewCertificates issues certificates for managed names that have none or
+// whose certificate expires within renew_before_days, then reloads the
+// deployment so nginx serves the new ones. Names whose issuance failed are
+// only tried again once their backoff passed, unless retryFailing is set.
+func (b *Bench) RenewCertificates(ctx context.Context, retryFailing bool) error {
+	if !b.tlsSettings().Enabled {
+		return nil
+	}
+	var renewed []string
+	err := b.runJob("certificates", func() error {
+		var err error
+		renewed, err = b.renewCertificates(ctx, certStore(), retryFailing)
+		return err
+	})
+	if len(renewed) > 0 {
+		fmt.Printf("[CERTS] Issued certificates for %v, reloading nginx\n", renewed)
+		if reloadErr := b.ReloadDeployment(); reloadErr != nil {
+			fmt.Printf("[ERROR] Deployment reload failed: %v\n", reloadErr)
+		}
+	}
+	return err
+}
+
+// renewCertificates is RenewCertificates' work against store. It returns
+// the names it issued certificates for; a failed name does not stop others.
+func (b *Bench) renewCertificates(ctx context.Context, store certs.Store, retryFailing bool) ([]string, error) {
+	b.certMu.Lock()
+	defer b.certMu.Unlock()
+
+	settings := b.tlsSettings()
+	renewBefore := defaultRenewBefore
+	if settings.RenewBefore > 0 {
+		renewBefore = time.Duration(settings.RenewBefore) * 24 * time.Hour
+	}
+	names, err := b.certificateNames(store)
+	if err != nil {
+		return nil, err
+	}
+
+	var renewed, failed []string
+	for _, n := range names {
+		if !n.Managed {
+			continue
+		}
+		issuer := "acme"
+		if isLocalName(n.Name) {
+			issuer = "self-signed"
+		}
+		now := time.Now()
+		if cert, err := store.Load(n.Name); err == nil && !cert.Expires(now, renewBefore) {
+			b.updateCertificate(n, func(status *entity.CertificateStatus) {
+				status.Issuer, status.Failures, status.Error, status.RetryAt = issuer, 0, "", nil
+			})
+			continue
+		}
+		if retryAt := b.certificateRetryAt(n.Name); !retryFailing && retryAt != nil && now.Before(*retryAt) {
+			fmt.Printf("[CERTS] Retrying %s after %s\n", n.Name, retryAt.Format(time.RFC3339))
+			continue
+		}
+
+		fmt.Printf("[CERTS] Issuing a %s certificate for %s\n", issuer, n.Name)
+		err := b.issueCertificate(ctx, store, n.Name, settings)
+		if err != nil {
+			fmt.Printf("[ERROR] Failed to issue a certificate for %s: %v\n", n.Name, err)
+			failed = append(failed, n.Name)
+		} else {
+			renewed = append(renewed, n.Name)
+		}
+		status := b.updateCertificate(n, func(status *entity.CertificateStatus) {
+			status.Issuer, status.AttemptedAt = issuer, &now
+			if err != nil {
+				status.Failures++
+				status.Error = err.Error()
+				retryAt := now.Add(backoff(status.Failures))
+				status.RetryAt = &retryAt
+				return
+			}
+			status.Failures, status.Error, status.RetryAt, status.RenewedAt = 0, "", nil, &now
+		})
+		b.raiseAlerts(status, settings)
+	}
+	b.pruneCertificates(names)
+	b.recordCertificateMetrics()
+
+	if len(failed) > 0 {
+		return renewed, fmt.Errorf("failed to issue certificates for %v", failed)
+	}
+	return renewed, nil
+}
+
+// backoff is how long a name waits after its issuance failed failures times
+// in a row: retryBackoff, doubled for every further failure up to
+// maxRetryBackoff, so a failing name does not hit the CA on every site or
+// domain change
+func backoff(failures int) time.Duration {
+	wait := retryBackoff
+	for i := 1; i < failures && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxRetryBackoff)
+}
+
+// certificateRetryAt returns when name may be issued again, or nil when its
+// last issuance did not fail
+func (b *Bench) certificateRetryAt(name string) *time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if status, ok := b.certificates[name]; ok && status.Failures > 0 && status.RetryAt != nil {
+		retryAt := *status.RetryAt
+		return &retryAt
+	}
+	return nil
+}
+
+// updateCertificate applies fn to the status of n, creating it, then
+// refreshes the file's expiry and returns a copy
+func (b *Bench) updateCertificate(n certificateName, fn func(*entity.CertificateStatus)) entity.CertificateStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.certificates == nil {
+		b.certificates = map[string]*entity.CertificateStatus{}
+	}
+	status, ok := b.certificates[n.Name]
+	if !ok {
+		status = &entity.CertificateStatus{Name: n.Name, Issuer: "site_config"}
+		b.certificates[n.Name] = status
+	}
+	now := time.Now()
+	status.Site, status.Certificate, status.CheckedAt = n.Site, n.Certificate, &now
+	fn(status)
+
+	status.NotAfter = nil
+	if cert, err := certs.Load(n.Certificate); err == nil {
+		notAfter := cert.NotAfter
+		status.NotAfter = &notAfter
+	}
+	status.DaysLeft = daysLeft(now, status.NotAfter, status.Live)
+	return *status
+}
+
+// pruneCertificates forgets the statuses of names no site has anymore
+func (b *Bench) pruneCertificates(names []certificateName) {
+	current := map[string]bool{}
+	for _, n := range names {
+		current[n.Name] = true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for name := range b.certificates {
+		if !current[name] {
+			delete(b.certificates, name)
+		}
+	}
+	for key := range b.alerted {
+		if name, _, _ := strings.Cut(key, "/"); !current[name] {
+			delete(b.alerted, key)
+		}
+	}
+}
+
+// issueCertificate stores a new certificate for name: self-signed for local
+// names, from the ACME server otherwise
+func (b *Bench) issueCertificate(ctx context.Context, store certs.Store, name string, settings entity.TLS) error {
+	if isLocalName(name) {
+		certPEM, keyPEM, err := certs.SelfSigned([]string{name}, selfSignedValidity)
+		if err != nil {
+			return err
+		}
+		return store.Save(name, certPEM, keyPEM)
+	}
+
+	issuer, err := b.acmeIssuer(store, settings)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, issueTimeout)
+	defer cancel()
+	certPEM, keyPEM, err := issuer.Obtain(ctx, []string{name})
+	if err != nil {
+		return err
+	}
+	return store.Save(name, certPEM, keyPEM)
+}
+
+// acmeIssuer returns the ACME client, created on first use
+func (b *Bench) acmeIssuer(store certs.Store, settings entity.TLS) (*certs.ACME, error) {
+	b.mu.Lock()
+	issuer := b.acme
+	b.mu.Unlock()
+	if issuer != nil {
+		return issuer, nil
+	}
+	issuer, err := certs.NewACME(settings.Directory, settings.CARoots, filepath.Join(store.Dir, ".acme", "account.key"), b.acmeWebroot(), settings.Email)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.acme = issuer
+	b.mu.Unlock()
+	return issuer, nil
+}
+
+// Certificates returns the status of every certificate, by name
+func (b *Bench) Certificates() []entity.CertificateStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	list := make([]entity.CertificateStatus, 0, len(b.certificates))
+	for _, status := range b.certificates {
+		list = append(list, *status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// RunCertificateManager renews certificates now and every 12 hours until
+// ctx is done. It does nothing unless instance.json enables TLS.
+func (b *Bench) RunCertificateManager(ctx context.Context) {
+	if !b.tlsSettings().Enabled {
+		return
+	}
+	ticker := time.NewTicker(certificateInterval)
+	defer ticker.Stop()
+	for {
+		if err := b.RenewCertificates(ctx, false); err != nil && !b.ShuttingDown() {
+			fmt.Printf("[CERTS] %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renewCertificatesAsync issues certificates for newly added names without
+// holding up the request that added them. Names that keep failing wait for
+// their backoff instead of being retried on every change.
+func (b *Bench) renewCertificatesAsync() {
+	if !b.tlsSettings().Enabled {
+		return
+	}
+	go func() {
+		if err := b.RenewCertificates(context.Background(), false); err != nil {
+			fmt.Printf("[CERTS] %v\n", err)
+		}
+	}()
+}
+
+// CertificatesHandler returns the certificates with their expiry and the recent alerts
+func (b *Bench) CertificatesHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] CertificatesHandler called")
+	writeJSON(w, 200, map[string]interface{}{
+		"enabled":      b.tlsSettings().Enabled,
+		"certificates": b.Certificates(),
+		"alerts":       b.CertificateAlerts(),
+	})
+}
+
+// RenewCertificatesHandler issues missing and expiring certificates now,
+// failing names included
+func (b *Bench) RenewCertificatesHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] RenewCertificatesHandler called")
+	if !b.tlsSettings().Enabled {
+		writeError(w, 409, "tls is not enabled in instance.json")
+		return
+	}
+	if err := b.RenewCertificates(r.Context(), true); err != nil {
+		writeJSON(w, jobStatus(err), map[string]interface{}{"error": err.Error(), "certificates": b.Certificates()})
+		return
+	}
+	writeJSON(w, 200, map[string]interface{}{"certificates": b.Certificates()})
+}
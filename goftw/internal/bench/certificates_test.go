@@ -0,0 +1,99 @@
+package bench
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"goftw/internal/certs"
+	"goftw/internal/entity"
+)
+
+// TestRenewCertificates tests that local names get self-signed certificates,
+// failed ACME names are reported and valid certificates are kept
+func TestRenewCertificates(t *testing.T) {
+	benchPath := t.TempDir()
+	files := map[string]string{
+		"sites/a.localhost/site_config.json": `{"db_name": "a", "domains": ["shop.example.com"]}`,
+		"sites/b.localhost/site_config.json": `{"db_name": "b", "ssl_certificate": "/ssl/b.pem", "ssl_certificate_key": "/ssl/b.key"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(benchPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An ACME server that is gone, so public names fail
+	acmeServer := httptest.NewServer(nil)
+	acmeServer.Close()
+	b := &Bench{Path: benchPath, Instance: &entity.Instance{TLS: entity.TLS{Enabled: true, Directory: acmeServer.URL + "/dir"}}}
+	store := certs.Store{Dir: filepath.Join(benchPath, "config", "certs")}
+
+	renewed, err := b.renewCertificates(context.Background(), store, false)
+	if err == nil {
+		t.Fatalf("EXPECTED an error for shop.example.com GOT nil")
+	}
+	if !reflect.DeepEqual(renewed, []string{"a.localhost"}) {
+		t.Fatalf("EXPECTED [a.localhost] renewed GOT %v", renewed)
+	}
+	statuses := b.Certificates()
+	if len(statuses) != 2 {
+		t.Fatalf("EXPECTED 2 managed names GOT %+v", statuses)
+	}
+	if a := statuses[0]; a.Name != "a.localhost" || a.Issuer != "self-signed" || a.NotAfter == nil || a.RenewedAt == nil || a.Error != "" {
+		t.Fatalf("EXPECTED a self-signed a.localhost GOT %+v", a)
+	}
+	if shop := statuses[1]; shop.Name != "shop.example.com" || shop.Issuer != "acme" || shop.Site != "a.localhost" || shop.Error == "" || shop.NotAfter != nil {
+		t.Fatalf("EXPECTED a failed acme shop.example.com GOT %+v", shop)
+	}
+	if shop := statuses[1]; shop.Failures != 1 || shop.AttemptedAt == nil || shop.RetryAt == nil || !shop.RetryAt.Equal(shop.AttemptedAt.Add(retryBackoff)) {
+		t.Fatalf("EXPECTED shop.example.com to retry %s after its attempt GOT %+v", retryBackoff, shop)
+	}
+
+	// A valid certificate is not issued again, and a failing name waits for its backoff
+	renewed, err = b.renewCertificates(context.Background(), store, false)
+	if len(renewed) != 0 || err != nil {
+		t.Fatalf("EXPECTED nothing renewed or tried GOT %v, %v", renewed, err)
+	}
+	if a := b.Certificates()[0]; a.RenewedAt == nil || a.Certificate == "" {
+		t.Fatalf("EXPECTED a.localhost to keep its certificate GOT %+v", a)
+	}
+	if shop := b.Certificates()[1]; shop.Failures != 1 {
+		t.Fatalf("EXPECTED shop.example.com not tried again GOT %d failures", shop.Failures)
+	}
+
+	// Unless failing names are retried on purpose
+	if _, err := b.renewCertificates(context.Background(), store, true); err == nil {
+		t.Fatalf("EXPECTED an error for shop.example.com GOT nil")
+	}
+	if shop := b.Certificates()[1]; shop.Failures != 2 || !shop.RetryAt.Equal(shop.AttemptedAt.Add(2*retryBackoff)) {
+		t.Fatalf("EXPECTED shop.example.com to back off twice as long GOT %+v", shop)
+	}
+}
+
+// TestBackoff tests that the wait doubles with every failure up to maxRetryBackoff
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{4, 40 * time.Minute},
+		{7, 320 * time.Minute},
+		{8, 6 * time.Hour},
+		{1000, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.failures); got != tt.expected {
+			t.Fatalf("EXPECTED %s after %d failures GOT %s", tt.expected, tt.failures, got)
+		}
+	}
+}
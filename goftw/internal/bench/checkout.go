@@ -3,12 +3,40 @@ package bench
 import (
 	"fmt"
 	"goftw/internal/entity"
+	"goftw/internal/metrics"
 	"goftw/internal/utils"
 	"os"
 	"path/filepath"
-	"sort"
+	"time"
 )
 
+// Reconcile runs CheckoutSites and records its outcome for status reporting
+func (b *Bench) Reconcile(instanceCfg *entity.Instance, dbRootUser, dbRootPass string) error {
+	status := &entity.ReconcileStatus{StartedAt: time.Now()}
+	err := b.runJob("reconcile", func() error {
+		return b.CheckoutSites(instanceCfg, dbRootUser, dbRootPass)
+	})
+	status.FinishedAt = time.Now()
+	status.OK = err == nil
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		metrics.LastReconcileSuccess.Set(float64(status.FinishedAt.Unix()))
+	}
+
+	b.mu.Lock()
+	b.lastReconcile = status
+	b.mu.Unlock()
+	return err
+}
+
+// LastReconcile returns the outcome of the last reconcile, or nil if none ran
+func (b *Bench) LastReconcile() *entity.ReconcileStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastReconcile
+}
+
 // CheckoutSites orchestrates all site operations
 func (b *Bench) CheckoutSites(instanceCfg *entity.Instance, dbRootUser, dbRootPass string) error {
 	currentSites, err := b.ListSites()
@@ -34,6 +62,13 @@ func (b *Bench) CheckoutSites(instanceCfg *entity.Instance, dbRootUser, dbRootPa
 
 // CheckoutSite ensures a site exists and is properly configured.
 func (b *Bench) CheckoutSite(site entity.Site, dbRootUser, dbRootPass string) error {
+	// Ensure apps and their dependencies exist locally in bench/apps, in install order
+	expectedApps, err := b.PlanAppInstall(site.Apps)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to resolve apps for site %s: %v\n", site.SiteName, err)
+		return err
+	}
+
 	if _, err := os.Stat(filepath.Join(b.Path, "sites", site.SiteName)); os.IsNotExist(err) {
 		fmt.Printf("[SITES] Creating: %s\n", site.SiteName)
 		if err := b.NewSite(site.SiteName, dbRootUser, dbRootPass); err != nil {
@@ -42,12 +77,6 @@ func (b *Bench) CheckoutSite(site entity.Site, dbRootUser, dbRootPass string) er
 		}
 	}
 
-	// Ensure apps exist locally in bench/apps
-	if err := b.fetchMissingApps(site); err != nil {
-		fmt.Printf("[ERROR] Failed to fetch missing apps for site %s: %v\n", site.SiteName, err)
-		return err
-	}
-
 	// Get current apps (parsed and normalized)
 	currentAppsInfo, err := b.ListAppsOnSite(site.SiteName)
 	if err != nil {
@@ -56,18 +85,16 @@ func (b *Bench) CheckoutSite(site entity.Site, dbRootUser, dbRootPass string) er
 	}
 
 	currentAppNames := utils.ExtractAppNames(currentAppsInfo)
-	// Expected apps (from instance.json)
-	expectedApps := site.Apps
-
-	// Normalize order
-	sort.Strings(currentAppNames)
-	sort.Strings(expectedApps)
 
 	// Align apps
 	if err := b.installMissingApps(site.SiteName, expectedApps, currentAppNames); err != nil {
 		fmt.Printf("[ERROR] Failed to install missing apps for site %s: %v\n", site.SiteName, err)
 		return err
 	}
+	if err := b.checkoutDomains(site); err != nil {
+		fmt.Printf("[ERROR] Failed to add domains for site %s: %v\n", site.SiteName, err)
+		return err
+	}
 	// if err := b.uninstallExtraApps(site.SiteName, currentAppNames, expectedApps); err != nil {
 	// 	fmt.Printf("[ERROR] Failed to uninstall extra apps for site %s: %v\n", site.SiteName, err)
 	// 	return err
@@ -0,0 +1,113 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// normalizeAppRef turns a required_apps entry such as "erpnext", "frappe/erpnext"
+// or "https://github.com/frappe/erpnext.git" into an app name.
+func normalizeAppRef(ref string) string {
+	ref = strings.TrimSpace(ref)
+	ref = strings.TrimSuffix(ref, "/")
+	ref = strings.TrimSuffix(ref, ".git")
+	if i := strings.LastIndexAny(ref, "/:"); i != -1 {
+		ref = ref[i+1:]
+	}
+	return ref
+}
+
+// resolveInstallOrder returns apps and their transitive dependencies ordered so that
+// every app comes after the apps it requires. It fails on cycles and on dependencies
+// that cannot be resolved, before the caller installs anything.
+func resolveInstallOrder(apps []string, requires func(app string) ([]string, error)) ([]string, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var order []string
+	var path []string
+
+	var visit func(app, requiredBy string) error
+	visit = func(app, requiredBy string) error {
+		switch state[app] {
+		case done:
+			return nil
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == app {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), app)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		state[app] = visiting
+		path = append(path, app)
+
+		deps, err := requires(app)
+		if err != nil {
+			if requiredBy != "" {
+				return fmt.Errorf("missing dependency %s required by %s: %w", app, requiredBy, err)
+			}
+			return fmt.Errorf("missing app %s: %w", app, err)
+		}
+		for _, dep := range deps {
+			if err := visit(dep, app); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[app] = done
+		order = append(order, app)
+		return nil
+	}
+
+	for _, app := range apps {
+		if err := visit(app, ""); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// appRequirements fetches an app into the bench if missing and returns its required_apps
+func (b *Bench) appRequirements(app string) ([]string, error) {
+	if app == "frappe" {
+		return nil, nil
+	}
+
+	appPath := filepath.Join(b.Path, "apps", app)
+	if _, err := os.Stat(appPath); os.IsNotExist(err) {
+		fmt.Printf("[APPS] Fetching missing app: %s\n", app)
+		if err := b.GetApp(app); err != nil {
+			return nil, err
+		}
+	}
+
+	var deps []string
+	for _, ref := range ReadAppMeta(appPath, app).RequiredApps {
+		if dep := normalizeAppRef(ref); dep != "" {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, nil
+}
+
+// PlanAppInstall fetches any missing apps and their dependencies, then returns
+// the full set of apps in the order they must be installed.
+func (b *Bench) PlanAppInstall(apps []string) ([]string, error) {
+	order, err := resolveInstallOrder(apps, b.appRequirements)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[APPS] Install order: %v\n", order)
+	return order, nil
+}
@@ -0,0 +1,94 @@
+package bench
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// requiresFrom builds a requires func from a static graph; unknown apps are missing
+func requiresFrom(graph map[string][]string) func(string) ([]string, error) {
+	return func(app string) ([]string, error) {
+		deps, ok := graph[app]
+		if !ok {
+			return nil, fmt.Errorf("app %s not found", app)
+		}
+		return deps, nil
+	}
+}
+
+// TestResolveInstallOrder tests ordering, cycles and missing dependencies
+func TestResolveInstallOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		apps     []string
+		graph    map[string][]string
+		expected []string
+		errMatch string
+	}{
+		{
+			name:     "dependency listed after dependent",
+			apps:     []string{"frappe", "hrms", "erpnext"},
+			graph:    map[string][]string{"frappe": nil, "erpnext": nil, "hrms": {"erpnext"}},
+			expected: []string{"frappe", "erpnext", "hrms"},
+		},
+		{
+			name:     "transitive dependency not requested",
+			apps:     []string{"lending"},
+			graph:    map[string][]string{"lending": {"hrms"}, "hrms": {"erpnext"}, "erpnext": nil},
+			expected: []string{"erpnext", "hrms", "lending"},
+		},
+		{
+			name:     "shared dependency installed once",
+			apps:     []string{"hrms", "lending", "erpnext"},
+			graph:    map[string][]string{"hrms": {"erpnext"}, "lending": {"erpnext"}, "erpnext": nil},
+			expected: []string{"erpnext", "hrms", "lending"},
+		},
+		{
+			name:     "cycle",
+			apps:     []string{"a"},
+			graph:    map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}},
+			errMatch: "dependency cycle: b -> c -> b",
+		},
+		{
+			name:     "missing dependency",
+			apps:     []string{"hrms"},
+			graph:    map[string][]string{"hrms": {"erpnext"}},
+			errMatch: "missing dependency erpnext required by hrms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := resolveInstallOrder(tt.apps, requiresFrom(tt.graph))
+
+			if tt.errMatch != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+					t.Fatalf("EXPECTED ERROR %q, GOT: %v (order %v)", tt.errMatch, err, order)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UNEXPECTED ERROR: %v", err)
+			}
+			if !reflect.DeepEqual(order, tt.expected) {
+				t.Fatalf("ORDER MISMATCH\nEXPECTED: %v\nGOT:      %v", tt.expected, order)
+			}
+		})
+	}
+}
+
+// TestNormalizeAppRef tests the required_apps reference formats
+func TestNormalizeAppRef(t *testing.T) {
+	for ref, expected := range map[string]string{
+		"erpnext":                            "erpnext",
+		"frappe/erpnext":                     "erpnext",
+		"https://github.com/frappe/hrms.git": "hrms",
+		"git@github.com:frappe/lending.git":  "lending",
+	} {
+		if got := normalizeAppRef(ref); got != expected {
+			t.Fatalf("normalizeAppRef(%q) = %q, EXPECTED %q", ref, got, expected)
+		}
+	}
+}
@@ -1,21 +1,20 @@
 package bench
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"goftw/internal/environ"
 	"goftw/internal/whoiam"
 )
 
-var (
-	unmannedDeployment bool
-)
-
 // DeployThroughShell runs the /scripts/service.sh directly.
 // This is "unmanned" mode where Go should not attempt to control WSGI state.
 func (b *Bench) DeployThroughShell(deployMode string) {
-	unmannedDeployment = true
+	b.Deployment().setUnmanaged(deployMode)
 	os.Setenv("BENCH_DIR", environ.GetBenchPath())
 	os.Setenv("DEPLOYMENT", deployMode)
 	os.Setenv("MERGED_SUPERVISOR_CONF", "/supervisor-merged.conf")
@@ -24,35 +23,44 @@ func (b *Bench) DeployThroughShell(deployMode string) {
 	whoiam.ExecRunPrintIO("bash", "/scripts/service.sh")
 }
 
-// RestartDeployment restarts either production or development WSGI depending on state.
+// RestartDeployment restarts either production or development WSGI depending on
+// state. It runs as a job, so shutdown waits for it before stopping the process.
 func (b *Bench) RestartDeployment() error {
-	if unmannedDeployment {
-		return fmt.Errorf("cannot restart WSGI: unmanaged shell deployment active")
-	}
+	return b.runJob("restart", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		return b.Deployment().Restart(ctx)
+	})
+}
 
-	if productionCMD != nil {
-		// Hard restart supervisor + nginx
-		if err := b.TerminateSupervisorNginx(); err != nil {
-			fmt.Printf("[ERROR] Could not stop production: %v", err)
-		}
-		if err := b.RunSupervisorNginx(); err != nil {
-			return fmt.Errorf("failed to start production WSGI: %v", err)
-		}
-		fmt.Println("[WSGI] Production WSGI restarted (hard)")
+// StopDeployment stops the WSGI process goftw started, SIGTERM first and SIGKILL
+// once ctx is done. supervisord and bench start stop their own programs in order.
+func (b *Bench) StopDeployment(ctx context.Context) error {
+	d := b.Deployment()
+	if d.Status().Process == "service.sh" {
 		return nil
 	}
+	return d.Stop(ctx)
+}
 
-	if developmentCMD != nil {
-		// Hard restart bench start
-		if err := b.StopBench(); err != nil {
-			return fmt.Errorf("failed to stop development WSGI: %v", err)
-		}
-		if err := b.StartBench(); err != nil {
-			return fmt.Errorf("failed to start development WSGI: %v", err)
-		}
-		fmt.Println("[WSGI] Development WSGI restarted (hard)")
-		return nil
-	}
+// DeploymentProcess reports which WSGI process goftw started and whether it is still running
+func (b *Bench) DeploymentProcess() (string, bool) {
+	d := b.Deployment()
+	return d.Status().Process, d.Running()
+}
 
-	return fmt.Errorf("unknown WSGI state: neither production nor development running")
+// DeploymentHandler returns the state of the managed WSGI process
+func (b *Bench) DeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] DeploymentHandler called")
+	writeJSON(w, 200, b.Deployment().Status())
+}
+
+// RestartDeploymentHandler restarts the managed WSGI process, clearing a crash loop
+func (b *Bench) RestartDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] RestartDeploymentHandler called")
+	if err := b.RestartDeployment(); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to restart deployment: %v", err))
+		return
+	}
+	writeJSON(w, 200, b.Deployment().Status())
 }
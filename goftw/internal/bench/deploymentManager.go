@@ -0,0 +1,433 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"goftw/internal/entity"
+	"goftw/internal/metrics"
+)
+
+// Deployment states
+const (
+	StateStopped    = "stopped"    // never started, or stopped on request
+	StateStarting   = "starting"   // generating configs and spawning the process
+	StateRunning    = "running"    // process alive
+	StateExited     = "exited"     // process ended without being asked to
+	StateRestarting = "restarting" // stopping the old process to start a new one
+	StateCrashLoop  = "crash_loop" // automatic restarts gave up
+)
+
+// maxExitHistory is how many exits Status reports
+const maxExitHistory = 20
+
+// RestartPolicy controls automatic restarts after the process exits unexpectedly
+type RestartPolicy struct {
+	Enabled     bool
+	MinBackoff  time.Duration // delay before the first restart, doubled per consecutive crash
+	MaxBackoff  time.Duration
+	MaxCrashes  int           // consecutive crashes before giving up
+	StableAfter time.Duration // uptime after which a crash no longer counts as consecutive
+}
+
+// backoff returns the delay before restarting after the given consecutive crash
+func (p RestartPolicy) backoff(crashes int) time.Duration {
+	delay := p.MinBackoff
+	for i := 1; i < crashes && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxBackoff)
+}
+
+// Process is what the deployment manager runs: an exec'd command such as
+// supervisord, or the native supervisor inside goftw
+type Process interface {
+	Pid() int
+	Signal(sig os.Signal) error
+	Kill() error
+	// Wait blocks until the process has exited, returning its exit code and a
+	// reason like "exit status 1"
+	Wait() (code int, reason string, err error)
+}
+
+// execProcess adapts a started exec.Cmd to Process
+type execProcess struct{ cmd *exec.Cmd }
+
+// ExecProcess wraps a started command
+func ExecProcess(cmd *exec.Cmd) Process { return execProcess{cmd} }
+
+func (p execProcess) Pid() int                   { return p.cmd.Process.Pid }
+func (p execProcess) Signal(sig os.Signal) error { return p.cmd.Process.Signal(sig) }
+func (p execProcess) Kill() error                { return p.cmd.Process.Kill() }
+func (p execProcess) Wait() (int, string, error) {
+	err := p.cmd.Wait()
+	return p.cmd.ProcessState.ExitCode(), p.cmd.ProcessState.String(), err
+}
+
+// Deployment owns the WSGI child process (supervisord or bench start). It reaps
+// the process so crashes are noticed, and serializes start, stop and restart so
+// concurrent API calls cannot interleave them.
+type Deployment struct {
+	ops sync.Mutex // held for the whole of Start, Stop and Restart
+
+	mu          sync.Mutex // guards the fields below
+	mode        string
+	process     string
+	launch      func() (Process, error)
+	policy      RestartPolicy
+	cmd         Process
+	exited      chan struct{} // closed once cmd has been reaped
+	state       string
+	startedAt   time.Time
+	exitedAt    time.Time
+	exitCode    *int
+	lastErr     string
+	restarts    int
+	crashes     int // consecutive unexpected exits
+	exits       []entity.DeploymentExit
+	retry       *time.Timer // pending automatic restart
+	nextRestart time.Time
+	generation  int  // bumped on every start and stop so stale retries do nothing
+	stopping    bool // the next exit was requested
+	unmanaged   bool
+}
+
+// NewDeployment returns a stopped deployment
+func NewDeployment() *Deployment {
+	return &Deployment{state: StateStopped}
+}
+
+// Deployment returns the bench's deployment manager
+func (b *Bench) Deployment() *Deployment {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.deployment == nil {
+		b.deployment = NewDeployment()
+	}
+	return b.deployment
+}
+
+// SetRestartPolicy sets how unexpected exits are handled from now on
+func (d *Deployment) SetRestartPolicy(policy RestartPolicy) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.policy = policy
+}
+
+// setUnmanaged marks the deployment as run by service.sh, outside goftw's control
+func (d *Deployment) setUnmanaged(mode string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.unmanaged = true
+	d.mode = mode
+	d.process = "service.sh"
+	d.state = StateRunning
+}
+
+// Start launches the process with launch, remembering it for restarts
+func (d *Deployment) Start(mode, process string, launch func() (Process, error)) error {
+	d.ops.Lock()
+	defer d.ops.Unlock()
+
+	d.mu.Lock()
+	if d.unmanaged {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot start %s: unmanaged shell deployment active", process)
+	}
+	if d.cmd != nil {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot start %s: %s already running (PID: %d)", process, d.process, d.cmd.Pid())
+	}
+	d.mode, d.process, d.launch = mode, process, launch
+	d.crashes = 0
+	d.mu.Unlock()
+
+	return d.startLocked()
+}
+
+// startLocked launches the remembered process. Caller holds d.ops.
+func (d *Deployment) startLocked() error {
+	d.mu.Lock()
+	d.cancelRetry()
+	launch, process := d.launch, d.process
+	d.state = StateStarting
+	d.mu.Unlock()
+
+	cmd, err := launch()
+	if err != nil {
+		d.mu.Lock()
+		d.exitedAt = time.Now()
+		d.lastErr = err.Error()
+		d.recordExit(entity.DeploymentExit{At: d.exitedAt, Reason: "start failed: " + err.Error()})
+		d.crashed(0)
+		d.mu.Unlock()
+		return err
+	}
+
+	exited := make(chan struct{})
+	d.mu.Lock()
+	d.cmd = cmd
+	d.exited = exited
+	d.state = StateRunning
+	d.startedAt = time.Now()
+	d.exitCode = nil
+	d.lastErr = ""
+	d.stopping = false
+	d.mu.Unlock()
+
+	fmt.Printf("[WSGI] %s started (PID: %d)\n", process, cmd.Pid())
+	go d.reap(cmd, exited)
+	return nil
+}
+
+// reap waits for cmd and records how it ended
+func (d *Deployment) reap(cmd Process, exited chan struct{}) {
+	code, reason, err := cmd.Wait()
+
+	d.mu.Lock()
+	d.exitCode = &code
+	d.exitedAt = time.Now()
+	d.cmd = nil
+	if err != nil {
+		d.lastErr = err.Error()
+	}
+	uptime := d.exitedAt.Sub(d.startedAt)
+	exit := entity.DeploymentExit{
+		At:            d.exitedAt,
+		Code:          code,
+		Reason:        reason,
+		UptimeSeconds: uptime.Seconds(),
+		Requested:     d.stopping,
+	}
+	d.recordExit(exit)
+	if d.stopping {
+		d.state = StateStopped
+	} else {
+		fmt.Printf("[WSGI] %s exited unexpectedly after %s: %s\n", d.process, uptime.Round(time.Second), exit.Reason)
+		d.crashed(uptime)
+	}
+	d.mu.Unlock()
+	close(exited)
+}
+
+// recordExit appends exit to the bounded history. Caller holds d.mu.
+func (d *Deployment) recordExit(exit entity.DeploymentExit) {
+	d.exits = append(d.exits, exit)
+	if len(d.exits) > maxExitHistory {
+		d.exits = d.exits[len(d.exits)-maxExitHistory:]
+	}
+}
+
+// crashed handles an unexpected exit after uptime, scheduling a restart when
+// the policy allows one. Caller holds d.mu.
+func (d *Deployment) crashed(uptime time.Duration) {
+	d.state = StateExited
+	if !d.policy.Enabled {
+		return
+	}
+	if uptime >= d.policy.StableAfter {
+		d.crashes = 0
+	}
+	d.crashes++
+	if d.crashes > d.policy.MaxCrashes {
+		d.state = StateCrashLoop
+		fmt.Printf("[WSGI] %s crashed %d times in a row, giving up until restarted manually\n", d.process, d.crashes)
+		return
+	}
+
+	delay := d.policy.backoff(d.crashes)
+	generation := d.generation
+	d.nextRestart = time.Now().Add(delay)
+	d.retry = time.AfterFunc(delay, func() { d.autoRestart(generation) })
+	fmt.Printf("[WSGI] Restarting %s in %s (attempt %d/%d)\n", d.process, delay, d.crashes, d.policy.MaxCrashes)
+}
+
+// cancelRetry drops a pending automatic restart. Caller holds d.mu.
+func (d *Deployment) cancelRetry() {
+	d.generation++
+	if d.retry != nil {
+		d.retry.Stop()
+		d.retry = nil
+	}
+	d.nextRestart = time.Time{}
+}
+
+// autoRestart starts the process again unless it was started or stopped since
+// the restart was scheduled
+func (d *Deployment) autoRestart(generation int) {
+	d.ops.Lock()
+	defer d.ops.Unlock()
+
+	d.mu.Lock()
+	if generation != d.generation || d.cmd != nil {
+		d.mu.Unlock()
+		return
+	}
+	process := d.process
+	d.restarts++
+	d.mu.Unlock()
+
+	metrics.ProcessRestarts.Inc(process)
+	if err := d.startLocked(); err != nil {
+		fmt.Printf("[ERROR] Automatic restart of %s failed: %v\n", process, err)
+	}
+}
+
+// Stop sends SIGTERM and waits for the process to be reaped, sending SIGKILL
+// once ctx is done. Stopping a stopped deployment is a no-op.
+func (d *Deployment) Stop(ctx context.Context) error {
+	d.ops.Lock()
+	defer d.ops.Unlock()
+	return d.stopLocked(ctx)
+}
+
+// stopLocked stops the current process. Caller holds d.ops.
+func (d *Deployment) stopLocked(ctx context.Context) error {
+	d.mu.Lock()
+	if d.unmanaged {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot stop WSGI: unmanaged shell deployment active")
+	}
+	d.cancelRetry()
+	cmd, exited, process := d.cmd, d.exited, d.process
+	if cmd == nil {
+		if d.state == StateExited || d.state == StateCrashLoop {
+			d.state = StateStopped
+		}
+		d.mu.Unlock()
+		return nil
+	}
+	d.stopping = true
+	d.mu.Unlock()
+
+	fmt.Printf("[WSGI] Stopping %s (PID: %d)\n", process, cmd.Pid())
+	if err := cmd.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return fmt.Errorf("failed to signal %s: %v", process, err)
+	}
+
+	select {
+	case <-exited:
+		fmt.Printf("[WSGI] %s stopped\n", process)
+		return nil
+	case <-ctx.Done():
+		fmt.Printf("[WSGI] %s did not exit in time, killing\n", process)
+		_ = cmd.Kill()
+		<-exited
+		return fmt.Errorf("%s killed after stop deadline", process)
+	}
+}
+
+// Restart stops the current process and starts it again with the same launcher.
+// It also clears a crash loop.
+func (d *Deployment) Restart(ctx context.Context) error {
+	d.ops.Lock()
+	defer d.ops.Unlock()
+	return d.restartLocked(ctx)
+}
+
+// Reload runs reload while no start, stop or restart can interleave with it.
+// reload returns restart=true when only a full restart can apply the change,
+// or when the process is not running to be reloaded.
+func (d *Deployment) Reload(ctx context.Context, reload func(ctx context.Context) (restart bool, err error)) error {
+	d.ops.Lock()
+	defer d.ops.Unlock()
+
+	d.mu.Lock()
+	if d.unmanaged {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot reload WSGI: unmanaged shell deployment active")
+	}
+	if d.launch == nil {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot reload WSGI: deployment was never started")
+	}
+	running := d.cmd != nil
+	d.mu.Unlock()
+
+	restart := !running
+	if running {
+		var err error
+		if restart, err = reload(ctx); err != nil {
+			return err
+		}
+	}
+	if restart {
+		return d.restartLocked(ctx)
+	}
+	return nil
+}
+
+// restartLocked is Restart. Caller holds d.ops.
+func (d *Deployment) restartLocked(ctx context.Context) error {
+	d.mu.Lock()
+	if d.unmanaged {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot restart WSGI: unmanaged shell deployment active")
+	}
+	if d.launch == nil {
+		d.mu.Unlock()
+		return fmt.Errorf("cannot restart WSGI: deployment was never started")
+	}
+	process := d.process
+	d.state = StateRestarting
+	d.restarts++
+	d.crashes = 0
+	d.mu.Unlock()
+
+	metrics.ProcessRestarts.Inc(process)
+	if err := d.stopLocked(ctx); err != nil {
+		fmt.Printf("[ERROR] Could not stop %s cleanly: %v\n", process, err)
+	}
+	if err := d.startLocked(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", process, err)
+	}
+	fmt.Printf("[WSGI] %s restarted (hard)\n", process)
+	return nil
+}
+
+// Running reports whether the process is alive, or run by service.sh
+func (d *Deployment) Running() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.unmanaged || d.cmd != nil
+}
+
+// Status returns a snapshot of the deployment state
+func (d *Deployment) Status() entity.DeploymentStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := entity.DeploymentStatus{
+		Mode:               d.mode,
+		Process:            d.process,
+		State:              d.state,
+		ExitCode:           d.exitCode,
+		Error:              d.lastErr,
+		Restarts:           d.restarts,
+		ConsecutiveCrashes: d.crashes,
+		AutoRestart:        d.policy.Enabled,
+		Exits:              append([]entity.DeploymentExit(nil), d.exits...),
+	}
+	if d.cmd != nil {
+		status.PID = d.cmd.Pid()
+	}
+	if !d.startedAt.IsZero() {
+		startedAt := d.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !d.exitedAt.IsZero() {
+		exitedAt := d.exitedAt
+		status.ExitedAt = &exitedAt
+	}
+	if !d.nextRestart.IsZero() {
+		nextRestart := d.nextRestart
+		status.NextRestart = &nextRestart
+	}
+	return status
+}
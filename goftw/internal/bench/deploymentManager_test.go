@@ -0,0 +1,209 @@
+package bench
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shLauncher returns a launcher running script under sh
+func shLauncher(script string) func() (Process, error) {
+	return func() (Process, error) {
+		cmd := exec.Command("sh", "-c", script)
+		return ExecProcess(cmd), cmd.Start()
+	}
+}
+
+// waitForState polls d until it reaches state or the test times out
+func waitForState(t *testing.T, d *Deployment, state string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if d.Status().State == state {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("EXPECTED state %q GOT %q", state, d.Status().State)
+}
+
+// TestDeploymentReapsCrash tests that an unexpected exit is recorded with its code
+func TestDeploymentReapsCrash(t *testing.T) {
+	d := NewDeployment()
+	if err := d.Start("development", "bench start", shLauncher("exit 3")); err != nil {
+		t.Fatalf("EXPECTED start to succeed GOT %v", err)
+	}
+	waitForState(t, d, StateExited)
+
+	status := d.Status()
+	if status.ExitCode == nil || *status.ExitCode != 3 {
+		t.Fatalf("EXPECTED exit code 3 GOT %v", status.ExitCode)
+	}
+	if d.Running() {
+		t.Fatalf("EXPECTED crashed deployment not to be running")
+	}
+}
+
+// TestDeploymentStopAndRestart tests serialized restarts and a requested stop
+func TestDeploymentStopAndRestart(t *testing.T) {
+	d := NewDeployment()
+	if err := d.Start("production", "supervisord", shLauncher("sleep 30")); err != nil {
+		t.Fatalf("EXPECTED start to succeed GOT %v", err)
+	}
+	if err := d.Start("production", "supervisord", shLauncher("sleep 30")); err == nil {
+		t.Fatalf("EXPECTED second start to fail while running")
+	}
+	firstPID := d.Status().PID
+
+	// Concurrent restarts are serialized and leave exactly one process running
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.Restart(ctx); err != nil {
+				t.Errorf("EXPECTED restart to succeed GOT %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := d.Status()
+	if status.State != StateRunning || status.PID == 0 || status.PID == firstPID {
+		t.Fatalf("EXPECTED a new running process GOT %+v", status)
+	}
+	if status.Restarts != 4 {
+		t.Fatalf("EXPECTED 4 restarts GOT %d", status.Restarts)
+	}
+
+	if err := d.Stop(ctx); err != nil {
+		t.Fatalf("EXPECTED stop to succeed GOT %v", err)
+	}
+	if status := d.Status(); status.State != StateStopped || status.PID != 0 {
+		t.Fatalf("EXPECTED stopped deployment GOT %+v", status)
+	}
+}
+
+// TestDeploymentUnmanaged tests that service.sh deployments are left alone
+func TestDeploymentUnmanaged(t *testing.T) {
+	d := NewDeployment()
+	d.setUnmanaged("production")
+	if err := d.Restart(context.Background()); err == nil {
+		t.Fatalf("EXPECTED restart of unmanaged deployment to fail")
+	}
+	if !d.Running() {
+		t.Fatalf("EXPECTED unmanaged deployment to report running")
+	}
+}
+
+// TestRestartPolicyBackoff tests the exponential backoff and its cap
+func TestRestartPolicyBackoff(t *testing.T) {
+	policy := RestartPolicy{MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		crashes  int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.backoff(tt.crashes); got != tt.expected {
+			t.Fatalf("EXPECTED backoff %s after %d crashes GOT %s", tt.expected, tt.crashes, got)
+		}
+	}
+}
+
+// TestDeploymentCrashLoop tests automatic restarts up to the crash limit and a manual restart after it
+func TestDeploymentCrashLoop(t *testing.T) {
+	d := NewDeployment()
+	d.SetRestartPolicy(RestartPolicy{
+		Enabled:     true,
+		MinBackoff:  10 * time.Millisecond,
+		MaxBackoff:  40 * time.Millisecond,
+		MaxCrashes:  3,
+		StableAfter: time.Minute,
+	})
+	if err := d.Start("development", "bench start", shLauncher("exit 1")); err != nil {
+		t.Fatalf("EXPECTED start to succeed GOT %v", err)
+	}
+	waitForState(t, d, StateCrashLoop)
+
+	status := d.Status()
+	if status.Restarts != 3 {
+		t.Fatalf("EXPECTED 3 automatic restarts GOT %d", status.Restarts)
+	}
+	if len(status.Exits) != 4 || status.Exits[0].Reason != "exit status 1" || status.Exits[0].Requested {
+		t.Fatalf("EXPECTED 4 recorded crashes with reason GOT %+v", status.Exits)
+	}
+	if status.NextRestart != nil {
+		t.Fatalf("EXPECTED no restart scheduled in a crash loop GOT %v", status.NextRestart)
+	}
+
+	// A manual restart clears the crash loop
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Restart(ctx); err != nil {
+		t.Fatalf("EXPECTED manual restart to succeed GOT %v", err)
+	}
+	if status := d.Status(); status.ConsecutiveCrashes > 1 {
+		t.Fatalf("EXPECTED crash count reset GOT %d", status.ConsecutiveCrashes)
+	}
+	if err := d.Stop(ctx); err != nil {
+		t.Fatalf("EXPECTED stop to succeed GOT %v", err)
+	}
+	if status := d.Status(); status.State != StateStopped || status.NextRestart != nil {
+		t.Fatalf("EXPECTED stop to cancel pending restarts GOT %+v", status)
+	}
+}
+
+// TestRestartDeploymentIsAJob tests that shutdown waits for a running restart
+// and refuses one that starts after it
+func TestRestartDeploymentIsAJob(t *testing.T) {
+	b := &Bench{}
+	launches := 0
+	entered, release := make(chan struct{}), make(chan struct{})
+	launch := func() (Process, error) {
+		if launches++; launches == 2 {
+			close(entered)
+			<-release
+		}
+		return shLauncher("sleep 30")()
+	}
+	if err := b.Deployment().Start("production", "supervisord", launch); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Deployment().Stop(context.Background())
+
+	restarted := make(chan error, 1)
+	go func() { restarted <- b.RestartDeployment() }()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := b.WaitForJobs(ctx); err == nil {
+		t.Fatal("EXPECTED shutdown to wait for the restart GOT nil")
+	}
+	close(release)
+	if err := <-restarted; err != nil {
+		t.Fatalf("EXPECTED the running restart to finish GOT %v", err)
+	}
+	if err := b.WaitForJobs(context.Background()); err != nil {
+		t.Fatalf("EXPECTED no running jobs GOT %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	b.RestartDeploymentHandler(rec, httptest.NewRequest(http.MethodPost, "/api/goftw/deployment/restart", nil))
+	if rec.Code != 503 || launches != 2 {
+		t.Fatalf("EXPECTED 503 without another launch GOT %d after %d launches", rec.Code, launches)
+	}
+}
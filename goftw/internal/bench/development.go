@@ -1,48 +1,68 @@
 package bench
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
-	"syscall"
-)
+	"strconv"
+	"time"
 
-var (
-	developmentCMD *exec.Cmd
+	"goftw/internal/environ"
 )
 
 // StartBench starts the bench in development mode (`bench start`) without blocking.
+// A crashed `bench start` is restarted with backoff per DevelopmentRestartPolicy.
 func (b *Bench) StartBench() error {
-	if unmannedDeployment {
-		return fmt.Errorf("cannot start development WSGI: unmanaged shell deployment active")
-	}
-	if developmentCMD != nil {
-		fmt.Printf("[ERROR] Development process already running")
-	}
 	fmt.Printf("[MODE] DEVELOPMENT\n")
-	// benchDir := environ.GetBenchPath()
+	d := b.Deployment()
+	d.SetRestartPolicy(DevelopmentRestartPolicy())
+	return d.Start("development", "bench start", b.startBenchProcess)
+}
 
-	developmentCMD, err := b.ExecStartInBenchPrintIO("bench", "start")
-	// Start without waiting (non-blocking)
+// DevelopmentRestartPolicy reads the `bench start` restart policy from
+// GOFTW_DEV_RESTART, GOFTW_DEV_RESTART_BACKOFF, GOFTW_DEV_RESTART_MAX_BACKOFF,
+// GOFTW_DEV_RESTART_MAX_CRASHES and GOFTW_DEV_RESTART_STABLE_AFTER.
+func DevelopmentRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		Enabled:     environ.GetEnv("GOFTW_DEV_RESTART", "1") != "0",
+		MinBackoff:  envDuration("GOFTW_DEV_RESTART_BACKOFF", time.Second),
+		MaxBackoff:  envDuration("GOFTW_DEV_RESTART_MAX_BACKOFF", time.Minute),
+		MaxCrashes:  envInt("GOFTW_DEV_RESTART_MAX_CRASHES", 5),
+		StableAfter: envDuration("GOFTW_DEV_RESTART_STABLE_AFTER", 2*time.Minute),
+	}
+}
+
+// envDuration parses key as a duration, falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(environ.GetEnv(key, def.String()))
 	if err != nil {
-		return fmt.Errorf("failed to start bench: %v", err)
+		fmt.Printf("[WARN] Invalid %s, using %s: %v\n", key, def, err)
+		return def
 	}
+	return value
+}
 
-	fmt.Printf("[DEV] Bench started (PID: %d)\n", developmentCMD.Process.Pid)
+// envInt parses key as an integer, falling back to def
+func envInt(key string, def int) int {
+	value, err := strconv.Atoi(environ.GetEnv(key, strconv.Itoa(def)))
+	if err != nil {
+		fmt.Printf("[WARN] Invalid %s, using %d: %v\n", key, def, err)
+		return def
+	}
+	return value
+}
 
-	return nil
+// startBenchProcess spawns `bench start` in the bench directory
+func (b *Bench) startBenchProcess() (Process, error) {
+	cmd, err := b.ExecStartInBenchPrintIO("bench", "start")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start bench: %v", err)
+	}
+	return ExecProcess(cmd), nil
 }
 
 // StopBench stops the bench process if running.
 func (b *Bench) StopBench() error {
-	if developmentCMD == nil {
-		return fmt.Errorf("cannot stop development WSGI: unmanaged shell deployment active")
-	}
-	if developmentCMD != nil && developmentCMD.Process != nil {
-		if err := productionCMD.Process.Signal(syscall.SIGTERM); err != nil {
-			return fmt.Errorf("failed to stop develeopment WSGI: %v", err)
-		}
-		developmentCMD = nil
-	}
-	fmt.Println("[WSGI] Development WSGI stopped (bench terminated)")
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return b.Deployment().Stop(ctx)
 }
@@ -0,0 +1,264 @@
+package bench
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"goftw/internal/entity"
+	"goftw/internal/environ"
+	"goftw/internal/nginx"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var domainRegex = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]$`)
+
+// SiteDomains returns the custom domains of a site's site_config.json
+func (b *Bench) SiteDomains(site string) ([]entity.SiteDomain, error) {
+	cfg, err := entity.LoadSiteConfig(filepath.Join(b.Path, "sites", site, "site_config.json"))
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Domains, nil
+}
+
+// hasDomain reports whether domain is one of domains
+func hasDomain(domains []entity.SiteDomain, domain string) bool {
+	return slices.ContainsFunc(domains, func(d entity.SiteDomain) bool { return d.Domain == domain })
+}
+
+// checkDomain refuses a domain nginx could not serve for site: a malformed
+// name, or one already served by another site
+func (b *Bench) checkDomain(site, domain string) error {
+	if !domainRegex.MatchString(domain) {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	cfg, err := b.nginxConfig(b.ServerName)
+	if err != nil {
+		return err
+	}
+	for i := range cfg.Sites {
+		if cfg.Sites[i].Name == site {
+			cfg.Sites[i].Domains = append(cfg.Sites[i].Domains, entity.SiteDomain{Domain: domain})
+		}
+	}
+	if _, err := nginx.Render(cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddDomain adds a custom domain to a site with `bench setup add-domain`
+func (b *Bench) AddDomain(site, domain string) error {
+	return b.runJob("domain_add", func() error {
+		return b.ExecRunInBenchPrintIO("bench", "setup", "add-domain", domain, "--site", site)
+	})
+}
+
+// RemoveDomain removes a custom domain from a site with `bench setup remove-domain`
+func (b *Bench) RemoveDomain(site, domain string) error {
+	return b.runJob("domain_remove", func() error {
+		return b.ExecRunInBenchPrintIO("bench", "setup", "remove-domain", domain, "--site", site)
+	})
+}
+
+// setInstanceDomain adds or removes domain from the site's instance.json
+// entry, so a reconcile restores it. Only the site's domains are rewritten in
+// the file. Sites not listed are left alone.
+func (b *Bench) setInstanceDomain(site, domain string, add bool) {
+	b.mu.Lock()
+	if b.Instance == nil {
+		b.mu.Unlock()
+		return
+	}
+	var domains []string
+	listed := false
+	for i, s := range b.Instance.Sites {
+		if s.SiteName != site {
+			continue
+		}
+		listed = true
+		domains = slices.DeleteFunc(slices.Clone(s.Domains), func(d string) bool { return d == domain })
+		if add {
+			domains = append(domains, domain)
+		}
+		b.Instance.Sites[i].Domains = domains
+	}
+	b.mu.Unlock()
+	if !listed {
+		return
+	}
+
+	// No domains left removes the key, as instance.json omits empty domains
+	var value interface{}
+	if len(domains) > 0 {
+		value = domains
+	}
+	if err := entity.SetInstanceSiteKey(environ.GetInstanceFile(), site, "domains", value); err != nil {
+		fmt.Printf("[WARN] Domains of %s changed but not saved to %s: %v\n", site, environ.GetInstanceFile(), err)
+	}
+}
+
+// checkoutDomains adds the domains instance.json lists for a site and it lacks
+func (b *Bench) checkoutDomains(site entity.Site) error {
+	if len(site.Domains) == 0 {
+		return nil
+	}
+	current, err := b.SiteDomains(site.SiteName)
+	if err != nil {
+		return err
+	}
+	for _, domain := range site.Domains {
+		if hasDomain(current, domain) {
+			continue
+		}
+		fmt.Printf("[SITES] Adding domain %s to %s\n", domain, site.SiteName)
+		if err := b.AddDomain(site.SiteName, domain); err != nil {
+			return fmt.Errorf("failed to add domain %s: %v", domain, err)
+		}
+	}
+	return nil
+}
+
+// domainRequest reads the domain of a domains request from the JSON body, or
+// the domain query parameter for clients that send no body with DELETE
+func domainRequest(r *http.Request) (string, error) {
+	if domain := r.URL.Query().Get("domain"); domain != "" {
+		return strings.ToLower(domain), nil
+	}
+	var body struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid JSON body")
+	}
+	if body.Domain == "" {
+		return "", fmt.Errorf("no domain")
+	}
+	return strings.ToLower(body.Domain), nil
+}
+
+// AddDomainHandler adds a custom domain to a site and reloads nginx
+func (b *Bench) AddDomainHandler(w http.ResponseWriter, r *http.Request) {
+	siteName := chi.URLParam(r, "name")
+	fmt.Printf("[API] AddDomainHandler called for site: %s\n", siteName)
+	if !b.siteExists(siteName) {
+		writeError(w, 404, "site not found")
+		return
+	}
+	domain, err := domainRequest(r)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	domains, err := b.SiteDomains(siteName)
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to read site domains: %v", err))
+		return
+	}
+	if hasDomain(domains, domain) {
+		writeError(w, 409, fmt.Sprintf("%s is already a domain of %s", domain, siteName))
+		return
+	}
+	if err := b.checkDomain(siteName, domain); err != nil {
+		writeError(w, 422, err.Error())
+		return
+	}
+
+	if err := b.AddDomain(siteName, domain); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to add domain: %v", err))
+		return
+	}
+	ok, reloadErr := b.reloadDomains(w, siteName, func() error { return b.RemoveDomain(siteName, domain) })
+	if !ok {
+		return
+	}
+	b.setInstanceDomain(siteName, domain, true)
+	b.renewCertificatesAsync()
+	b.writeDomains(w, 201, siteName, reloadErr)
+}
+
+// RemoveDomainHandler removes a custom domain from a site and reloads nginx
+func (b *Bench) RemoveDomainHandler(w http.ResponseWriter, r *http.Request) {
+	siteName := chi.URLParam(r, "name")
+	fmt.Printf("[API] RemoveDomainHandler called for site: %s\n", siteName)
+	if !b.siteExists(siteName) {
+		writeError(w, 404, "site not found")
+		return
+	}
+	domain, err := domainRequest(r)
+	if err != nil {
+		writeError(w, 400, err.Error())
+		return
+	}
+	domains, err := b.SiteDomains(siteName)
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to read site domains: %v", err))
+		return
+	}
+	if !hasDomain(domains, domain) {
+		writeError(w, 404, fmt.Sprintf("%s is not a domain of %s", domain, siteName))
+		return
+	}
+
+	if err := b.RemoveDomain(siteName, domain); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to remove domain: %v", err))
+		return
+	}
+	ok, reloadErr := b.reloadDomains(w, siteName, func() error { return b.AddDomain(siteName, domain) })
+	if !ok {
+		return
+	}
+	b.setInstanceDomain(siteName, domain, false)
+	b.writeDomains(w, 200, siteName, reloadErr)
+}
+
+// reloadDomains reloads the deployment after a domain change. When nginx
+// rejects the new config the change is undone and 422 written; nginx keeps
+// serving the previous config meanwhile. Other reload failures are returned
+// for the response, like site creation reports them.
+func (b *Bench) reloadDomains(w http.ResponseWriter, siteName string, undo func() error) (bool, error) {
+	err := b.ReloadDeployment()
+	var configErr *NginxConfigError
+	if !errors.As(err, &configErr) {
+		if err != nil {
+			fmt.Printf("[ERROR] Deployment reload failed: %v\n", err)
+		}
+		return true, err
+	}
+	fmt.Printf("[ERROR] nginx rejected the domains of %s, undoing the change: %v\n", siteName, err)
+	if err := undo(); err != nil {
+		fmt.Printf("[ERROR] Failed to undo the domain change of %s: %v\n", siteName, err)
+	}
+	writeJSON(w, 422, map[string]interface{}{"error": "nginx rejected the new config", "nginx": b.NginxStatus()})
+	return false, nil
+}
+
+// writeDomains writes the site's domains after a change
+func (b *Bench) writeDomains(w http.ResponseWriter, status int, siteName string, reloadErr error) {
+	domains, err := b.SiteDomains(siteName)
+	if err != nil {
+		writeError(w, 500, fmt.Sprintf("failed to read site domains: %v", err))
+		return
+	}
+	resp := map[string]interface{}{"site": siteName, "domains": domainNames(domains)}
+	if reloadErr != nil {
+		resp["reload_error"] = reloadErr.Error()
+	}
+	writeJSON(w, status, resp)
+}
+
+// domainNames returns the names of domains, never nil so it encodes as []
+func domainNames(domains []entity.SiteDomain) []string {
+	names := make([]string, 0, len(domains))
+	for _, d := range domains {
+		names = append(names, d.Domain)
+	}
+	return names
+}
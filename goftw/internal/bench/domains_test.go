@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestCheckDomain tests that malformed domains and names of other sites are refused
+func TestCheckDomain(t *testing.T) {
+	benchPath := t.TempDir()
+	files := map[string]string{
+		"sites/a.localhost/site_config.json": `{"db_name": "a", "domains": ["shop.com"]}`,
+		"sites/b.localhost/site_config.json": `{"db_name": "b"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(benchPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := &Bench{Name: "frappe-bench", Path: benchPath}
+
+	tests := []struct {
+		site    string
+		domain  string
+		wantErr bool
+	}{
+		{site: "b.localhost", domain: "erp.example.com", wantErr: false},
+		{site: "a.localhost", domain: "www.shop.com", wantErr: false},
+		{site: "b.localhost", domain: "shop.com", wantErr: true},
+		{site: "b.localhost", domain: "a.localhost", wantErr: true},
+		{site: "b.localhost", domain: "example", wantErr: true},
+		{site: "b.localhost", domain: "shop.com;", wantErr: true},
+		{site: "b.localhost", domain: "-shop.com", wantErr: true},
+		{site: "b.localhost", domain: "Shop.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := b.checkDomain(tt.site, tt.domain)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("EXPECTED error %v for %s on %s GOT %v", tt.wantErr, tt.domain, tt.site, err)
+		}
+	}
+}
+
+// TestAddDomainRejectedByNginx tests that a domain nginx -t rejects is removed
+// again and the request answers 422
+func TestAddDomainRejectedByNginx(t *testing.T) {
+	calls := fakeBench(t, `case "$2" in
+add-domain) echo "{\"domains\": [\"$3\"]}" > "sites/$5/site_config.json" ;;
+remove-domain) echo "{}" > "sites/$5/site_config.json" ;;
+esac`)
+	b := rejectingBench(t)
+	r := chi.NewRouter()
+	r.Post("/site/{name}/domains", b.AddDomainHandler)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/site/a.localhost/domains", strings.NewReader(`{"domain": "shop.example.com"}`)))
+	if rec.Code != 422 {
+		t.Fatalf("EXPECTED 422 GOT %d: %s", rec.Code, rec.Body.String())
+	}
+	expected := []string{
+		"setup add-domain shop.example.com --site a.localhost",
+		"setup remove-domain shop.example.com --site a.localhost",
+	}
+	if got := calls(); !slices.Equal(got, expected) {
+		t.Fatalf("EXPECTED %v GOT %v", expected, got)
+	}
+	domains, err := b.SiteDomains("a.localhost")
+	if err != nil || len(domains) != 0 {
+		t.Fatalf("EXPECTED no domains left GOT %v, %v", domains, err)
+	}
+}
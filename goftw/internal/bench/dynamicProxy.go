@@ -0,0 +1,120 @@
+package bench
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goftw/internal/environ"
+	internalExec "goftw/internal/fns"
+	"goftw/internal/nginx"
+	"goftw/internal/proxy"
+)
+
+const (
+	defaultProxyUpstream        = "frappe:80"
+	defaultBuiltinProxyUpstream = "127.0.0.1:80"
+	defaultProxyListen          = ":2020"
+)
+
+// dynamicProxyConfig routes each site of the bench under /{site}/, dropping
+// the .localhost extension API-created sites get. A site whose path cannot be
+// routed, e.g. api.localhost under the framework's /api/, is left out.
+func (b *Bench) dynamicProxyConfig() (nginx.ProxyConfig, error) {
+	instance := b.instance()
+	cfg := nginx.ProxyConfig{
+		Upstream:    defaultProxyUpstream,
+		ServerName:  instance.ServerName,
+		DefaultSite: instance.Proxy.DefaultSite,
+	}
+	switch {
+	case instance.Proxy.Upstream != "":
+		cfg.Upstream = instance.Proxy.Upstream
+	case instance.Proxy.Builtin:
+		cfg.Upstream = defaultBuiltinProxyUpstream
+	}
+	sites, err := b.ListSites()
+	if err != nil {
+		return cfg, err
+	}
+	for _, site := range sites {
+		cfg.Sites = append(cfg.Sites, nginx.ProxySite{Path: strings.TrimSuffix(site, siteExtension), Name: site})
+	}
+	var skipped []error
+	cfg.Sites, skipped = nginx.RoutableSites(cfg.Sites)
+	for _, err := range skipped {
+		fmt.Printf("[PROXY] Not routing a site: %v\n", err)
+	}
+	return cfg, nil
+}
+
+// UpdateDynamicProxy regenerates the path-based proxy config from the live
+// site list, which the nginx-dynamic-proxy container reloads when it
+// changes, or updates the built-in proxy's routes
+func (b *Bench) UpdateDynamicProxy() error {
+	cfg, err := b.dynamicProxyConfig()
+	if err != nil {
+		return err
+	}
+	data, err := nginx.RenderProxy(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to render dynamic proxy config: %v", err)
+	}
+
+	// The built-in proxy takes the same, validated, routing table
+	b.mu.Lock()
+	builtin := b.proxy
+	b.mu.Unlock()
+	if builtin != nil {
+		routes := make([]proxy.Route, 0, len(cfg.Sites))
+		for _, site := range cfg.Sites {
+			routes = append(routes, proxy.Route{Path: site.Path, Site: site.Name})
+		}
+		builtin.SetRoutes(routes, cfg.DefaultSite)
+		fmt.Printf("[PROXY] Routing %d sites\n", len(routes))
+		return nil
+	}
+
+	path := environ.GetDynamicProxyConf()
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := internalExec.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dynamic proxy config: %v", err)
+	}
+	fmt.Printf("[PROXY] Wrote %s for %d sites\n", path, len(cfg.Sites))
+	return nil
+}
+
+// BuiltinProxyServer returns the server of the built-in dynamic proxy, or nil
+// when instance.json leaves the proxy to the nginx-dynamic-proxy container.
+// Its routes are filled by UpdateDynamicProxy.
+func (b *Bench) BuiltinProxyServer() (*http.Server, error) {
+	settings := b.instance().Proxy
+	if !settings.Builtin {
+		return nil, nil
+	}
+	cfg, err := b.dynamicProxyConfig()
+	if err != nil {
+		return nil, err
+	}
+	p, err := proxy.New(cfg.Upstream)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	b.proxy = p
+	b.mu.Unlock()
+
+	listen := settings.Listen
+	if listen == "" {
+		listen = defaultProxyListen
+	}
+	return &http.Server{Addr: listen, Handler: p}, nil
+}
@@ -0,0 +1,46 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"goftw/internal/entity"
+	"goftw/internal/nginx"
+)
+
+// TestDynamicProxyConfig tests that every site is routed under its name without .localhost,
+// except one under a framework path
+func TestDynamicProxyConfig(t *testing.T) {
+	benchPath := t.TempDir()
+	for _, site := range []string{"hrms.localhost", "home.example.com", "api.localhost"} {
+		dir := filepath.Join(benchPath, "sites", site)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "site_config.json"), []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := &Bench{Path: benchPath, Instance: &entity.Instance{
+		ServerName: "example.com",
+		Proxy:      entity.Proxy{Upstream: "frappe-nginx:8080"},
+	}}
+
+	cfg, err := b.dynamicProxyConfig()
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	expected := nginx.ProxyConfig{
+		Upstream:   "frappe-nginx:8080",
+		ServerName: "example.com",
+		Sites: []nginx.ProxySite{
+			{Path: "home.example.com", Name: "home.example.com"},
+			{Path: "hrms", Name: "hrms.localhost"},
+		},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Fatalf("EXPECTED %+v GOT %+v", expected, cfg)
+	}
+}
@@ -0,0 +1,124 @@
+package bench
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"goftw/internal/entity"
+	"goftw/internal/utils"
+)
+
+// referencedApps returns apps listed for any site in instance.json, plus the
+// required_apps of those that are fetched, transitively.
+func (b *Bench) referencedApps(cfg *entity.Instance) map[string]bool {
+	referenced := map[string]bool{}
+	queue := []string{"frappe"}
+	for _, site := range cfg.Sites {
+		queue = append(queue, site.Apps...)
+	}
+	for len(queue) > 0 {
+		app := queue[0]
+		queue = queue[1:]
+		if referenced[app] {
+			continue
+		}
+		referenced[app] = true
+		for _, ref := range ReadAppMeta(filepath.Join(b.Path, "apps", app), app).RequiredApps {
+			if dep := normalizeAppRef(ref); dep != "" && !referenced[dep] {
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return referenced
+}
+
+// PlanGC finds apps in bench/apps that are not installed on any site and not
+// referenced in instance.json. Listing apps must succeed for every site, so an
+// app is never proposed because a site could not be inspected.
+func (b *Bench) PlanGC(cfg *entity.Instance) (*entity.GCPlan, error) {
+	apps, err := b.ListApps()
+	if err != nil {
+		return nil, err
+	}
+	sites, err := b.ListSites()
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]bool{}
+	for _, site := range sites {
+		siteApps, err := b.ListAppsOnSite(site)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list apps on site %s: %w", site, err)
+		}
+		for _, app := range siteApps {
+			installed[app.Name] = true
+		}
+	}
+	referenced := b.referencedApps(cfg)
+
+	plan := &entity.GCPlan{Apps: []entity.GCCandidate{}}
+	for _, app := range apps {
+		if installed[app] || referenced[app] {
+			continue
+		}
+		size, err := utils.DirSize(filepath.Join(b.Path, "apps", app))
+		if err != nil {
+			fmt.Printf("[WARN] Could not measure app %s: %v\n", app, err)
+		}
+		plan.Apps = append(plan.Apps, entity.GCCandidate{Name: app, Bytes: size, Size: utils.HumanBytes(size)})
+		plan.ReclaimBytes += size
+	}
+	sort.Slice(plan.Apps, func(i, j int) bool { return plan.Apps[i].Name < plan.Apps[j].Name })
+	plan.Reclaim = utils.HumanBytes(plan.ReclaimBytes)
+	return plan, nil
+}
+
+// RunGC plans again inside the job and removes the planned apps with
+// `bench remove-app`, keeping only those in confirmed unless it is empty, so
+// an app installed since confirmed was listed is not removed. It returns the
+// plan it ran and the apps removed, stopping at the first failure.
+func (b *Bench) RunGC(cfg *entity.Instance, confirmed []string) (*entity.GCPlan, []string, error) {
+	var plan *entity.GCPlan
+	removed := []string{}
+	err := b.runJob("gc", func() error {
+		var err error
+		if plan, err = b.PlanGC(cfg); err != nil {
+			return fmt.Errorf("failed to plan gc: %w", err)
+		}
+		if len(confirmed) > 0 {
+			plan = filterGCPlan(plan, confirmed)
+		}
+		for _, app := range plan.Apps {
+			fmt.Printf("[GC] Removing unused app %s (%s)\n", app.Name, app.Size)
+			if err := b.ExecRunInBenchPrintIO("bench", "remove-app", app.Name, "--no-backup"); err != nil {
+				return fmt.Errorf("failed to remove app %s: %w", app.Name, err)
+			}
+			removed = append(removed, app.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return plan, removed, err
+	}
+	fmt.Printf("[GC] Removed %d apps, reclaimed %s\n", len(removed), plan.Reclaim)
+	return plan, removed, nil
+}
+
+// filterGCPlan keeps only the planned apps that are also in apps
+func filterGCPlan(plan *entity.GCPlan, apps []string) *entity.GCPlan {
+	keep := map[string]bool{}
+	for _, app := range apps {
+		keep[app] = true
+	}
+	filtered := &entity.GCPlan{Apps: []entity.GCCandidate{}}
+	for _, app := range plan.Apps {
+		if keep[app.Name] {
+			filtered.Apps = append(filtered.Apps, app)
+			filtered.ReclaimBytes += app.Bytes
+		}
+	}
+	filtered.Reclaim = utils.HumanBytes(filtered.ReclaimBytes)
+	return filtered
+}
@@ -0,0 +1,184 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"goftw/internal/entity"
+)
+
+// fakeBench puts a `bench` script running script first on PATH and returns
+// a function listing the commands it was called with
+func fakeBench(t *testing.T, script string) func() []string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	content := "#!/bin/sh\necho \"$*\" >> " + log + "\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "bench"), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return func() []string {
+		data, _ := os.ReadFile(log)
+		if len(data) == 0 {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+// gcBench lays out a bench with the given app repositories and sites, each
+// site listing its apps in apps.txt for the fake `bench list-apps`
+func gcBench(t *testing.T, apps []string, sites map[string][]string) *Bench {
+	t.Helper()
+	path := t.TempDir()
+	for _, app := range apps {
+		dir := filepath.Join(path, "apps", app)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		git(t, dir, "init", "-q")
+		writeAppFile(t, dir, "setup.py", "setup()\n")
+	}
+	for site, installed := range sites {
+		writeAppFile(t, filepath.Join(path, "sites", site), "site_config.json", "{}")
+		setSiteApps(t, path, site, installed...)
+	}
+	return &Bench{Path: path}
+}
+
+// setSiteApps changes what the fake `bench list-apps` reports for site
+func setSiteApps(t *testing.T, path, site string, apps ...string) {
+	t.Helper()
+	writeAppFile(t, filepath.Join(path, "sites", site), "apps.txt", strings.Join(apps, "\n")+"\n")
+}
+
+const gcBenchScript = `case "$3" in
+list-apps) cat "sites/$2/apps.txt" ;;
+esac`
+
+// TestPlanGC tests which apps are proposed for removal
+func TestPlanGC(t *testing.T) {
+	tests := []struct {
+		name     string
+		apps     []string
+		sites    map[string][]string
+		instance entity.Instance
+		want     []string
+	}{
+		{
+			name:  "installed apps are kept",
+			apps:  []string{"frappe", "erpnext", "hrms"},
+			sites: map[string][]string{"a.localhost": {"frappe", "erpnext"}},
+			want:  []string{"hrms"},
+		},
+		{
+			name:     "apps listed in instance.json are kept before they are installed",
+			apps:     []string{"frappe", "crm", "lms"},
+			sites:    map[string][]string{"a.localhost": {"frappe"}},
+			instance: entity.Instance{Sites: []entity.Site{{SiteName: "b.localhost", Apps: []string{"crm"}}}},
+			want:     []string{"lms"},
+		},
+		{
+			name:  "frappe is never proposed",
+			apps:  []string{"frappe", "wiki", "blog"},
+			sites: map[string][]string{},
+			want:  []string{"blog", "wiki"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeBench(t, gcBenchScript)
+			b := gcBench(t, tt.apps, tt.sites)
+			plan, err := b.PlanGC(&tt.instance)
+			if err != nil {
+				t.Fatalf("EXPECTED no error GOT %v", err)
+			}
+			var got []string
+			for _, app := range plan.Apps {
+				got = append(got, app.Name)
+				if app.Bytes <= 0 {
+					t.Fatalf("EXPECTED %s to have a size GOT %d", app.Name, app.Bytes)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("EXPECTED %v GOT %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestPlanGCListFailure tests that no plan is made when a site cannot be inspected
+func TestPlanGCListFailure(t *testing.T) {
+	fakeBench(t, `[ "$2" = "b.localhost" ] && exit 1
+cat "sites/$2/apps.txt"`)
+	b := gcBench(t, []string{"frappe", "hrms"}, map[string][]string{"a.localhost": {"frappe"}, "b.localhost": {"frappe", "hrms"}})
+	if _, err := b.PlanGC(&entity.Instance{}); err == nil {
+		t.Fatal("EXPECTED an error when a site cannot be listed GOT nil")
+	}
+}
+
+// TestFilterGCPlan tests restricting a plan to confirmed apps
+func TestFilterGCPlan(t *testing.T) {
+	plan := &entity.GCPlan{Apps: []entity.GCCandidate{
+		{Name: "hrms", Bytes: 2048},
+		{Name: "lms", Bytes: 1024},
+		{Name: "wiki", Bytes: 512},
+	}, ReclaimBytes: 3584}
+
+	tests := []struct {
+		confirmed []string
+		want      []string
+		bytes     int64
+	}{
+		{[]string{"hrms", "wiki"}, []string{"hrms", "wiki"}, 2560},
+		{[]string{"wiki", "crm"}, []string{"wiki"}, 512},
+		{[]string{"crm"}, nil, 0},
+	}
+	for _, tt := range tests {
+		filtered := filterGCPlan(plan, tt.confirmed)
+		var got []string
+		for _, app := range filtered.Apps {
+			got = append(got, app.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) || filtered.ReclaimBytes != tt.bytes {
+			t.Fatalf("EXPECTED %v (%d bytes) GOT %v (%d bytes)", tt.want, tt.bytes, got, filtered.ReclaimBytes)
+		}
+	}
+}
+
+// TestRunGCReplans tests that an app installed after the plan was confirmed is kept
+func TestRunGCReplans(t *testing.T) {
+	calls := fakeBench(t, gcBenchScript)
+	b := gcBench(t, []string{"frappe", "hrms", "lms"}, map[string][]string{"a.localhost": {"frappe"}})
+	plan, err := b.PlanGC(&entity.Instance{})
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	confirmed := []string{}
+	for _, app := range plan.Apps {
+		confirmed = append(confirmed, app.Name)
+	}
+	if !reflect.DeepEqual(confirmed, []string{"hrms", "lms"}) {
+		t.Fatalf("EXPECTED hrms and lms planned GOT %v", confirmed)
+	}
+
+	// lms gets installed while the operator confirms
+	setSiteApps(t, b.Path, "a.localhost", "frappe", "lms")
+	ran, removed, err := b.RunGC(&entity.Instance{}, confirmed)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"hrms"}) || len(ran.Apps) != 1 {
+		t.Fatalf("EXPECTED only hrms removed GOT %v", removed)
+	}
+	for _, call := range calls() {
+		if strings.HasPrefix(call, "remove-app") && call != "remove-app hrms --no-backup" {
+			t.Fatalf("EXPECTED only hrms passed to remove-app GOT %s", call)
+		}
+	}
+}
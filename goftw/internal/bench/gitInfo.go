@@ -0,0 +1,85 @@
+package bench
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitOutput runs a git plumbing command in dir and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = os.Environ()
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w, stderr: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// isGitRepo reports whether dir is the top level of a git work tree.
+func isGitRepo(dir string) bool {
+	top, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		resolved = abs
+	}
+	return top == resolved
+}
+
+// gitRemote returns the fetch URL of the origin remote, or of the first remote configured.
+func gitRemote(dir string) string {
+	if url, err := gitOutput(dir, "config", "--get", "remote.origin.url"); err == nil && url != "" {
+		return url
+	}
+	remotes, err := gitOutput(dir, "remote")
+	if err != nil || remotes == "" {
+		return ""
+	}
+	name := strings.Fields(remotes)[0]
+	url, _ := gitOutput(dir, "config", "--get", "remote."+name+".url")
+	return url
+}
+
+// gitBranch returns the checked out branch, or an empty string on a detached HEAD.
+func gitBranch(dir string) string {
+	branch, _ := gitOutput(dir, "symbolic-ref", "--short", "-q", "HEAD")
+	return branch
+}
+
+// gitCommit returns the abbreviated HEAD commit.
+func gitCommit(dir string) string {
+	commit, _ := gitOutput(dir, "rev-parse", "--short", "HEAD")
+	return commit
+}
+
+// gitDirty reports whether tracked files differ from HEAD.
+func gitDirty(dir string) (bool, error) {
+	// Refresh stat info first so touched-but-unchanged files are not reported
+	_, _ = gitOutput(dir, "update-index", "-q", "--refresh")
+
+	_, err := gitOutput(dir, "diff-index", "--quiet", "HEAD", "--")
+	if err == nil {
+		return false, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,128 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// git runs a git command in dir with a fixed identity, failing the test on error
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := gitOutput(dir, append([]string{"-c", "user.name=goftw", "-c", "user.email=goftw@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// TestGitInfo tests the remote, branch, commit and dirty state read from app repositories
+func TestGitInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, dir string)
+		remote string
+		branch string
+		dirty  bool
+	}{
+		{
+			name: "clean branch with origin",
+			setup: func(t *testing.T, dir string) {
+				git(t, dir, "remote", "add", "origin", "https://github.com/frappe/hrms")
+			},
+			remote: "https://github.com/frappe/hrms",
+			branch: "develop",
+		},
+		{
+			name: "first remote without origin",
+			setup: func(t *testing.T, dir string) {
+				git(t, dir, "remote", "add", "upstream", "https://github.com/frappe/erpnext")
+			},
+			remote: "https://github.com/frappe/erpnext",
+			branch: "develop",
+		},
+		{
+			name: "detached head",
+			setup: func(t *testing.T, dir string) {
+				git(t, dir, "checkout", "-q", "--detach")
+			},
+		},
+		{
+			name: "modified tracked file",
+			setup: func(t *testing.T, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, "setup.py"), []byte("changed\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			branch: "develop",
+			dirty:  true,
+		},
+		{
+			name: "untracked file only",
+			setup: func(t *testing.T, dir string) {
+				if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			branch: "develop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			git(t, dir, "init", "-q", "-b", "develop")
+			if err := os.WriteFile(filepath.Join(dir, "setup.py"), []byte("setup()\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			git(t, dir, "add", "setup.py")
+			git(t, dir, "commit", "-q", "-m", "initial")
+			commit := git(t, dir, "rev-parse", "--short", "HEAD")
+			tt.setup(t, dir)
+
+			if !isGitRepo(dir) {
+				t.Fatalf("EXPECTED %s to be a git repository", dir)
+			}
+			if got := gitRemote(dir); got != tt.remote {
+				t.Fatalf("EXPECTED remote %q GOT %q", tt.remote, got)
+			}
+			if got := gitBranch(dir); got != tt.branch {
+				t.Fatalf("EXPECTED branch %q GOT %q", tt.branch, got)
+			}
+			if got := gitCommit(dir); got != commit {
+				t.Fatalf("EXPECTED commit %q GOT %q", commit, got)
+			}
+			dirty, err := gitDirty(dir)
+			if err != nil {
+				t.Fatalf("EXPECTED no error GOT %v", err)
+			}
+			if dirty != tt.dirty {
+				t.Fatalf("EXPECTED dirty %v GOT %v", tt.dirty, dirty)
+			}
+		})
+	}
+}
+
+// TestIsGitRepo tests that only the top level of a work tree counts as an app repository
+func TestIsGitRepo(t *testing.T) {
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	sub := filepath.Join(dir, "hrms")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{dir, true},
+		{sub, false},
+		{t.TempDir(), false},
+	}
+	for _, tt := range tests {
+		if got := isGitRepo(tt.dir); got != tt.want {
+			t.Fatalf("EXPECTED isGitRepo(%s) %v GOT %v", tt.dir, tt.want, got)
+		}
+	}
+}
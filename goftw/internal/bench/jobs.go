@@ -0,0 +1,98 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"goftw/internal/metrics"
+)
+
+// ErrShuttingDown is returned for jobs started after shutdown began
+var ErrShuttingDown = errors.New("goftw is shutting down and not accepting new jobs")
+
+// runJob runs fn as a job of jobType, recording its count and duration. Once
+// shutdown began it refuses new jobs, so a multi-step job such as a reconcile
+// stops between steps instead of being killed halfway through one.
+func (b *Bench) runJob(jobType string, fn func() error) error {
+	b.mu.Lock()
+	if b.draining {
+		b.mu.Unlock()
+		return ErrShuttingDown
+	}
+	b.jobs.Add(1)
+	b.mu.Unlock()
+	defer b.jobs.Done()
+
+	start := time.Now()
+	err := fn()
+	metrics.ObserveJob(jobType, start, err)
+	return err
+}
+
+// StopAcceptingJobs makes every later job fail with ErrShuttingDown
+func (b *Bench) StopAcceptingJobs() {
+	b.mu.Lock()
+	b.draining = true
+	b.mu.Unlock()
+}
+
+// ShuttingDown reports whether StopAcceptingJobs was called
+func (b *Bench) ShuttingDown() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.draining
+}
+
+// WaitForJobs stops accepting jobs and waits for running ones until ctx is done
+func (b *Bench) WaitForJobs(ctx context.Context) error {
+	b.StopAcceptingJobs()
+
+	done := make(chan struct{})
+	go func() {
+		b.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("running jobs did not finish: %w", ctx.Err())
+	}
+}
+
+// jobStatus returns the HTTP status for a failed job
+func jobStatus(err error) int {
+	if errors.Is(err, ErrShuttingDown) {
+		return 503
+	}
+	return 500
+}
+
+// commandLabel names a command for metrics without its variable arguments,
+// e.g. "bench --site a.localhost install-app hrms" becomes "bench install-app"
+func commandLabel(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	label := args[0]
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--site" {
+			i++
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		return label + " " + arg
+	}
+	return label
+}
+
+// observeCommand records the duration of a command run in the bench directory
+func observeCommand(args []string, start time.Time, err error) {
+	metrics.BenchCommandDuration.Observe(time.Since(start).Seconds(), commandLabel(args), metrics.Outcome(err))
+}
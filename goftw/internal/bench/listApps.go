@@ -3,7 +3,6 @@ package bench
 import (
 	"fmt"
 	"goftw/internal/entity"
-	"goftw/internal/fns"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -26,8 +25,8 @@ func (b *Bench) ListApps() ([]string, error) {
 		}
 
 		// Check if directory is a git repository
-		if err := fns.ExecRunPrintIO("git", "-C", d, "status"); err != nil {
-			fmt.Printf("[WARN] Skipping %s: git status failed\n", d)
+		if !isGitRepo(d) {
+			fmt.Printf("[WARN] Skipping %s: not a git repository\n", d)
 			continue
 		}
 		apps = append(apps, filepath.Base(d))
@@ -35,6 +34,66 @@ func (b *Bench) ListApps() ([]string, error) {
 	return apps, nil
 }
 
+// DescribeApps returns git details and metadata for every app in bench/apps,
+// along with the sites each app is installed on.
+func (b *Bench) DescribeApps() ([]entity.BenchApp, error) {
+	appNames, err := b.ListApps()
+	if err != nil {
+		return nil, err
+	}
+
+	sitesByApp, err := b.SitesByApp()
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make([]entity.BenchApp, 0, len(appNames))
+	for _, name := range appNames {
+		appPath := filepath.Join(b.Path, "apps", name)
+		dirty, err := gitDirty(appPath)
+		if err != nil {
+			fmt.Printf("[WARN] Could not determine dirty state of %s: %v\n", name, err)
+		}
+		sites := sitesByApp[name]
+		if sites == nil {
+			sites = []string{}
+		}
+		meta := ReadAppMeta(appPath, name)
+		apps = append(apps, entity.BenchApp{
+			Name:    name,
+			Remote:  gitRemote(appPath),
+			Branch:  gitBranch(appPath),
+			Commit:  gitCommit(appPath),
+			Dirty:   dirty,
+			Version: meta.Version,
+			Sites:   sites,
+			Meta:    meta,
+		})
+	}
+	return apps, nil
+}
+
+// SitesByApp maps each installed app to the sites it is installed on.
+func (b *Bench) SitesByApp() (map[string][]string, error) {
+	sites, err := b.ListSites()
+	if err != nil {
+		return nil, err
+	}
+
+	sitesByApp := map[string][]string{}
+	for _, site := range sites {
+		apps, err := b.ListAppsOnSite(site)
+		if err != nil {
+			fmt.Printf("[WARN] Could not list apps for site %s: %v\n", site, err)
+			continue
+		}
+		for _, app := range apps {
+			sitesByApp[app.Name] = append(sitesByApp[app.Name], site)
+		}
+	}
+	return sitesByApp, nil
+}
+
 // ListApps runs `bench --site <site> list-apps` and parses the result into []AppInfo.
 func (b *Bench) ListAppsOnSite(siteName string) ([]entity.App, error) {
 	out, err := b.ExecRunInBenchSwallowIO("bench", "--site", siteName, "list-apps")
@@ -74,5 +133,6 @@ func (b *Bench) ListAppsOnSite(siteName string) ([]entity.App, error) {
 		})
 	}
 
+	b.recordSiteApps(siteName, len(apps))
 	return apps, nil
 }
@@ -0,0 +1,69 @@
+package bench
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"goftw/internal/metrics"
+)
+
+// recordSiteApps caches the number of apps on a site for the site apps gauge
+func (b *Bench) recordSiteApps(site string, count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.siteAppCounts == nil {
+		b.siteAppCounts = map[string]int{}
+	}
+	b.siteAppCounts[site] = count
+}
+
+// newestBackup returns the modification time of the newest file in a site's backups folder
+func (b *Bench) newestBackup(site string) (time.Time, bool) {
+	entries, err := os.ReadDir(filepath.Join(b.Path, "sites", site, "private", "backups"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var newest time.Time
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, !newest.IsZero()
+}
+
+// CollectMetrics refreshes the per-site gauges from the filesystem and cached app lists.
+// It is cheap enough to run on every scrape; it never shells out to bench.
+func (b *Bench) CollectMetrics() {
+	sites, err := b.ListSites()
+	if err != nil {
+		fmt.Printf("[WARN] Metrics could not list sites: %v\n", err)
+		return
+	}
+	metrics.Sites.Set(float64(len(sites)))
+
+	b.mu.Lock()
+	counts := maps.Clone(b.siteAppCounts)
+	b.mu.Unlock()
+
+	// Sites that are gone drop out when the new series are swapped in
+	apps := metrics.SiteApps.NewSet()
+	backups := metrics.BackupAge.NewSet()
+	for _, site := range sites {
+		if count, ok := counts[site]; ok {
+			apps.Set(float64(count), site)
+		}
+		if newest, ok := b.newestBackup(site); ok {
+			backups.Set(time.Since(newest).Seconds(), site)
+		}
+	}
+	metrics.SiteApps.Replace(apps)
+	metrics.BackupAge.Replace(backups)
+}
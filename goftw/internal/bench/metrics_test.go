@@ -0,0 +1,50 @@
+package bench
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goftw/internal/metrics"
+)
+
+// TestCollectMetrics tests the per-site gauges while app counts are recorded
+// concurrently, as GET /site/{name} does
+func TestCollectMetrics(t *testing.T) {
+	b := &Bench{Path: t.TempDir()}
+	for _, site := range []string{"a.localhost", "b.localhost"} {
+		writeAppFile(t, filepath.Join(b.Path, "sites", site), "site_config.json", "{}")
+	}
+	writeAppFile(t, filepath.Join(b.Path, "sites", "a.localhost", "private", "backups"), "backup.sql.gz", "")
+	b.recordSiteApps("a.localhost", 3)
+	b.recordSiteApps("gone.localhost", 1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			b.recordSiteApps(fmt.Sprintf("%d.localhost", i), i)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		b.CollectMetrics()
+	}
+	<-done
+
+	var out strings.Builder
+	if _, err := metrics.Default.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{`goftw_site_apps{site="a.localhost"} 3`, `goftw_backup_age_seconds{site="a.localhost"}`} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("EXPECTED %s IN\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{`goftw_site_apps{site="b.localhost"}`, `site="gone.localhost"`, `goftw_backup_age_seconds{site="b.localhost"}`} {
+		if strings.Contains(got, unwanted) {
+			t.Fatalf("EXPECTED NO %s IN\n%s", unwanted, got)
+		}
+	}
+}
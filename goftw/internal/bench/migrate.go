@@ -7,7 +7,9 @@ import (
 // Migrate runs bench Migrate
 func (b *Bench) Migrate(site string) error {
 	fmt.Printf("[SITES] Migrating site: %s\n", site)
-	return b.ExecRunInBenchPrintIO("bench", "--site", site, "migrate")
+	return b.runJob("migrate", func() error {
+		return b.ExecRunInBenchPrintIO("bench", "--site", site, "migrate")
+	})
 }
 
 // MigrateSites runs migrate for all provided sites
@@ -0,0 +1,84 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"goftw/internal/supervisor"
+)
+
+// nativeSupervisorEnabled reports whether instance.json asks for the built-in
+// supervisor instead of supervisord
+func (b *Bench) nativeSupervisorEnabled() bool {
+	return b.instance().Supervisor == "native"
+}
+
+// supervisorProcessName is the process the deployment runs in production mode
+func (b *Bench) supervisorProcessName() string {
+	if b.nativeSupervisorEnabled() {
+		return "goftw supervisor"
+	}
+	return "supervisord"
+}
+
+// startNativeSupervisor runs the programs of the merged supervisor config as
+// goftw's own children
+func (b *Bench) startNativeSupervisor(cfg *supervisor.Config) (Process, error) {
+	sup, err := supervisor.New(cfg, filepath.Join(b.Path, "logs"))
+	if err != nil {
+		return nil, err
+	}
+	if err := sup.Start(); err != nil {
+		sup.Shutdown(context.Background())
+		return nil, err
+	}
+	b.setController(sup)
+	return newNativeProcess(sup), nil
+}
+
+// nativeProcess adapts the native supervisor to Process. SIGTERM stops every
+// program in reverse priority order; Kill cuts that short.
+type nativeProcess struct {
+	sup    *supervisor.Supervisor
+	ctx    context.Context
+	kill   context.CancelFunc
+	stopMu sync.Once
+}
+
+func newNativeProcess(sup *supervisor.Supervisor) *nativeProcess {
+	ctx, kill := context.WithCancel(context.Background())
+	return &nativeProcess{sup: sup, ctx: ctx, kill: kill}
+}
+
+// Pid is goftw's own PID, the parent of every program
+func (p *nativeProcess) Pid() int { return os.Getpid() }
+
+// Signal starts stopping the programs; any signal means stop
+func (p *nativeProcess) Signal(os.Signal) error {
+	p.stopMu.Do(func() {
+		go func() {
+			if err := p.sup.Shutdown(p.ctx); err != nil {
+				fmt.Printf("[SUPERVISOR] %v\n", err)
+			}
+		}()
+	})
+	return nil
+}
+
+// Kill stops the programs with SIGKILL
+func (p *nativeProcess) Kill() error {
+	p.kill()
+	return p.Signal(os.Kill)
+}
+
+// Wait blocks until every program has stopped
+func (p *nativeProcess) Wait() (int, string, error) {
+	<-p.sup.Done()
+	if p.ctx.Err() != nil {
+		return -1, "programs killed", nil
+	}
+	return 0, "programs stopped", nil
+}
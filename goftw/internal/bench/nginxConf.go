@@ -0,0 +1,234 @@
+package bench
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"goftw/internal/entity"
+	internalExec "goftw/internal/fns"
+	"goftw/internal/nginx"
+)
+
+// nginxConfig collects what the nginx config is rendered from: the bench's
+// sites with the domains and certificates of their site_config.json, the
+// per-site options of instance.json and the ports of common_site_config.json.
+// Names without a certificate of their own get the one goftw manages, if any.
+func (b *Bench) nginxConfig(serverName string) (nginx.Config, error) {
+	sitesPath := filepath.Join(b.Path, "sites")
+	cfg := nginx.Config{BenchName: b.Name, SitesPath: sitesPath, ServerName: serverName}
+	if b.tlsSettings().Enabled {
+		cfg.ACMEWebroot = b.acmeWebroot()
+	}
+	if cfg.BenchName == "" {
+		cfg.BenchName = filepath.Base(b.Path)
+	}
+	if common, err := entity.LoadCommonSitesConfig(filepath.Join(sitesPath, "common_site_config.json")); err == nil {
+		cfg.WebPort, cfg.SocketIOPort = common.WebserverPort, common.SocketIOPort
+	}
+
+	sites, err := b.ListSites()
+	if err != nil {
+		return cfg, err
+	}
+	for _, name := range sites {
+		siteCfg, err := entity.LoadSiteConfig(filepath.Join(sitesPath, name, "site_config.json"))
+		if err != nil {
+			return cfg, fmt.Errorf("failed to read site_config.json of %s: %v", name, err)
+		}
+		site := nginx.Site{
+			Name:           name,
+			Certificate:    siteCfg.SSLCertificate,
+			CertificateKey: siteCfg.SSLCertificateKey,
+			Domains:        siteCfg.Domains,
+		}
+		if site.Certificate == "" {
+			site.Certificate, site.CertificateKey, _ = b.managedCertificate(name)
+		}
+		for i, domain := range site.Domains {
+			if domain.SSLCertificate == "" {
+				site.Domains[i].SSLCertificate, site.Domains[i].SSLCertificateKey, _ = b.managedCertificate(domain.Domain)
+			}
+		}
+		if options := b.siteNginxOptions(name); options != nil {
+			site.Options = *options
+		}
+		cfg.Sites = append(cfg.Sites, site)
+	}
+	return cfg, nil
+}
+
+// siteNginxOptions returns the nginx options instance.json sets for a site
+func (b *Bench) siteNginxOptions(site string) *entity.NginxOptions {
+	for _, s := range b.instance().Sites {
+		if s.SiteName == site {
+			return s.Nginx
+		}
+	}
+	return nil
+}
+
+// NginxConfigError is a generated nginx config that `nginx -t` rejected
+type NginxConfigError struct {
+	Output   string // what nginx -t printed
+	Restored bool   // whether the previous config is still in place
+}
+
+func (e *NginxConfigError) Error() string {
+	if e.Restored {
+		return "nginx -t rejected the new config, the previous one was kept: " + e.Output
+	}
+	return "nginx -t rejected the config: " + e.Output
+}
+
+var (
+	// nginxMainConf is the main config nginx runs with
+	nginxMainConf = "/etc/nginx/nginx.conf"
+	// checkNginx runs nginx -t against mainConf and everything it includes
+	checkNginx = func(sudo bool, mainConf string) ([]byte, error) {
+		args := withSudo(sudo, "nginx", "-t", "-c", mainConf)
+		return exec.Command(args[0], args[1:]...).CombinedOutput()
+	}
+	// linkNginxConf points nginx's include at target
+	linkNginxConf = func(sudo bool, target, link string) error {
+		return internalExec.ExecRunPrintIO(withSudo(sudo, "ln", "-sfn", target, link)...)
+	}
+)
+
+// withSudo prefixes args with sudo when sudo is set
+func withSudo(sudo bool, args ...string) []string {
+	if sudo {
+		return append([]string{"sudo"}, args...)
+	}
+	return args
+}
+
+// nginxNeedsSudo reports whether nginx commands run as root. The native
+// supervisor runs nginx as goftw's own user, so they do not.
+func (b *Bench) nginxNeedsSudo() bool {
+	return !b.nativeSupervisorEnabled()
+}
+
+// applyNginxConf renders the nginx config for the bench's sites into
+// conf. nginx only ever includes a config that passed `nginx -t`: the
+// candidate is tested through a main config of its own while link keeps
+// pointing at the previous conf, and a rejected candidate is kept next to it
+// as conf.rejected.
+func (b *Bench) applyNginxConf(conf, link, serverName string) error {
+	cfg, err := b.nginxConfig(serverName)
+	if err != nil {
+		return err
+	}
+	data, err := nginx.Render(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to render nginx config: %v", err)
+	}
+	status, err := activateNginxConf(conf, link, data, b.nginxNeedsSudo())
+	b.mu.Lock()
+	b.nginxStatus = &status
+	b.mu.Unlock()
+	return err
+}
+
+// activateNginxConf tests data as the config nginx includes through link and
+// keeps it as conf only if nginx -t accepts it. The link is left alone until
+// then, so nginx never sees an untested or missing config.
+func activateNginxConf(conf, link string, data []byte, sudo bool) (entity.NginxStatus, error) {
+	now := time.Now()
+	status := entity.NginxStatus{Config: conf, CheckedAt: &now}
+	candidate := conf + ".candidate"
+	if err := os.MkdirAll(filepath.Dir(conf), 0755); err != nil {
+		return status, err
+	}
+	if err := os.WriteFile(candidate, data, 0644); err != nil {
+		return status, err
+	}
+
+	mainConf := conf + ".test"
+	if err := os.WriteFile(mainConf, candidateMainConf(nginxMainConf, link, candidate), 0644); err != nil {
+		return status, err
+	}
+	output, err := checkNginx(sudo, mainConf)
+	_ = os.Remove(mainConf)
+	if err != nil {
+		configErr := &NginxConfigError{Output: strings.TrimSpace(string(output))}
+		if configErr.Output == "" {
+			configErr.Output = err.Error()
+		}
+		if _, statErr := os.Stat(conf); statErr == nil {
+			configErr.Restored = true
+		}
+		status.Rejected = conf + ".rejected"
+		_ = os.Rename(candidate, status.Rejected)
+		status.Error, status.Restored = configErr.Output, configErr.Restored
+		fmt.Printf("[NGINX] %v\n", configErr)
+		return status, configErr
+	}
+
+	// A link already at conf sees the new config as soon as it is renamed
+	if err := os.Rename(candidate, conf); err != nil {
+		return status, err
+	}
+	if target, err := os.Readlink(link); err != nil || target != conf {
+		if err := linkNginxConf(sudo, conf, link); err != nil {
+			return status, fmt.Errorf("failed to link nginx config: %v", err)
+		}
+	}
+	_ = os.Remove(conf + ".rejected")
+	status.Valid = true
+	return status, nil
+}
+
+// includeDirective matches an include of the main nginx config
+var includeDirective = regexp.MustCompile(`(?m)^(\s*)include\s+([^;\s]+)\s*;`)
+
+// candidateMainConf returns mainConf with the includes that pick up link
+// including candidate instead, so nginx -t checks the candidate together with
+// everything else nginx loads. Relative includes are made absolute since nginx
+// resolves them against the directory of the main config. Without a readable
+// mainConf, the candidate is checked on its own.
+func candidateMainConf(mainConf, link, candidate string) []byte {
+	data, err := os.ReadFile(mainConf)
+	if err != nil {
+		return []byte(fmt.Sprintf("events {}\n\nhttp {\n    include %s;\n}\n", candidate))
+	}
+	return includeDirective.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := includeDirective.FindSubmatch(match)
+		indent, pattern := string(groups[1]), string(groups[2])
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(mainConf), pattern)
+		}
+		if ok, _ := filepath.Match(pattern, link); !ok {
+			return []byte(indent + "include " + pattern + ";")
+		}
+		var lines []string
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			if path != link {
+				lines = append(lines, indent+"include "+path+";")
+			}
+		}
+		return []byte(strings.Join(append(lines, indent+"include "+candidate+";"), "\n"))
+	})
+}
+
+// NginxStatus returns the outcome of the last generated nginx config
+func (b *Bench) NginxStatus() entity.NginxStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.nginxStatus == nil {
+		return entity.NginxStatus{Config: filepath.Join(b.Path, "config", "nginx.conf")}
+	}
+	return *b.nginxStatus
+}
+
+// NginxHandler returns whether the last generated nginx config was accepted
+func (b *Bench) NginxHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] NginxHandler called")
+	writeJSON(w, 200, b.NginxStatus())
+}
@@ -0,0 +1,170 @@
+package bench
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"goftw/internal/entity"
+)
+
+// TestNginxConfig tests that sites, their site_config.json domains and instance.json options are collected
+func TestNginxConfig(t *testing.T) {
+	benchPath := t.TempDir()
+	files := map[string]string{
+		"sites/common_site_config.json":       `{"webserver_port": 8080, "socketio_port": 9001}`,
+		"sites/a.localhost/site_config.json":  `{"db_name": "a", "domains": ["shop.com", {"domain": "www.shop.com", "ssl_certificate": "/ssl/shop.pem", "ssl_certificate_key": "/ssl/shop.key"}]}`,
+		"sites/b.localhost/site_config.json":  `{"db_name": "b", "ssl_certificate": "/ssl/b.pem", "ssl_certificate_key": "/ssl/b.key"}`,
+		"sites/assets/frappe/placeholder.txt": ``,
+	}
+	for name, content := range files {
+		path := filepath.Join(benchPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := &Bench{Name: "frappe-bench", Path: benchPath, Instance: &entity.Instance{Sites: []entity.Site{
+		{SiteName: "a.localhost", Nginx: &entity.NginxOptions{ClientMaxBodySize: "100m"}},
+	}}}
+
+	cfg, err := b.nginxConfig("example.com")
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if cfg.WebPort != 8080 || cfg.SocketIOPort != 9001 || cfg.ServerName != "example.com" {
+		t.Fatalf("EXPECTED ports 8080/9001 and example.com GOT %+v", cfg)
+	}
+	if len(cfg.Sites) != 2 {
+		t.Fatalf("EXPECTED 2 sites GOT %+v", cfg.Sites)
+	}
+	expectedDomains := []entity.SiteDomain{
+		{Domain: "shop.com"},
+		{Domain: "www.shop.com", SSLCertificate: "/ssl/shop.pem", SSLCertificateKey: "/ssl/shop.key"},
+	}
+	if a := cfg.Sites[0]; a.Name != "a.localhost" || !reflect.DeepEqual(a.Domains, expectedDomains) || a.Options.ClientMaxBodySize != "100m" {
+		t.Fatalf("EXPECTED a.localhost with domains %+v GOT %+v", expectedDomains, a)
+	}
+	if b := cfg.Sites[1]; b.Name != "b.localhost" || b.Certificate != "/ssl/b.pem" || b.CertificateKey != "/ssl/b.key" {
+		t.Fatalf("EXPECTED b.localhost with its certificate GOT %+v", b)
+	}
+}
+
+// TestActivateNginxConf tests that only a config nginx -t accepts stays linked
+func TestActivateNginxConf(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "config", "nginx.conf")
+	link := filepath.Join(dir, "frappe-bench.conf")
+
+	linked := func() string {
+		target, err := os.Readlink(link)
+		if err != nil {
+			return ""
+		}
+		data, _ := os.ReadFile(target)
+		return string(data)
+	}
+
+	var accept bool
+	var links int
+	var linkedDuringCheck []string
+	defer func(main string, check func(bool, string) ([]byte, error), link func(bool, string, string) error) {
+		nginxMainConf, checkNginx, linkNginxConf = main, check, link
+	}(nginxMainConf, checkNginx, linkNginxConf)
+	nginxMainConf = filepath.Join(dir, "missing.conf")
+	checkNginx = func(_ bool, mainConf string) ([]byte, error) {
+		linkedDuringCheck = append(linkedDuringCheck, linked())
+		if data, _ := os.ReadFile(mainConf); !strings.Contains(string(data), "include "+conf+".candidate;") {
+			t.Errorf("EXPECTED the main config to include the candidate GOT %s", data)
+		}
+		if accept {
+			return []byte("syntax is ok"), nil
+		}
+		return []byte("unknown directive \"servr\""), errors.New("exit status 1")
+	}
+	linkNginxConf = func(_ bool, target, link string) error {
+		links++
+		os.Remove(link)
+		return os.Symlink(target, link)
+	}
+
+	// Nothing to fall back to on the first config
+	_, err := activateNginxConf(conf, link, []byte("servr {}"), false)
+	var configErr *NginxConfigError
+	if !errors.As(err, &configErr) || configErr.Restored {
+		t.Fatalf("EXPECTED an unrestored NginxConfigError GOT %v", err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Fatalf("EXPECTED no link GOT %v", err)
+	}
+
+	accept = true
+	status, err := activateNginxConf(conf, link, []byte("server {}"), false)
+	if err != nil || !status.Valid || linked() != "server {}" {
+		t.Fatalf("EXPECTED server {} linked GOT %v, %+v, %q", err, status, linked())
+	}
+
+	accept = false
+	status, err = activateNginxConf(conf, link, []byte("servr { listen 80; }"), false)
+	if !errors.As(err, &configErr) || !configErr.Restored {
+		t.Fatalf("EXPECTED a restored NginxConfigError GOT %v", err)
+	}
+	if linked() != "server {}" {
+		t.Fatalf("EXPECTED the previous config linked GOT %q", linked())
+	}
+	if links != 1 {
+		t.Fatalf("EXPECTED the link set once GOT %d times", links)
+	}
+	if expected := []string{"", "", "server {}"}; !reflect.DeepEqual(linkedDuringCheck, expected) {
+		t.Fatalf("EXPECTED nginx to include %q during the checks GOT %q", expected, linkedDuringCheck)
+	}
+	if _, err := os.Stat(conf + ".test"); !os.IsNotExist(err) {
+		t.Fatalf("EXPECTED the test main config removed GOT %v", err)
+	}
+	rejected, _ := os.ReadFile(status.Rejected)
+	if status.Valid || status.Error != "unknown directive \"servr\"" || string(rejected) != "servr { listen 80; }" {
+		t.Fatalf("EXPECTED the rejected candidate and nginx output GOT %+v, %q", status, rejected)
+	}
+}
+
+// TestCandidateMainConf tests that the includes picking up the link include the candidate instead
+func TestCandidateMainConf(t *testing.T) {
+	dir := t.TempDir()
+	confD := filepath.Join(dir, "conf.d")
+	for _, name := range []string{"frappe-bench.conf", "other.conf"} {
+		writeAppFile(t, confD, name, "")
+	}
+	link := filepath.Join(confD, "frappe-bench.conf")
+	mainConf := filepath.Join(dir, "nginx.conf")
+	writeAppFile(t, dir, "nginx.conf", `user www-data;
+events {}
+http {
+    include mime.types;
+    include `+confD+`/*.conf;
+    include /etc/nginx/sites-enabled/*;
+}
+`)
+
+	expected := `user www-data;
+events {}
+http {
+    include ` + filepath.Join(dir, "mime.types") + `;
+    include ` + filepath.Join(confD, "other.conf") + `;
+    include /bench/config/nginx.conf.candidate;
+    include /etc/nginx/sites-enabled/*;
+}
+`
+	if got := string(candidateMainConf(mainConf, link, "/bench/config/nginx.conf.candidate")); got != expected {
+		t.Fatalf("UNEXPECTED main config\nEXPECTED:\n%s\nGOT:\n%s", expected, got)
+	}
+
+	expected = "events {}\n\nhttp {\n    include /bench/config/nginx.conf.candidate;\n}\n"
+	if got := string(candidateMainConf(filepath.Join(dir, "missing.conf"), link, "/bench/config/nginx.conf.candidate")); got != expected {
+		t.Fatalf("EXPECTED the candidate on its own GOT %q", got)
+	}
+}
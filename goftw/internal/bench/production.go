@@ -1,71 +1,76 @@
 package bench
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"regexp"
-	"syscall"
+	"time"
 
+	"goftw/internal/entity"
+	"goftw/internal/environ"
 	internalExec "goftw/internal/fns"
+	"goftw/internal/ini"
+	"goftw/internal/supervisor"
 )
 
-var (
-	productionCMD *exec.Cmd
-	blockRegex    = regexp.MustCompile(`server_name\s+([\s\S]*?);`)
-)
+// supervisorSocket is where supervisord serves XML-RPC
+const supervisorSocket = "/var/run/goftw-supervisor.sock"
 
 // RunSupervisorNginx sets up supervisor for the bench, merges configs, and starts supervisord.
 func (b *Bench) RunSupervisorNginx() error {
-	if unmannedDeployment {
-		return fmt.Errorf("cannot run production WSGI: unmanaged shell deployment active")
-	}
-	// Configure nginx
+	fmt.Printf("[MODE] PRODUCTION\n")
+	return b.Deployment().Start("production", b.supervisorProcessName(), b.startSupervisorNginx)
+}
+
+// startSupervisorNginx regenerates the nginx and supervisor configs and spawns
+// supervisord, or the native supervisor when instance.json asks for it
+func (b *Bench) startSupervisorNginx() (Process, error) {
+	// Configure nginx, starting with the previous config if the new one is rejected
 	if err := b.configurePatchNginx(b, b.ServerName); err != nil {
-		fmt.Printf("[ERROR] Failed to setup nginx: %v\n", err)
-		return err
+		var configErr *NginxConfigError
+		if !errors.As(err, &configErr) || !configErr.Restored {
+			fmt.Printf("[ERROR] Failed to setup nginx: %v\n", err)
+			return nil, err
+		}
+		fmt.Printf("[WARN] Serving the previous nginx config: %v\n", err)
 	}
 
 	// Configure supervisor
-	tmpFile, err := b.configurePatchSupervisor(b)
+	tmpFile, err := b.configurePatchSupervisor(b, b.Scaling())
 	if err != nil {
 		fmt.Printf("[ERROR] Failed configure and patch supervisor: %v\n", err)
-		return err
+		return nil, err
+	}
+	// Remember the programs so a reload can tell whether they changed
+	cfg, err := supervisor.LoadConfig(tmpFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supervisor config: %v", err)
+	}
+	b.setSupervisorConfig(cfg)
+	if b.nativeSupervisorEnabled() {
+		return b.startNativeSupervisor(cfg)
 	}
 
-	productionCMD, err = internalExec.ExecStartPrintIO("sudo", "supervisord", "-c", tmpFile)
-	// Start without waiting
+	// Start without waiting, the deployment manager reaps it
+	cmd, err := internalExec.ExecStartPrintIO("sudo", "supervisord", "-c", tmpFile)
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to start supervisord: %v\n", err)
-		return err
+		return nil, err
 	}
-
-	// Supervisord is running in the background now
-	fmt.Printf("[WSGI] Production WSGI started (PID: %d).\n", productionCMD.Process.Pid)
-	return nil
+	b.setController(supervisor.NewRPCClient(supervisorSocket))
+	return ExecProcess(cmd), nil
 }
 
 // TerminateSupervisorNginx stops production services (supervisord + nginx).
 func (b *Bench) TerminateSupervisorNginx() error {
-	if productionCMD == nil || productionCMD.Process == nil {
-		return fmt.Errorf("production WSGI not running")
-	}
-	if unmannedDeployment {
-		return fmt.Errorf("cannot stop production WSGI: unmanaged shell deployment active")
-	}
-
-	// Send SIGTERM to gracefully stop supervisord
-	if err := productionCMD.Process.Signal(syscall.SIGTERM); err != nil {
-		return fmt.Errorf("failed to stop production WSGI: %v", err)
-	}
-
-	fmt.Println("[WSGI] Production WSGI stopped")
-	productionCMD = nil
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return b.Deployment().Stop(ctx)
 }
 
-// configurePatchSupervisor runs supervisor setup, patches it and returns conf or error
-func (b *Bench) configurePatchSupervisor(bench *Bench) (string, error) {
+// configurePatchSupervisor runs supervisor setup, patches it with scaling and returns conf or error
+func (b *Bench) configurePatchSupervisor(bench *Bench, scaling entity.Scaling) (string, error) {
 	supervisorConf := bench.Path + "/config/supervisor.conf"
 	wrapperConf := "/patches/head.patch.conf"
 
@@ -84,66 +89,58 @@ func (b *Bench) configurePatchSupervisor(bench *Bench) (string, error) {
 	}
 
 	// Merge configs
-	wrapper, err := internalExec.ReadFile(wrapperConf)
+	head, err := ini.Load(wrapperConf)
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to read supervisor wrapper config: %v\n", err)
 		return "", err
 	}
-	benchConf, err := internalExec.ReadFile(supervisorConf)
+	generated, err := ini.Load(supervisorConf)
 	if err != nil {
 		fmt.Printf("[ERROR] Failed to read supervisor config: %v\n", err)
 		return "", err
 	}
+	if err := scaleSupervisorConf(generated, scaling); err != nil {
+		fmt.Printf("[ERROR] Failed to apply scaling: %v\n", err)
+		return "", err
+	}
+	overlays, err := ini.LoadDir(environ.GetSupervisorOverlayDir())
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to read supervisor overlays: %v\n", err)
+		return "", err
+	}
+	var rpc *ini.File
+	if !b.nativeSupervisorEnabled() {
+		rpc = supervisorRPCConf()
+	}
+	merged, err := mergeSupervisorConf(head, generated, rpc, overlays)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to merge supervisor config: %v\n", err)
+		return "", err
+	}
 
 	tmpFile := "/tmp/supervisor-merged.tmp"
-	if err := os.WriteFile(tmpFile, append(wrapper, append([]byte("\n"), benchConf...)...), 0644); err != nil {
-		fmt.Printf("[ERROR] Failed to write temporary merged config: %v\n", err)
-		return "", fmt.Errorf("failed to write temporary merged config: %v", err)
+	if err := writeSupervisorConf(tmpFile, merged); err != nil {
+		fmt.Printf("[ERROR] Failed to write merged config: %v\n", err)
+		return "", fmt.Errorf("failed to write merged config: %v", err)
 	}
 	return tmpFile, nil
 }
 
-// configurePatchNginx sets up nginx using bench and symlinks the config.
+// configurePatchNginx renders the nginx config for the bench's sites and
+// links it once `nginx -t` accepts it.
 func (b *Bench) configurePatchNginx(bench *Bench, serverName string) error {
 	nginxConf := bench.Path + "/config/nginx.conf"
 	nginxConfDest := "/etc/nginx/conf.d/frappe-bench.conf"
-	logPatch := "/patches/log.patch.conf"
-	globalConf := "/etc/nginx/nginx.conf"
-
-	// Remove old configs/links to force regeneration
-	_ = internalExec.RemoveFile(nginxConf)
-	_ = internalExec.RemoveFile(nginxConfDest)
-
-	// Generate nginx config
-	if err := bench.ExecRunInBenchPrintIO("bench", "setup", "nginx"); err != nil {
-		fmt.Printf("[ERROR] Failed to setup nginx: %v\n", err)
-		return fmt.Errorf("failed to setup nginx: %v", err)
-	}
-
-	// Inject patch into global nginx.conf if not already present
-	checkCmd := []string{"grep", "-q", "log_format main", globalConf}
-	if err := internalExec.ExecRunPrintIO(checkCmd...); err != nil {
-		fmt.Printf("[PATCH] Injecting main log_format into %s\n", globalConf)
-		if err := internalExec.ExecRunPrintIO("sudo", "sed", "-i", "/http {/r "+logPatch, globalConf); err != nil {
-			fmt.Printf("[ERROR] Failed to inject main.patch.conf: %v\n", err)
-			// not fatal — continue
-		}
-	}
 
 	// Note: Dynamic proxy (port 2020) is handled by a separate nginx service in docker-compose
 	// No need to patch server_name here for dynamic routing
 
-	// Symlink bench-generated config
-	err := internalExec.ExecRunPrintIO("sudo", "ln", "-sf", nginxConf, nginxConfDest)
-	if err != nil {
-		fmt.Printf("[ERROR] Failed to symlink nginx config: %v\n", err)
+	if err := bench.applyNginxConf(nginxConf, nginxConfDest, serverName); err != nil {
+		fmt.Printf("[ERROR] Failed to setup nginx: %v\n", err)
 		return err
 	}
 
 	fmt.Printf("[NGINX] Nginx configured and symlinked\n")
-	// if str, err := internalExec.ReadFile(nginxConf); err == nil {
-	// 	fmt.Println(string(str))
-	// }
 	return nil
 }
 
@@ -0,0 +1,111 @@
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"goftw/internal/supervisor"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// setController records the supervisor the programs API talks to
+func (b *Bench) setController(c supervisor.Controller) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.controller = c
+}
+
+// Controller returns the supervisor running the production programs, if any
+func (b *Bench) Controller() (supervisor.Controller, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.controller == nil {
+		return nil, fmt.Errorf("no supervisor running: programs are only managed in production mode")
+	}
+	return b.controller, nil
+}
+
+// programStatus maps a controller error to an HTTP status
+func programStatus(err error) int {
+	var missing supervisor.ErrNoSuchProcess
+	if errors.As(err, &missing) {
+		return 404
+	}
+	return 500
+}
+
+// ProgramsHandler lists every supervised process with its state
+func (b *Bench) ProgramsHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] ProgramsHandler called")
+	controller, err := b.Controller()
+	if err != nil {
+		writeError(w, 503, err.Error())
+		return
+	}
+	programs, err := controller.Programs()
+	if err != nil {
+		writeError(w, 502, fmt.Sprintf("failed to list programs: %v", err))
+		return
+	}
+	writeJSON(w, 200, programs)
+}
+
+// ProgramActionHandler starts, stops or restarts a program, e.g.
+// POST /programs/frappe-bench-workers:*/restart
+func (b *Bench) ProgramActionHandler(w http.ResponseWriter, r *http.Request) {
+	name, action := chi.URLParam(r, "program"), chi.URLParam(r, "action")
+	fmt.Printf("[API] ProgramActionHandler called: %s %s\n", action, name)
+	controller, err := b.Controller()
+	if err != nil {
+		writeError(w, 503, err.Error())
+		return
+	}
+
+	switch action {
+	case "start":
+		err = controller.StartProgram(name)
+	case "stop":
+		err = controller.StopProgram(name)
+	case "restart":
+		err = controller.RestartProgram(name)
+	default:
+		writeError(w, 400, fmt.Sprintf("unknown action %q, want start, stop or restart", action))
+		return
+	}
+	if err != nil {
+		writeError(w, programStatus(err), fmt.Sprintf("failed to %s %s: %v", action, name, err))
+		return
+	}
+	writeJSON(w, 200, map[string]string{"program": name, "action": action, "status": "ok"})
+}
+
+// ProgramLogHandler returns the tail of a process's log as plain text.
+// Query: stream=stdout|stderr, bytes (default 16384)
+func (b *Bench) ProgramLogHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "program")
+	fmt.Printf("[API] ProgramLogHandler called: %s\n", name)
+	controller, err := b.Controller()
+	if err != nil {
+		writeError(w, 503, err.Error())
+		return
+	}
+
+	n := int64(16384)
+	if value := r.URL.Query().Get("bytes"); value != "" {
+		if n, err = strconv.ParseInt(value, 10, 64); err != nil || n <= 0 {
+			writeError(w, 400, fmt.Sprintf("invalid bytes %q", value))
+			return
+		}
+	}
+	data, err := controller.TailLog(name, r.URL.Query().Get("stream"), n)
+	if err != nil {
+		writeError(w, programStatus(err), fmt.Sprintf("failed to read log of %s: %v", name, err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,285 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"goftw/internal/entity"
+	internalExec "goftw/internal/fns"
+	"goftw/internal/supervisor"
+)
+
+// Roles of the programs `bench setup supervisor` generates
+const (
+	roleWeb       = "web"       // gunicorn
+	roleWorker    = "worker"    // bench worker
+	roleScheduler = "scheduler" // bench schedule
+	roleSocketIO  = "socketio"  // node socketio.js
+	roleNginx     = "nginx"
+	roleOther     = "other"
+)
+
+// programRole classifies a program by its command
+func programRole(program supervisor.ProgramConfig) string {
+	command := program.Command
+	switch {
+	case strings.Contains(command, "gunicorn"):
+		return roleWeb
+	case strings.Contains(command, "bench worker"):
+		return roleWorker
+	case strings.Contains(command, "bench schedule"):
+		return roleScheduler
+	case strings.Contains(command, "socketio"):
+		return roleSocketIO
+	case strings.Contains(command, "nginx"):
+		return roleNginx
+	}
+	return roleOther
+}
+
+// setSupervisorConfig records the programs the supervisor runs
+func (b *Bench) setSupervisorConfig(cfg *supervisor.Config) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.programs = cfg
+}
+
+// supervisorConfig returns the programs the supervisor runs
+func (b *Bench) supervisorConfig() *supervisor.Config {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.programs
+}
+
+// programsChanged reports whether next defines different programs or groups than current
+func programsChanged(current, next *supervisor.Config) bool {
+	if current == nil || next == nil {
+		return true
+	}
+	return !reflect.DeepEqual(current.Programs, next.Programs) || !reflect.DeepEqual(current.Groups, next.Groups)
+}
+
+// ReloadDeployment applies site changes without dropping requests: it
+// regenerates the nginx and supervisor configs, reloads nginx once `nginx -t`
+// passes, sends gunicorn HUP and restarts workers one at a time. Groups whose
+// programs changed are replaced like `supervisorctl update`; a full restart is
+// the fallback when that fails. The dynamic proxy is regenerated afterwards.
+func (b *Bench) ReloadDeployment() error {
+	return b.reloadDeployment(nil)
+}
+
+// reloadDeployment is ReloadDeployment with scaling, when not nil, in place
+// of the instance's. The instance takes it over once the programs run it.
+func (b *Bench) reloadDeployment(scaling *entity.Scaling) error {
+	return b.runJob("reload", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		defer cancel()
+		err := b.Deployment().Reload(ctx, func(ctx context.Context) (bool, error) {
+			return b.reload(ctx, scaling)
+		})
+		// The path-based proxy routes to the sites in every mode
+		if err := b.UpdateDynamicProxy(); err != nil {
+			fmt.Printf("[ERROR] Failed to update the dynamic proxy: %v\n", err)
+		}
+		return err
+	})
+}
+
+// reload is reloadDeployment's work, run while the deployment is locked so the
+// scaling it reads or commits cannot change meanwhile
+func (b *Bench) reload(ctx context.Context, scaling *entity.Scaling) (bool, error) {
+	target := b.Scaling()
+	if scaling != nil {
+		target = *scaling
+	}
+	// commit hands scaling to the instance once the programs run it
+	commit := func() {
+		if scaling != nil {
+			b.setScaling(*scaling)
+		}
+	}
+
+	if b.Deployment().Status().Mode != "production" {
+		// bench start serves every site from the same processes
+		fmt.Println("[RELOAD] Development mode picks up site changes without a reload")
+		commit()
+		return false, nil
+	}
+
+	if err := b.configurePatchNginx(b, b.ServerName); err != nil {
+		return false, fmt.Errorf("failed to setup nginx: %w", err)
+	}
+	confFile, err := b.configurePatchSupervisor(b, target)
+	if err != nil {
+		return false, fmt.Errorf("failed to configure supervisor: %v", err)
+	}
+	next, err := supervisor.LoadConfig(confFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to read supervisor config: %v", err)
+	}
+
+	controller, err := b.Controller()
+	if err != nil {
+		return false, err
+	}
+	if err := b.reloadNginx(controller, next); err != nil {
+		return false, err
+	}
+
+	// Changed programs (e.g. new scaling) restart only their own groups
+	var updated []string
+	if programsChanged(b.supervisorConfig(), next) {
+		if updated, err = controller.Update(next); err != nil {
+			fmt.Printf("[RELOAD] Updating supervisor programs failed, restarting the deployment: %v\n", err)
+			commit()
+			return true, nil
+		}
+		b.setSupervisorConfig(next)
+		fmt.Printf("[RELOAD] Restarted changed program groups %v\n", updated)
+	}
+	if err := reloadPrograms(ctx, controller, next, updated); err != nil {
+		return false, err
+	}
+	commit()
+	fmt.Println("[RELOAD] Deployment reloaded")
+	return false, nil
+}
+
+// reloadNginx reloads nginx workers gracefully. The native supervisor's nginx
+// is goftw's own child and gets HUP through controller, without root;
+// supervisord's is checked and reloaded with sudo.
+func (b *Bench) reloadNginx(controller supervisor.Controller, cfg *supervisor.Config) error {
+	if !b.nginxNeedsSudo() {
+		return signalNginx(controller, cfg)
+	}
+	if err := internalExec.ExecRunPrintIO("sudo", "nginx", "-t"); err != nil {
+		return fmt.Errorf("nginx config test failed: %v", err)
+	}
+	if err := internalExec.ExecRunPrintIO("sudo", "nginx", "-s", "reload"); err != nil {
+		return fmt.Errorf("nginx reload failed: %v", err)
+	}
+	fmt.Println("[RELOAD] nginx reloaded")
+	return nil
+}
+
+// signalNginx sends HUP to the running nginx program, which makes nginx
+// reread its config and replace its workers
+func signalNginx(controller supervisor.Controller, cfg *supervisor.Config) error {
+	statuses, err := controller.Programs()
+	if err != nil {
+		return fmt.Errorf("failed to list programs: %v", err)
+	}
+	for _, status := range statuses {
+		program := programOf(cfg, status.Name)
+		if program == nil || programRole(*program) != roleNginx || status.State != supervisor.StateRunning {
+			continue
+		}
+		name := status.Group + ":" + status.Name
+		if err := controller.SignalProgram(name, syscall.SIGHUP); err != nil {
+			return fmt.Errorf("nginx reload failed: %v", err)
+		}
+		fmt.Printf("[RELOAD] Sent HUP to %s\n", name)
+		return nil
+	}
+	return fmt.Errorf("nginx reload failed: no running nginx program")
+}
+
+// reloadPrograms sends gunicorn HUP, which replaces its workers gracefully, and
+// restarts background workers and the scheduler one process at a time. Groups
+// in skip were just restarted and are left alone.
+func reloadPrograms(ctx context.Context, controller supervisor.Controller, cfg *supervisor.Config, skip []string) error {
+	statuses, err := controller.Programs()
+	if err != nil {
+		return fmt.Errorf("failed to list programs: %v", err)
+	}
+	for _, status := range statuses {
+		program := programOf(cfg, status.Name)
+		if program == nil || status.State != supervisor.StateRunning || slices.Contains(skip, status.Group) {
+			continue
+		}
+		name := status.Group + ":" + status.Name
+		switch programRole(*program) {
+		case roleWeb:
+			if err := controller.SignalProgram(name, syscall.SIGHUP); err != nil {
+				return fmt.Errorf("failed to reload %s: %v", name, err)
+			}
+			fmt.Printf("[RELOAD] Sent HUP to %s\n", name)
+		case roleWorker, roleScheduler:
+			if err := controller.RestartProgram(name); err != nil {
+				return fmt.Errorf("failed to restart %s: %v", name, err)
+			}
+			if err := waitForProgram(ctx, controller, status.Name); err != nil {
+				return err
+			}
+			fmt.Printf("[RELOAD] Restarted %s\n", name)
+		}
+	}
+	return nil
+}
+
+// programOf returns the program a process belongs to; numprocs > 1 processes
+// are named <program>-<n>
+func programOf(cfg *supervisor.Config, process string) *supervisor.ProgramConfig {
+	for i, program := range cfg.Programs {
+		if process == program.Name || strings.HasPrefix(process, program.Name+"-") {
+			return &cfg.Programs[i]
+		}
+	}
+	return nil
+}
+
+// waitForProgram waits until a restarted process is RUNNING again. A process
+// no longer listed, e.g. renamed by an update, fails right away.
+func waitForProgram(ctx context.Context, controller supervisor.Controller, process string) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		statuses, err := controller.Programs()
+		if err != nil {
+			return err
+		}
+		listed := false
+		for _, status := range statuses {
+			if status.Name != process {
+				continue
+			}
+			listed = true
+			switch status.State {
+			case supervisor.StateRunning:
+				return nil
+			case supervisor.StateFatal, supervisor.StateExited:
+				return fmt.Errorf("%s did not come back: %s %s", process, status.State, status.Description)
+			}
+		}
+		if !listed {
+			return fmt.Errorf("%s did not come back: %w", process, supervisor.ErrNoSuchProcess{Name: process})
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not come back: %w", process, ctx.Err())
+		}
+	}
+}
+
+// ReloadDeploymentHandler reloads the deployment after out-of-band changes
+func (b *Bench) ReloadDeploymentHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] ReloadDeploymentHandler called")
+	if err := b.ReloadDeployment(); err != nil {
+		var configErr *NginxConfigError
+		if errors.As(err, &configErr) {
+			writeJSON(w, 422, map[string]interface{}{"error": "nginx rejected the new config", "nginx": b.NginxStatus()})
+			return
+		}
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to reload deployment: %v", err))
+		return
+	}
+	writeJSON(w, 200, b.Deployment().Status())
+}
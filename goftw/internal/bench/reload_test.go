@@ -0,0 +1,213 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"goftw/internal/entity"
+	"goftw/internal/proxy"
+	"goftw/internal/supervisor"
+)
+
+// fakeController records control calls against a fixed process list
+type fakeController struct {
+	statuses []entity.ProgramStatus
+	calls    []string
+}
+
+func (c *fakeController) Programs() ([]entity.ProgramStatus, error) { return c.statuses, nil }
+func (c *fakeController) StartProgram(name string) error {
+	c.calls = append(c.calls, "start "+name)
+	return nil
+}
+func (c *fakeController) StopProgram(name string) error {
+	c.calls = append(c.calls, "stop "+name)
+	return nil
+}
+func (c *fakeController) RestartProgram(name string) error {
+	c.calls = append(c.calls, "restart "+name)
+	return nil
+}
+func (c *fakeController) SignalProgram(name string, sig syscall.Signal) error {
+	c.calls = append(c.calls, fmt.Sprintf("signal %s %d", name, sig))
+	return nil
+}
+func (c *fakeController) TailLog(name, stream string, n int64) ([]byte, error) { return nil, nil }
+func (c *fakeController) Update(*supervisor.Config) ([]string, error)          { return nil, nil }
+
+// benchPrograms mirrors `bench setup supervisor --skip-redis` plus the nginx patch
+func benchPrograms() *supervisor.Config {
+	return &supervisor.Config{
+		Programs: []supervisor.ProgramConfig{
+			{Name: "frappe-bench-frappe-web", Command: "/env/bin/gunicorn -b 127.0.0.1:8000 -w 2 frappe.app:application"},
+			{Name: "nginx", Command: `/usr/sbin/nginx -g "daemon off;"`},
+			{Name: "frappe-bench-frappe-schedule", Command: "/usr/local/bin/bench schedule"},
+			{Name: "frappe-bench-frappe-short-worker", Command: "/usr/local/bin/bench worker --queue short,default", NumProcs: 2},
+			{Name: "frappe-bench-node-socketio", Command: "/usr/bin/node /apps/frappe/socketio.js"},
+		},
+		Groups: map[string][]string{"frappe-bench-workers": {"frappe-bench-frappe-schedule", "frappe-bench-frappe-short-worker"}},
+	}
+}
+
+// TestReloadPrograms tests that gunicorn gets HUP and workers restart one by one
+func TestReloadPrograms(t *testing.T) {
+	running := func(group, name string) entity.ProgramStatus {
+		return entity.ProgramStatus{Group: group, Name: name, State: supervisor.StateRunning}
+	}
+	controller := &fakeController{statuses: []entity.ProgramStatus{
+		running("frappe-bench-web", "frappe-bench-frappe-web"),
+		running("nginx", "nginx"),
+		running("frappe-bench-workers", "frappe-bench-frappe-schedule"),
+		running("frappe-bench-workers", "frappe-bench-frappe-short-worker-0"),
+		running("frappe-bench-workers", "frappe-bench-frappe-short-worker-1"),
+		running("frappe-bench-web", "frappe-bench-node-socketio"),
+		{Group: "frappe-bench-workers", Name: "frappe-bench-frappe-long-worker", State: supervisor.StateStopped},
+	}}
+
+	if err := reloadPrograms(context.Background(), controller, benchPrograms(), []string{"nginx"}); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	expected := []string{
+		fmt.Sprintf("signal frappe-bench-web:frappe-bench-frappe-web %d", syscall.SIGHUP),
+		"restart frappe-bench-workers:frappe-bench-frappe-schedule",
+		"restart frappe-bench-workers:frappe-bench-frappe-short-worker-0",
+		"restart frappe-bench-workers:frappe-bench-frappe-short-worker-1",
+	}
+	if !reflect.DeepEqual(controller.calls, expected) {
+		t.Fatalf("EXPECTED %v GOT %v", expected, controller.calls)
+	}
+}
+
+// TestProgramsChanged tests when a reload must fall back to a full restart
+func TestProgramsChanged(t *testing.T) {
+	changedCommand := benchPrograms()
+	changedCommand.Programs[0].Command += " --preload"
+	removed := benchPrograms()
+	removed.Programs = removed.Programs[:4]
+
+	tests := []struct {
+		name     string
+		current  *supervisor.Config
+		expected bool
+	}{
+		{"same programs", benchPrograms(), false},
+		{"changed command", changedCommand, true},
+		{"removed program", removed, true},
+		{"never started", nil, true},
+	}
+	for _, tt := range tests {
+		if got := programsChanged(tt.current, benchPrograms()); got != tt.expected {
+			t.Fatalf("%s: EXPECTED %v GOT %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+// TestReloadNginxNative tests that the native supervisor's nginx is reloaded with HUP, not sudo
+func TestReloadNginxNative(t *testing.T) {
+	b := &Bench{Instance: &entity.Instance{Supervisor: "native"}}
+	controller := &fakeController{statuses: []entity.ProgramStatus{
+		{Group: "frappe-bench-web", Name: "frappe-bench-frappe-web", State: supervisor.StateRunning},
+		{Group: "nginx", Name: "nginx", State: supervisor.StateRunning},
+	}}
+	if err := b.reloadNginx(controller, benchPrograms()); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	expected := []string{fmt.Sprintf("signal nginx:nginx %d", syscall.SIGHUP)}
+	if !reflect.DeepEqual(controller.calls, expected) {
+		t.Fatalf("EXPECTED %v GOT %v", expected, controller.calls)
+	}
+
+	controller = &fakeController{statuses: []entity.ProgramStatus{{Group: "nginx", Name: "nginx", State: supervisor.StateFatal}}}
+	if err := b.reloadNginx(controller, benchPrograms()); err == nil {
+		t.Fatal("EXPECTED an error without a running nginx GOT nil")
+	}
+}
+
+// TestWaitForProgramMissing tests that a process no longer listed fails at once
+func TestWaitForProgramMissing(t *testing.T) {
+	controller := &fakeController{statuses: []entity.ProgramStatus{
+		{Group: "frappe-bench-workers", Name: "frappe-bench-frappe-short-worker-0", State: supervisor.StateRunning},
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := waitForProgram(ctx, controller, "frappe-bench-frappe-schedule")
+	var missing supervisor.ErrNoSuchProcess
+	if !errors.As(err, &missing) {
+		t.Fatalf("EXPECTED ErrNoSuchProcess GOT %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("EXPECTED to fail without polling GOT %s", elapsed)
+	}
+	if err := waitForProgram(ctx, controller, "frappe-bench-frappe-short-worker-0"); err != nil {
+		t.Fatalf("EXPECTED a running process to pass GOT %v", err)
+	}
+}
+
+// rejectingBench returns a running production bench with one site whose
+// regenerated nginx config `nginx -t` rejects. The previous config stays in
+// place and the built-in proxy keeps the proxy update in memory.
+func rejectingBench(t *testing.T) *Bench {
+	t.Helper()
+	check, link := checkNginx, linkNginxConf
+	t.Cleanup(func() { checkNginx, linkNginxConf = check, link })
+	checkNginx = func(bool, string) ([]byte, error) {
+		return []byte("nginx: [emerg] invalid server name"), errors.New("exit status 1")
+	}
+	linkNginxConf = func(bool, string, string) error { return nil }
+
+	path := t.TempDir()
+	writeAppFile(t, filepath.Join(path, "sites", "a.localhost"), "site_config.json", "{}")
+	writeAppFile(t, filepath.Join(path, "config"), "nginx.conf", "# accepted before\n")
+	builtin, err := proxy.New(defaultBuiltinProxyUpstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bench{Name: "frappe-bench", Path: path, proxy: builtin}
+	if err := b.Deployment().Start("production", "supervisord", shLauncher("sleep 30")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = b.Deployment().Stop(context.Background()) })
+	return b
+}
+
+// TestReloadDeploymentNginxRejected tests that a rejected nginx config reaches
+// callers as an NginxConfigError and the handler answers 422
+func TestReloadDeploymentNginxRejected(t *testing.T) {
+	b := rejectingBench(t)
+
+	err := b.ReloadDeployment()
+	var configErr *NginxConfigError
+	if !errors.As(err, &configErr) || !configErr.Restored {
+		t.Fatalf("EXPECTED a restored NginxConfigError GOT %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	b.ReloadDeploymentHandler(rec, httptest.NewRequest(http.MethodPost, "/api/goftw/deployment/reload", nil))
+	if rec.Code != 422 {
+		t.Fatalf("EXPECTED 422 GOT %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Nginx entity.NginxStatus `json:"nginx"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Nginx.Valid || !body.Nginx.Restored || body.Nginx.Error == "" {
+		t.Fatalf("EXPECTED the rejected nginx status GOT %+v", body.Nginx)
+	}
+	if _, err := os.Stat(filepath.Join(b.Path, "config", "nginx.conf.rejected")); err != nil {
+		t.Fatalf("EXPECTED the rejected config kept GOT %v", err)
+	}
+}
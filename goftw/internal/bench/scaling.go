@@ -0,0 +1,274 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goftw/internal/entity"
+	"goftw/internal/environ"
+	"goftw/internal/ini"
+	"goftw/internal/supervisor"
+)
+
+// Scaling returns the program sizes from instance.json
+func (b *Bench) Scaling() entity.Scaling {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Instance == nil {
+		return entity.Scaling{}
+	}
+	return b.Instance.Scaling
+}
+
+// setScaling replaces the instance's scaling, which a restart starts programs with
+func (b *Bench) setScaling(scaling entity.Scaling) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.Instance != nil {
+		b.Instance.Scaling = scaling
+	}
+}
+
+// SetScaling validates scaling and reloads the deployment with it. The
+// instance takes it over only once the programs run it, and only then is it
+// saved to the scaling key of instance.json. A failed reload may have applied
+// part of it, e.g. updated groups before a rolling restart failed, so the
+// deployment is reloaded again with the previous scaling.
+func (b *Bench) SetScaling(scaling entity.Scaling) error {
+	if err := scaling.Validate(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	if b.Instance == nil {
+		b.mu.Unlock()
+		return fmt.Errorf("no instance.json loaded")
+	}
+	previous := b.Instance.Scaling
+	b.mu.Unlock()
+
+	if err := b.reloadDeployment(&scaling); err != nil {
+		b.setScaling(previous)
+		if rollbackErr := b.reloadDeployment(&previous); rollbackErr != nil {
+			return fmt.Errorf("%w; reloading the previous scaling also failed: %v", err, rollbackErr)
+		}
+		return err
+	}
+	if err := entity.SetInstanceKey(environ.GetInstanceFile(), "scaling", scaling); err != nil {
+		fmt.Printf("[WARN] Scaling applied but not saved to %s: %v\n", environ.GetInstanceFile(), err)
+	}
+	return nil
+}
+
+// scaleSupervisorConf applies scaling to the config `bench setup supervisor`
+// generated
+func scaleSupervisorConf(generated *ini.File, scaling entity.Scaling) error {
+	if reflect.DeepEqual(scaling, entity.Scaling{}) {
+		return nil
+	}
+	return applyScaling(generated, scaling)
+}
+
+// applyScaling rewrites the generated programs: gunicorn flags, worker
+// numprocs per queue (adding or removing queue workers) and the scheduler
+func applyScaling(file *ini.File, scaling entity.Scaling) error {
+	workers := map[string]*ini.Section{}
+	var template *ini.Section
+	for _, section := range programSections(file) {
+		program := supervisor.ProgramConfig{Command: section.Value("command", "")}
+		switch programRole(program) {
+		case roleWeb:
+			if err := scaleGunicorn(section, scaling.Gunicorn); err != nil {
+				return err
+			}
+		case roleWorker:
+			queue := workerQueue(section.Value("command", ""))
+			workers[queue] = section
+			if template == nil {
+				template = section
+			}
+		case roleScheduler:
+			if scaling.Scheduler != nil && !*scaling.Scheduler {
+				removeProgram(file, section.Name)
+			}
+		}
+	}
+
+	queues := make([]string, 0, len(scaling.Workers))
+	for queue := range scaling.Workers {
+		queues = append(queues, queue)
+	}
+	sort.Strings(queues)
+	for _, queue := range queues {
+		n := scaling.Workers[queue]
+		section, ok := workers[queue]
+		switch {
+		case ok && n == 0:
+			removeProgram(file, section.Name)
+		case ok:
+			setNumProcs(section, n)
+		case n == 0:
+		case template == nil:
+			return fmt.Errorf("cannot add workers for queue %s: bench generated no worker program", queue)
+		default:
+			addQueueWorker(file, template, queue, n)
+		}
+	}
+	return nil
+}
+
+// programSections returns the [program:x] sections
+func programSections(file *ini.File) []*ini.Section {
+	var sections []*ini.Section
+	for _, section := range file.Sections {
+		if strings.HasPrefix(section.Name, "program:") {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// scaleGunicorn sets -w, --threads and -t on the gunicorn command
+func scaleGunicorn(section *ini.Section, scaling entity.GunicornScaling) error {
+	args, err := supervisor.SplitCommand(section.Value("command", ""))
+	if err != nil {
+		return fmt.Errorf("%s: %v", section.Name, err)
+	}
+	if scaling.Workers > 0 {
+		args = setFlag(args, strconv.Itoa(scaling.Workers), "-w", "--workers")
+	}
+	if scaling.Threads > 0 {
+		args = setFlag(args, strconv.Itoa(scaling.Threads), "--threads")
+	}
+	if scaling.Timeout > 0 {
+		args = setFlag(args, strconv.Itoa(scaling.Timeout), "-t", "--timeout")
+	}
+	section.Set("command", supervisor.JoinCommand(args))
+	return nil
+}
+
+// setFlag replaces the value of a flag spelled as any of names, or adds the
+// last name right after the executable
+func setFlag(args []string, value string, names ...string) []string {
+	for i := 1; i < len(args); i++ {
+		for _, name := range names {
+			if args[i] == name && i+1 < len(args) {
+				args[i+1] = value
+				return args
+			}
+			if strings.HasPrefix(name, "--") && strings.HasPrefix(args[i], name+"=") {
+				args[i] = name + "=" + value
+				return args
+			}
+		}
+	}
+	return append([]string{args[0], names[len(names)-1], value}, args[1:]...)
+}
+
+// workerQueue returns the first queue a `bench worker` listens on
+func workerQueue(command string) string {
+	args, _ := supervisor.SplitCommand(command)
+	for i, arg := range args {
+		if arg == "--queue" && i+1 < len(args) {
+			return strings.Split(args[i+1], ",")[0]
+		}
+		if strings.HasPrefix(arg, "--queue=") {
+			return strings.Split(strings.TrimPrefix(arg, "--queue="), ",")[0]
+		}
+	}
+	return "default"
+}
+
+// setNumProcs runs n processes of a program, numbering them
+func setNumProcs(section *ini.Section, n int) {
+	section.Set("numprocs", strconv.Itoa(n))
+	if !strings.Contains(section.Value("process_name", ""), "process_num") {
+		section.Set("process_name", "%(program_name)s-%(process_num)d")
+	}
+}
+
+// addQueueWorker clones template into a worker for queue, in the same group
+func addQueueWorker(file *ini.File, template *ini.Section, queue string, n int) {
+	templateName := strings.TrimPrefix(template.Name, "program:")
+	name := strings.Replace(templateName, "-"+workerQueue(template.Value("command", ""))+"-", "-"+queue+"-", 1)
+	if name == templateName {
+		name = templateName + "-" + queue
+	}
+
+	section := file.AddSection("program:" + name)
+	for _, key := range template.Keys {
+		section.Set(key.Name, key.Value)
+	}
+	args, _ := supervisor.SplitCommand(template.Value("command", ""))
+	section.Set("command", supervisor.JoinCommand(setFlag(args, queue, "--queue")))
+	for _, key := range []string{"stdout_logfile", "stderr_logfile"} {
+		if logfile, ok := template.Get(key); ok {
+			section.Set(key, strings.Replace(logfile, workerQueue(template.Value("command", "")), queue, 1))
+		}
+	}
+	setNumProcs(section, n)
+
+	for _, group := range file.Sections {
+		if !strings.HasPrefix(group.Name, "group:") {
+			continue
+		}
+		members := strings.Split(group.Value("programs", ""), ",")
+		for _, member := range members {
+			if strings.TrimSpace(member) == templateName {
+				group.Set("programs", strings.Join(append(members, name), ","))
+				return
+			}
+		}
+	}
+}
+
+// removeProgram drops a program and its group memberships; emptied groups go too
+func removeProgram(file *ini.File, section string) {
+	file.RemoveSection(section)
+	name := strings.TrimPrefix(section, "program:")
+	for _, group := range append([]*ini.Section(nil), file.Sections...) {
+		if !strings.HasPrefix(group.Name, "group:") {
+			continue
+		}
+		var kept []string
+		for _, member := range strings.Split(group.Value("programs", ""), ",") {
+			if member = strings.TrimSpace(member); member != "" && member != name {
+				kept = append(kept, member)
+			}
+		}
+		if len(kept) == 0 {
+			file.RemoveSection(group.Name)
+		} else {
+			group.Set("programs", strings.Join(kept, ","))
+		}
+	}
+}
+
+// ScalingHandler returns the program sizes
+func (b *Bench) ScalingHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] ScalingHandler called")
+	writeJSON(w, 200, b.Scaling())
+}
+
+// PutScalingHandler replaces the program sizes and applies them live
+func (b *Bench) PutScalingHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Println("[API] PutScalingHandler called")
+	var scaling entity.Scaling
+	if err := json.NewDecoder(r.Body).Decode(&scaling); err != nil {
+		writeError(w, 400, "invalid JSON body")
+		return
+	}
+	if err := scaling.Validate(); err != nil {
+		writeError(w, 422, err.Error())
+		return
+	}
+	if err := b.SetScaling(scaling); err != nil {
+		writeError(w, jobStatus(err), fmt.Sprintf("failed to apply scaling: %v", err))
+		return
+	}
+	writeJSON(w, 200, b.Scaling())
+}
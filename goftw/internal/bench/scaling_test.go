@@ -0,0 +1,123 @@
+package bench
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"goftw/internal/entity"
+	"goftw/internal/ini"
+)
+
+// generatedSupervisorConf is trimmed from `bench setup supervisor --skip-redis`
+const generatedSupervisorConf = `
+[program:frappe-bench-frappe-web]
+command=/home/frappe/frappe-bench/env/bin/gunicorn -b 127.0.0.1:8000 -w 17 --max-requests 5000 -t 120 frappe.app:application --preload
+priority=4
+
+[program:frappe-bench-frappe-schedule]
+command=/usr/local/bin/bench schedule
+priority=3
+
+[program:frappe-bench-frappe-short-worker]
+command=/usr/local/bin/bench worker --queue short,default
+numprocs=1
+process_name=%(program_name)s-%(process_num)d
+
+[program:frappe-bench-frappe-long-worker]
+command=/usr/local/bin/bench worker --queue long,default,short
+numprocs=1
+process_name=%(program_name)s-%(process_num)d
+
+[group:frappe-bench-web]
+programs=frappe-bench-frappe-web
+
+[group:frappe-bench-workers]
+programs=frappe-bench-frappe-schedule,frappe-bench-frappe-short-worker,frappe-bench-frappe-long-worker
+`
+
+// TestApplyScaling tests gunicorn flags, queue workers and the scheduler switch
+func TestApplyScaling(t *testing.T) {
+	file, err := ini.Parse(strings.NewReader(generatedSupervisorConf))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	noScheduler := false
+	err = applyScaling(file, entity.Scaling{
+		Gunicorn:  entity.GunicornScaling{Workers: 2, Threads: 4, Timeout: 60},
+		Workers:   map[string]int{"short": 0, "long": 3, "default": 2},
+		Scheduler: &noScheduler,
+	})
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+
+	web := file.Section("program:frappe-bench-frappe-web").Value("command", "")
+	expectedWeb := "/home/frappe/frappe-bench/env/bin/gunicorn --threads 4 -b 127.0.0.1:8000 -w 2 --max-requests 5000 -t 60 frappe.app:application --preload"
+	if web != expectedWeb {
+		t.Fatalf("EXPECTED %q GOT %q", expectedWeb, web)
+	}
+
+	if file.Section("program:frappe-bench-frappe-schedule") != nil || file.Section("program:frappe-bench-frappe-short-worker") != nil {
+		t.Fatalf("EXPECTED scheduler and short worker removed GOT\n%s", file.Bytes())
+	}
+	if n := file.Section("program:frappe-bench-frappe-long-worker").Value("numprocs", ""); n != "3" {
+		t.Fatalf("EXPECTED 3 long workers GOT %s", n)
+	}
+
+	added := file.Section("program:frappe-bench-frappe-default-worker")
+	if added == nil {
+		t.Fatalf("EXPECTED a default queue worker GOT\n%s", file.Bytes())
+	}
+	if command := added.Value("command", ""); command != "/usr/local/bin/bench worker --queue default" {
+		t.Fatalf("EXPECTED default queue command GOT %q", command)
+	}
+	if n := added.Value("numprocs", ""); n != "2" {
+		t.Fatalf("EXPECTED 2 default workers GOT %s", n)
+	}
+
+	group := file.Section("group:frappe-bench-workers").Value("programs", "")
+	if group != "frappe-bench-frappe-long-worker,frappe-bench-frappe-default-worker" {
+		t.Fatalf("EXPECTED group with long and default workers GOT %q", group)
+	}
+}
+
+// TestApplyScalingEmptyGroup tests that a group losing its last program is removed
+func TestApplyScalingEmptyGroup(t *testing.T) {
+	file, err := ini.Parse(strings.NewReader(generatedSupervisorConf))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	noScheduler := false
+	err = applyScaling(file, entity.Scaling{
+		Workers:   map[string]int{"short": 0, "long": 0},
+		Scheduler: &noScheduler,
+	})
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if file.Section("group:frappe-bench-workers") != nil {
+		t.Fatalf("EXPECTED empty workers group removed GOT\n%s", file.Bytes())
+	}
+}
+
+// TestSetScalingReloadFails tests that a failed reload leaves the instance on
+// the previous scaling and reloads with it again
+func TestSetScalingReloadFails(t *testing.T) {
+	b := rejectingBench(t)
+	previous := entity.Scaling{Workers: map[string]int{"long": 2}}
+	b.Instance = &entity.Instance{Scaling: previous}
+
+	err := b.SetScaling(entity.Scaling{Workers: map[string]int{"long": 4}})
+	var configErr *NginxConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("EXPECTED the NginxConfigError of the reload GOT %v", err)
+	}
+	if !strings.Contains(err.Error(), "reloading the previous scaling also failed") {
+		t.Fatalf("EXPECTED the rollback reload to run GOT %v", err)
+	}
+	if got := b.Scaling(); !reflect.DeepEqual(got, previous) {
+		t.Fatalf("EXPECTED scaling %+v GOT %+v", previous, got)
+	}
+}
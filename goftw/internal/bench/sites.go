@@ -17,13 +17,50 @@ func siteExistsInCfx(site string, cfg *entity.Instance) bool {
 
 // New creates a new site
 func (b *Bench) NewSite(site, dbRootUser, dbRootPass string) error {
-	err := b.ExecRunInBenchPrintIO("bench", "new-site", site, "--db-root-username", dbRootUser, "--db-root-password", dbRootPass, "--admin-password", "admin")
-	return err
+	return b.runJob("site_create", func() error {
+		return b.newSite(site, dbRootUser, dbRootPass)
+	})
+}
+
+func (b *Bench) newSite(site, dbRootUser, dbRootPass string) error {
+	return b.ExecRunInBenchPrintIO("bench", "new-site", site, "--db-root-username", dbRootUser, "--db-root-password", dbRootPass, "--admin-password", "admin")
+}
+
+// CreateSite creates site and installs apps in order as one job, so a drain
+// cannot start between the steps. A site whose apps fail to install is
+// dropped again; the error says when that failed too.
+func (b *Bench) CreateSite(site string, apps []string, dbRootUser, dbRootPass string) error {
+	return b.runJob("site_create", func() error {
+		if err := b.newSite(site, dbRootUser, dbRootPass); err != nil {
+			return fmt.Errorf("failed to create site: %w", err)
+		}
+		for _, app := range apps {
+			if app == "frappe" {
+				continue
+			}
+			if err := b.installApp(site, app); err != nil {
+				err = fmt.Errorf("failed to install app %s: %w", app, err)
+				fmt.Printf("[SITES] %v, dropping site %s\n", err, site)
+				if dropErr := b.dropSite(site, dbRootPass); dropErr != nil {
+					fmt.Printf("[ERROR] Failed to drop site %s: %v\n", site, dropErr)
+					return fmt.Errorf("%w; dropping the site also failed: %v", err, dropErr)
+				}
+				return err
+			}
+			fmt.Printf("[SITES] App %s installed on site %s\n", app, site)
+		}
+		return nil
+	})
 }
 
 func (b *Bench) DropSite(site, dbRootUser, dbRootPass string) error {
-	err := b.ExecRunInBenchPrintIO("bench", "drop-site", site, "--force", "--root-password", dbRootPass)
-	return err
+	return b.runJob("site_drop", func() error {
+		return b.dropSite(site, dbRootPass)
+	})
+}
+
+func (b *Bench) dropSite(site, dbRootPass string) error {
+	return b.ExecRunInBenchPrintIO("bench", "drop-site", site, "--force", "--root-password", dbRootPass)
 }
 
 // DropAbandonedSites drops sites that exist in the bench but are not listed in instance.json
@@ -0,0 +1,71 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCreateSiteDropsOnFailure tests that a site whose app fails to install is
+// dropped again, even when a drain starts while the app installs
+func TestCreateSiteDropsOnFailure(t *testing.T) {
+	release := filepath.Join(t.TempDir(), "release")
+	calls := fakeBench(t, `case "$1" in
+new-site) mkdir -p "sites/$2" ;;
+drop-site) rm -rf "sites/$2" ;;
+get-app) exit 1 ;;
+esac
+if [ "$3" = "install-app" ]; then
+	while [ ! -e "`+release+`" ]; do sleep 0.05; done
+	exit 1
+fi`)
+	b := &Bench{Path: t.TempDir()}
+
+	done := make(chan error, 1)
+	go func() { done <- b.CreateSite("a.localhost", []string{"frappe", "hrms"}, "root", "secret") }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(strings.Join(calls(), "\n"), "install-app") {
+		if time.Now().After(deadline) {
+			t.Fatalf("EXPECTED install-app to run GOT %v", calls())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.StopAcceptingJobs()
+	if err := os.WriteFile(release, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := <-done
+	if err == nil || !strings.Contains(err.Error(), "failed to install app hrms") {
+		t.Fatalf("EXPECTED the install error GOT %v", err)
+	}
+	var dropped bool
+	for _, call := range calls() {
+		dropped = dropped || strings.HasPrefix(call, "drop-site a.localhost")
+	}
+	if !dropped {
+		t.Fatalf("EXPECTED drop-site a.localhost GOT %v", calls())
+	}
+	if _, err := os.Stat(filepath.Join(b.Path, "sites", "a.localhost")); !os.IsNotExist(err) {
+		t.Fatalf("EXPECTED the half-created site removed GOT %v", err)
+	}
+}
+
+// TestCreateSiteReportsDropFailure tests that a failed cleanup is part of the error
+func TestCreateSiteReportsDropFailure(t *testing.T) {
+	fakeBench(t, `case "$1" in
+new-site) mkdir -p "sites/$2" ;;
+drop-site|get-app) exit 1 ;;
+esac
+[ "$3" = "install-app" ] && exit 1
+exit 0`)
+	b := &Bench{Path: t.TempDir()}
+
+	err := b.CreateSite("a.localhost", []string{"hrms"}, "root", "secret")
+	if err == nil || !strings.Contains(err.Error(), "dropping the site also failed") {
+		t.Fatalf("EXPECTED the drop failure reported GOT %v", err)
+	}
+}
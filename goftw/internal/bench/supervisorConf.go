@@ -0,0 +1,69 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+
+	internalExec "goftw/internal/fns"
+	"goftw/internal/ini"
+)
+
+// mergeSupervisorConf builds the config supervisord runs: the head patch, the
+// XML-RPC sections (unless the head patch defines its own) and bench's
+// generated programs must not disagree on any key. Overlays then add programs
+// or override keys, in order.
+func mergeSupervisorConf(head, generated, rpc *ini.File, overlays []*ini.File) (*ini.File, error) {
+	layers := []*ini.File{head}
+	if rpc != nil && head.Section("unix_http_server") == nil {
+		layers = append(layers, rpc)
+	}
+	layers = append(layers, generated)
+
+	merged, err := ini.Merge(layers...)
+	if err != nil {
+		return nil, err
+	}
+	for _, overlay := range overlays {
+		for _, change := range ini.Overlay(merged, overlay) {
+			fmt.Printf("[SUPERVISOR] Overlay %s\n", change)
+		}
+	}
+	return merged, nil
+}
+
+// writeSupervisorConf atomically replaces path with merged, logging what
+// changed since the previous version
+func writeSupervisorConf(path string, merged *ini.File) error {
+	previous, err := ini.Load(path)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("[SUPERVISOR] Previous %s unreadable, not diffing: %v\n", path, err)
+	}
+	if previous != nil {
+		diff := ini.Diff(previous, merged)
+		if len(diff) == 0 {
+			fmt.Printf("[SUPERVISOR] %s unchanged\n", path)
+		}
+		for _, line := range diff {
+			fmt.Printf("[SUPERVISOR] %s\n", line)
+		}
+	}
+	return internalExec.WriteFileAtomic(path, merged.Bytes(), 0644)
+}
+
+// supervisorRPCConf enables supervisord's XML-RPC interface on a unix socket
+// goftw's user can reach, even though supervisord runs as root
+func supervisorRPCConf() *ini.File {
+	file := &ini.File{Source: "<goftw xml-rpc>"}
+	server := file.AddSection("unix_http_server")
+	server.Set("file", supervisorSocket)
+	server.Set("chmod", "0770")
+	if u, err := user.Current(); err == nil {
+		if g, err := user.LookupGroupId(u.Gid); err == nil {
+			server.Set("chown", u.Username+":"+g.Name)
+		}
+	}
+	file.AddSection("rpcinterface:supervisor").Set("supervisor.rpcinterface_factory", "supervisor.rpcinterface:make_main_rpcinterface")
+	file.AddSection("supervisorctl").Set("serverurl", "unix://"+supervisorSocket)
+	return file
+}
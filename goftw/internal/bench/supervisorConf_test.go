@@ -0,0 +1,55 @@
+package bench
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"goftw/internal/ini"
+)
+
+func parseConf(t *testing.T, source, input string) *ini.File {
+	t.Helper()
+	file, err := ini.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	file.Source = source
+	return file
+}
+
+// TestMergeSupervisorConf tests the layering of head patch, RPC sections, bench config and overlays
+func TestMergeSupervisorConf(t *testing.T) {
+	head := parseConf(t, "head.patch.conf", "[supervisord]\nnodaemon=true\n\n[program:nginx]\ncommand=nginx\n")
+	generated := parseConf(t, "supervisor.conf", "[program:frappe-bench-frappe-web]\ncommand=gunicorn\npriority=4\n")
+	overlay := parseConf(t, "10-web.conf", "[program:frappe-bench-frappe-web]\npriority=2\n")
+
+	merged, err := mergeSupervisorConf(head, generated, supervisorRPCConf(), []*ini.File{overlay})
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if merged.Section("unix_http_server") == nil || merged.Section("rpcinterface:supervisor") == nil {
+		t.Fatalf("EXPECTED xml-rpc sections GOT %+v", merged.Sections)
+	}
+	if got := merged.Section("program:frappe-bench-frappe-web").Value("priority", ""); got != "2" {
+		t.Fatalf("EXPECTED overlaid priority 2 GOT %s", got)
+	}
+
+	// A head patch with its own socket keeps it
+	head.AddSection("unix_http_server").Set("file", "/tmp/own.sock")
+	merged, err = mergeSupervisorConf(head, generated, supervisorRPCConf(), nil)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if got := merged.Section("unix_http_server").Value("file", ""); got != "/tmp/own.sock" {
+		t.Fatalf("EXPECTED /tmp/own.sock GOT %s", got)
+	}
+
+	// bench redefining a program of the head patch is a conflict
+	clash := parseConf(t, "supervisor.conf", "[program:nginx]\ncommand=/usr/sbin/nginx\n")
+	_, err = mergeSupervisorConf(head, clash, nil, nil)
+	var conflict *ini.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("EXPECTED *ini.ConflictError GOT %v", err)
+	}
+}
@@ -7,6 +7,10 @@ import (
 
 // ManualUpdate runs all safe update steps in sequence.
 func (b *Bench) ManualUpdate() error {
+	return b.runJob("update", b.manualUpdate)
+}
+
+func (b *Bench) manualUpdate() error {
 	// STEP 1: Update Apps
 	fmt.Println("[APPS] Upgrading installed apps")
 	if err := b.GitPullOnApps(); err != nil {
@@ -0,0 +1,197 @@
+package certs
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	internalExec "goftw/internal/fns"
+
+	"golang.org/x/crypto/acme"
+)
+
+// tokenRegex matches the base64url tokens of HTTP-01 challenges, which
+// become file names under the webroot
+var tokenRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ACME obtains certificates from an ACME server such as Let's Encrypt or
+// Pebble, answering HTTP-01 challenges with files under Webroot that nginx
+// serves at /.well-known/acme-challenge/
+type ACME struct {
+	Client  *acme.Client
+	Webroot string
+	Email   string
+
+	mu         sync.Mutex
+	registered bool
+}
+
+// NewACME returns an ACME issuer for the directory URL, Let's Encrypt when
+// empty. The account key is kept at accountKey and created on first use.
+// caRoots, when set, is a PEM file of the roots the server's TLS certificate
+// is checked against, e.g. Pebble's test CA.
+func NewACME(directory, caRoots, accountKey, webroot, email string) (*ACME, error) {
+	if directory == "" {
+		directory = acme.LetsEncryptURL
+	}
+	key, err := loadAccountKey(accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load acme account key: %v", err)
+	}
+	client := &acme.Client{Key: key, DirectoryURL: directory, UserAgent: "goftw"}
+	if caRoots != "" {
+		data, err := os.ReadFile(caRoots)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read acme ca roots: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", caRoots)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+	return &ACME{Client: client, Webroot: webroot, Email: email}, nil
+}
+
+// loadAccountKey reads the account key at path, creating it when missing
+func loadAccountKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return DecodeKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	data, err = EncodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := internalExec.WriteFileAtomic(path, data, 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// register creates the ACME account, accepting the terms of service. A
+// failed registration is retried with the next certificate.
+func (a *ACME) register(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.registered {
+		return nil
+	}
+	account := &acme.Account{}
+	if a.Email != "" {
+		account.Contact = []string{"mailto:" + a.Email}
+	}
+	_, err := a.Client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("failed to register acme account: %v", err)
+	}
+	a.registered = true
+	return nil
+}
+
+// Obtain orders a certificate for names, proving control of each through
+// HTTP-01, and returns the chain and its new key, both PEM encoded
+func (a *ACME) Obtain(ctx context.Context, names []string) ([]byte, []byte, error) {
+	if err := a.register(ctx); err != nil {
+		return nil, nil, err
+	}
+	order, err := a.Client.AuthorizeOrder(ctx, acme.DomainIDs(names...))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to order certificate: %v", err)
+	}
+	for _, url := range order.AuthzURLs {
+		if err := a.authorize(ctx, url); err != nil {
+			return nil, nil, err
+		}
+	}
+	if order, err = a.Client.WaitOrder(ctx, order.URI); err != nil {
+		return nil, nil, fmt.Errorf("order not ready: %v", err)
+	}
+
+	key, err := NewKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, _, err := a.Client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to finalize order: %v", err)
+	}
+	keyPEM, err := EncodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeChain(der), keyPEM, nil
+}
+
+// authorize completes the HTTP-01 challenge of one authorization
+func (a *ACME) authorize(ctx context.Context, url string) error {
+	authz, err := a.Client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %v", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no http-01 challenge offered for %s", authz.Identifier.Value)
+	}
+	if !tokenRegex.MatchString(challenge.Token) {
+		return fmt.Errorf("invalid http-01 token for %s", authz.Identifier.Value)
+	}
+
+	response, err := a.Client.HTTP01ChallengeResponse(challenge.Token)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(a.Webroot, filepath.FromSlash(a.Client.HTTP01ChallengePath(challenge.Token)))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(response), 0644); err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	if _, err := a.Client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge for %s: %v", authz.Identifier.Value, err)
+	}
+	if _, err := a.Client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorization of %s failed: %v", authz.Identifier.Value, err)
+	}
+	return nil
+}
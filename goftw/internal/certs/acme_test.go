@@ -0,0 +1,201 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeACME is a minimal RFC 8555 server that validates HTTP-01 challenges by
+// reading the webroot, as nginx would serve it
+type fakeACME struct {
+	t       *testing.T
+	url     string
+	webroot string
+
+	mu        sync.Mutex
+	names     []string
+	validated map[int]bool
+	cert      []byte
+}
+
+// payload decodes the payload of a JWS request body
+func (f *fakeACME) payload(r *http.Request, v interface{}) {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		f.t.Errorf("invalid jws: %v", err)
+		return
+	}
+	data, _ := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if v != nil && len(data) > 0 {
+		_ = json.Unmarshal(data, v)
+	}
+}
+
+func (f *fakeACME) order(status string) map[string]interface{} {
+	order := map[string]interface{}{"status": status, "finalize": f.url + "/finalize"}
+	var authz []string
+	for i := range f.names {
+		authz = append(authz, fmt.Sprintf("%s/authz/%d", f.url, i))
+	}
+	order["authorizations"] = authz
+	if status == "valid" {
+		order["certificate"] = f.url + "/cert"
+	}
+	return order
+}
+
+func (f *fakeACME) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	reply := func(status int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	var index int
+	switch {
+	case r.URL.Path == "/dir":
+		reply(200, map[string]string{"newNonce": f.url + "/nonce", "newAccount": f.url + "/account", "newOrder": f.url + "/order"})
+	case r.URL.Path == "/nonce":
+		w.WriteHeader(200)
+	case r.URL.Path == "/account":
+		f.payload(r, nil)
+		w.Header().Set("Location", f.url+"/account/1")
+		reply(201, map[string]string{"status": "valid"})
+	case r.URL.Path == "/order":
+		var body struct {
+			Identifiers []struct{ Value string } `json:"identifiers"`
+		}
+		f.payload(r, &body)
+		for _, id := range body.Identifiers {
+			f.names = append(f.names, id.Value)
+		}
+		w.Header().Set("Location", f.url+"/order/1")
+		reply(201, f.order("pending"))
+	case r.URL.Path == "/order/1":
+		f.payload(r, nil)
+		status := "ready"
+		if f.cert != nil {
+			status = "valid"
+		}
+		w.Header().Set("Location", f.url+"/order/1")
+		reply(200, f.order(status))
+	case sscanf(r.URL.Path, "/authz/%d", &index):
+		f.payload(r, nil)
+		status := "pending"
+		if f.validated[index] {
+			status = "valid"
+		}
+		reply(200, map[string]interface{}{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": f.names[index]},
+			"challenges": []map[string]string{{"type": "http-01", "url": fmt.Sprintf("%s/chal/%d", f.url, index), "token": fmt.Sprintf("token-%d", index), "status": status}},
+		})
+	case sscanf(r.URL.Path, "/chal/%d", &index):
+		f.payload(r, nil)
+		token := fmt.Sprintf("token-%d", index)
+		served, err := os.ReadFile(filepath.Join(f.webroot, ".well-known", "acme-challenge", token))
+		if err != nil || !strings.HasPrefix(string(served), token+".") {
+			reply(400, map[string]string{"type": "urn:ietf:params:acme:error:unauthorized", "detail": "challenge not served"})
+			return
+		}
+		f.validated[index] = true
+		reply(200, map[string]string{"type": "http-01", "url": r.URL.String(), "token": token, "status": "valid"})
+	case r.URL.Path == "/finalize":
+		var body struct {
+			CSR string `json:"csr"`
+		}
+		f.payload(r, &body)
+		der, _ := base64.RawURLEncoding.DecodeString(body.CSR)
+		f.cert = f.issue(der)
+		w.Header().Set("Location", f.url+"/order/1")
+		reply(200, f.order("valid"))
+	case r.URL.Path == "/cert":
+		f.payload(r, nil)
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(f.cert)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// issue signs the CSR with a throwaway CA
+func (f *fakeACME) issue(der []byte) []byte {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		f.t.Errorf("invalid csr: %v", err)
+		return nil
+	}
+	caKey, _ := NewKey()
+	ca := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "fake acme"}, NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour), IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+	caDER, _ := x509.CreateCertificate(nil, ca, ca, caKey.Public(), caKey)
+	ca, _ = x509.ParseCertificate(caDER)
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: csr.Subject, DNSNames: csr.DNSNames, NotBefore: time.Now(), NotAfter: time.Now().Add(90 * 24 * time.Hour)}
+	leafDER, err := x509.CreateCertificate(nil, leaf, ca, csr.PublicKey, caKey)
+	if err != nil {
+		f.t.Errorf("failed to issue: %v", err)
+		return nil
+	}
+	return append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER}), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+}
+
+func sscanf(path, format string, index *int) bool {
+	_, err := fmt.Sscanf(path, format, index)
+	return err == nil
+}
+
+// TestObtain tests a full HTTP-01 order: account, challenges through the webroot, finalize
+func TestObtain(t *testing.T) {
+	dir := t.TempDir()
+	fake := &fakeACME{t: t, webroot: filepath.Join(dir, "webroot"), validated: map[int]bool{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fake.url = server.URL
+
+	issuer, err := NewACME(server.URL+"/dir", "", filepath.Join(dir, "account.key"), fake.webroot, "ops@example.com")
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	certPEM, keyPEM, err := issuer.Obtain(ctx, []string{"shop.example.com", "www.shop.example.com"})
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+
+	cert, err := Parse(certPEM)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if !reflect.DeepEqual(cert.Names, []string{"shop.example.com", "www.shop.example.com"}) || cert.Issuer != "fake acme" || cert.SelfSigned {
+		t.Fatalf("EXPECTED a fake acme certificate for both names GOT %+v", cert)
+	}
+	if _, err := DecodeKey(keyPEM); err != nil {
+		t.Fatalf("EXPECTED a valid key GOT %v", err)
+	}
+	if files, _ := os.ReadDir(filepath.Join(fake.webroot, ".well-known", "acme-challenge")); len(files) != 0 {
+		t.Fatalf("EXPECTED challenge files removed GOT %d left", len(files))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "account.key")); err != nil {
+		t.Fatalf("EXPECTED the account key saved GOT %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package certs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// Certificate describes the leaf of a PEM certificate chain
+type Certificate struct {
+	Names      []string
+	Issuer     string
+	NotBefore  time.Time
+	NotAfter   time.Time
+	SelfSigned bool
+}
+
+// Expires reports whether the certificate is no longer valid at now+within
+func (c *Certificate) Expires(now time.Time, within time.Duration) bool {
+	return !now.Add(within).Before(c.NotAfter)
+}
+
+// Parse describes the first certificate of a PEM chain
+func Parse(data []byte) (*Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return describe(cert), nil
+}
+
+// describe summarises a parsed certificate
+func describe(cert *x509.Certificate) *Certificate {
+	return &Certificate{
+		Names:      cert.DNSNames,
+		Issuer:     cert.Issuer.CommonName,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		SelfSigned: bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil,
+	}
+}
+
+// Load describes the certificate chain at path
+func Load(path string) (*Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// NewKey returns a new P-256 private key
+func NewKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// EncodeKey encodes a private key as PEM
+func EncodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// DecodeKey decodes a PEM private key written by EncodeKey, or an EC key
+func DecodeKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM private key found")
+	}
+	if block.Type == "EC PRIVATE KEY" {
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// encodeChain encodes DER certificates as a PEM chain
+func encodeChain(der [][]byte) []byte {
+	var buf bytes.Buffer
+	for _, cert := range der {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	}
+	return buf.Bytes()
+}
+
+// SelfSigned returns a certificate for names signed by its own key, valid
+// for validity, and that key, both PEM encoded
+func SelfSigned(names []string, validity time.Duration) ([]byte, []byte, error) {
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no names to certify")
+	}
+	key, err := NewKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: names[0], Organization: []string{"goftw self-signed"}},
+		DNSNames:              names,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := EncodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeChain([][]byte{der}), keyPEM, nil
+}
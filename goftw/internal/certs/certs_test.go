@@ -0,0 +1,75 @@
+package certs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSelfSigned tests that self-signed certificates cover their names and validity
+func TestSelfSigned(t *testing.T) {
+	certPEM, keyPEM, err := SelfSigned([]string{"hrms.localhost", "www.hrms.localhost"}, 90*24*time.Hour)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	cert, err := Parse(certPEM)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if !reflect.DeepEqual(cert.Names, []string{"hrms.localhost", "www.hrms.localhost"}) || !cert.SelfSigned {
+		t.Fatalf("EXPECTED a self-signed certificate for both names GOT %+v", cert)
+	}
+	if days := time.Until(cert.NotAfter).Hours() / 24; days < 89 || days > 90 {
+		t.Fatalf("EXPECTED 90 days of validity GOT %.1f", days)
+	}
+	if cert.Expires(time.Now(), 30*24*time.Hour) || !cert.Expires(time.Now(), 91*24*time.Hour) {
+		t.Fatalf("EXPECTED expiry only within 91 days GOT %v", cert.NotAfter)
+	}
+	if _, err := DecodeKey(keyPEM); err != nil {
+		t.Fatalf("EXPECTED a valid key GOT %v", err)
+	}
+	if _, _, err := SelfSigned(nil, time.Hour); err == nil {
+		t.Fatalf("EXPECTED error for no names GOT nil")
+	}
+}
+
+// TestStore tests that certificates are stored per name and invalid input is refused
+func TestStore(t *testing.T) {
+	store := Store{Dir: t.TempDir()}
+	certPEM, keyPEM, err := SelfSigned([]string{"a.localhost"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if store.Has("a.localhost") {
+		t.Fatalf("EXPECTED no certificate before saving GOT one")
+	}
+	if err := store.Save("a.localhost", certPEM, keyPEM); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if !store.Has("a.localhost") {
+		t.Fatalf("EXPECTED a certificate after saving GOT none")
+	}
+	cert, err := store.Load("a.localhost")
+	if err != nil || cert.Names[0] != "a.localhost" {
+		t.Fatalf("EXPECTED the saved certificate GOT %+v, %v", cert, err)
+	}
+	_, key := store.Paths("a.localhost")
+	if info, err := os.Stat(key); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("EXPECTED a 0600 key GOT %v, %v", info, err)
+	}
+	if filepath.Dir(key) != filepath.Join(store.Dir, "a.localhost") {
+		t.Fatalf("EXPECTED the key under the name's directory GOT %s", key)
+	}
+
+	for _, name := range []string{"", "..", "a/b"} {
+		if err := store.Save(name, certPEM, keyPEM); err == nil {
+			t.Fatalf("EXPECTED error for name %q GOT nil", name)
+		}
+	}
+	if err := store.Save("b.localhost", []byte("not a certificate"), keyPEM); err == nil {
+		t.Fatalf("EXPECTED error for an invalid certificate GOT nil")
+	}
+}
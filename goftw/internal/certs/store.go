@@ -0,0 +1,72 @@
+package certs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	internalExec "goftw/internal/fns"
+)
+
+// Store keeps one certificate per name under Dir/{name}/, in the
+// fullchain.pem and privkey.pem layout nginx is pointed at
+type Store struct {
+	Dir string
+}
+
+// Paths returns where the certificate chain and key of name are kept
+func (s Store) Paths(name string) (string, string) {
+	dir := filepath.Join(s.Dir, name)
+	return filepath.Join(dir, "fullchain.pem"), filepath.Join(dir, "privkey.pem")
+}
+
+// Load describes the stored certificate of name
+func (s Store) Load(name string) (*Certificate, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	cert, _ := s.Paths(name)
+	return Load(cert)
+}
+
+// Has reports whether both files of name's certificate exist
+func (s Store) Has(name string) bool {
+	if checkName(name) != nil {
+		return false
+	}
+	cert, key := s.Paths(name)
+	for _, path := range []string{cert, key} {
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Save stores the certificate chain and key of name. The key is written
+// first, so nginx never sees a new chain next to an old key for long.
+func (s Store) Save(name string, certPEM, keyPEM []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if _, err := Parse(certPEM); err != nil {
+		return fmt.Errorf("refusing to store an invalid certificate for %s: %v", name, err)
+	}
+	cert, key := s.Paths(name)
+	if err := os.MkdirAll(filepath.Dir(cert), 0700); err != nil {
+		return err
+	}
+	if err := internalExec.WriteFileAtomic(key, keyPEM, 0600); err != nil {
+		return err
+	}
+	return internalExec.WriteFileAtomic(cert, certPEM, 0644)
+}
+
+// checkName refuses names that would leave the store's directory
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid certificate name %q", name)
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"goftw/internal/whoiam"
+	"strings"
 	"time"
 )
 
+// pingTimeout bounds each attempt of WaitForDB
+const pingTimeout = 10 * time.Second
+
 // Config holds DB connection info
 type Config struct {
 	Host     string
@@ -24,15 +29,9 @@ func WaitForDB(cfg Config) error {
 
 	fmt.Printf("[Database] Waiting for MariaDB at %s:%s...\n", cfg.Host, cfg.Port)
 	for {
-		_, err := whoiam.ExecRunSwallowIO(
-			"mysqladmin",
-			"ping",
-			"-h", cfg.Host,
-			"-P", cfg.Port,
-			"-u", cfg.User,
-			fmt.Sprintf("-p%s", cfg.Password),
-			"--silent",
-		)
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := Ping(ctx, cfg)
+		cancel()
 		// if err != nil {
 		// 	return err
 		// }
@@ -46,3 +45,21 @@ func WaitForDB(cfg Config) error {
 		time.Sleep(2 * time.Second)
 	}
 }
+
+// Ping checks once whether the database is reachable, killing mysqladmin when ctx is done
+func Ping(ctx context.Context, cfg Config) error {
+	out, err := whoiam.ExecRunSwallowIOContext(ctx,
+		"mysqladmin",
+		"ping",
+		"-h", cfg.Host,
+		"-P", cfg.Port,
+		"-u", cfg.User,
+		fmt.Sprintf("-p%s", cfg.Password),
+		"--connect-timeout=3",
+		"--silent",
+	)
+	if err != nil {
+		return fmt.Errorf("mysqladmin ping failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -9,7 +9,25 @@ type App struct {
 	Raw     string // original line
 }
 
-type AppForReact struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+// AppMeta is metadata read from an app's packaging files and hooks.py.
+type AppMeta struct {
+	Name         string   `json:"name"`
+	Title        string   `json:"title"`
+	Version      string   `json:"version"`
+	Description  string   `json:"description"`
+	Publisher    string   `json:"publisher"`
+	LogoURL      string   `json:"logo_url"`
+	RequiredApps []string `json:"required_apps"`
+}
+
+// BenchApp describes an app checked out in bench/apps, as read from its git repository.
+type BenchApp struct {
+	Name    string   `json:"name"`
+	Remote  string   `json:"remote"`
+	Branch  string   `json:"branch"`
+	Commit  string   `json:"commit"`
+	Dirty   bool     `json:"dirty"`
+	Version string   `json:"version"`
+	Sites   []string `json:"sites"`
+	Meta    AppMeta  `json:"meta"`
 }
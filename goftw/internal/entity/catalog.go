@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogApp is an app offered to the dashboard, annotated with its state in the bench.
+type CatalogApp struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Repo        string `json:"repo" yaml:"repo"`
+	Branch      string `json:"branch" yaml:"branch"`
+	Asset       string `json:"asset" yaml:"asset"`
+	Category    string `json:"category" yaml:"category"`
+	Fetched     bool   `json:"fetched" yaml:"-"`
+	Installs    int    `json:"installs" yaml:"-"`
+}
+
+type Catalog struct {
+	Apps []CatalogApp `json:"apps" yaml:"apps"`
+}
+
+// LoadCatalog loads and parses a catalog file, as YAML for .yaml/.yml files and JSON otherwise
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Catalog
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
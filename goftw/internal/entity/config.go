@@ -9,6 +9,8 @@ type Common_Site_Config struct {
 	RedisQueue    string `json:"redis_queue"`
 	RedisCache    string `json:"redis_cache"`
 	RedisSocketIO string `json:"redis_socketio"`
+	WebserverPort int    `json:"webserver_port"`
+	SocketIOPort  int    `json:"socketio_port"`
 }
 
 // LoadCommonSitesConfig loads and parses common_site_config.json
@@ -23,3 +25,42 @@ func LoadCommonSitesConfig(path string) (*Common_Site_Config, error) {
 	}
 	return &cfg, nil
 }
+
+// SiteConfig is the part of a site's site_config.json that decides how it is served
+type SiteConfig struct {
+	Domains           []SiteDomain `json:"domains"`
+	SSLCertificate    string       `json:"ssl_certificate"`
+	SSLCertificateKey string       `json:"ssl_certificate_key"`
+}
+
+// SiteDomain is a custom domain added with `bench setup add-domain`
+type SiteDomain struct {
+	Domain            string `json:"domain"`
+	SSLCertificate    string `json:"ssl_certificate,omitempty"`
+	SSLCertificateKey string `json:"ssl_certificate_key,omitempty"`
+}
+
+// UnmarshalJSON accepts both forms bench writes: a plain name, or an object
+// when the domain has its own certificate
+func (d *SiteDomain) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*d = SiteDomain{Domain: name}
+		return nil
+	}
+	type plain SiteDomain
+	return json.Unmarshal(data, (*plain)(d))
+}
+
+// LoadSiteConfig loads and parses a site's site_config.json
+func LoadSiteConfig(path string) (*SiteConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg SiteConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
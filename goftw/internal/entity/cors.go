@@ -0,0 +1,12 @@
+package entity
+
+// CORS is the cross-origin policy of the goftw API, from the "cors" section of instance.json.
+// Origins are exact ("https://dash.example.com"), wildcard subdomains
+// ("https://*.example.com") or "*" for any origin.
+type CORS struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"` // seconds preflight responses may be cached
+}
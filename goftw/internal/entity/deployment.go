@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// DeploymentStatus is the state of the WSGI process goftw manages.
+type DeploymentStatus struct {
+	Mode               string           `json:"mode"`    // production, development or shell
+	Process            string           `json:"process"` // supervisord, bench start or service.sh
+	State              string           `json:"state"`   // stopped, starting, running, exited, restarting, crash_loop
+	PID                int              `json:"pid,omitempty"`
+	StartedAt          *time.Time       `json:"started_at,omitempty"`
+	ExitedAt           *time.Time       `json:"exited_at,omitempty"`
+	ExitCode           *int             `json:"exit_code,omitempty"`
+	Error              string           `json:"error,omitempty"`
+	Restarts           int              `json:"restarts"`
+	AutoRestart        bool             `json:"auto_restart"`
+	ConsecutiveCrashes int              `json:"consecutive_crashes"`
+	NextRestart        *time.Time       `json:"next_restart,omitempty"`
+	Exits              []DeploymentExit `json:"exits"`
+}
+
+// DeploymentExit records one exit of the WSGI process.
+type DeploymentExit struct {
+	At            time.Time `json:"at"`
+	Code          int       `json:"code"`
+	Reason        string    `json:"reason"` // e.g. "exit status 1", "signal: killed"
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	Requested     bool      `json:"requested"` // stopped by goftw rather than crashed
+}
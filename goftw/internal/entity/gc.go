@@ -0,0 +1,15 @@
+package entity
+
+// GCCandidate is an app in bench/apps that no site uses.
+type GCCandidate struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+	Size  string `json:"size"`
+}
+
+// GCPlan lists the apps a garbage collection would remove and the space it would reclaim.
+type GCPlan struct {
+	Apps         []GCCandidate `json:"apps"`
+	ReclaimBytes int64         `json:"reclaim_bytes"`
+	Reclaim      string        `json:"reclaim"`
+}
@@ -1,8 +1,15 @@
 package entity
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"sync"
+
+	"goftw/internal/fns"
 )
 
 type Instance struct {
@@ -10,9 +17,15 @@ type Instance struct {
 	ServerName   string `json:"server_name"`
 	FrappeBranch string `json:"frappe_branch"`
 	// BenchName          string         `json:"frappe_bench"`
-	DropAbandonedSites bool   `json:"drop_abandoned_sites"`
-	RunSitesManager    bool   `json:"run_sites_manager"`
-	Sites              []Site `json:"instance_sites"`
+	// Supervisor runs production programs with "supervisord" (default) or "native", goftw's built-in supervisor
+	Supervisor         string  `json:"supervisor"`
+	Scaling            Scaling `json:"scaling"`
+	DropAbandonedSites bool    `json:"drop_abandoned_sites"`
+	RunSitesManager    bool    `json:"run_sites_manager"`
+	Sites              []Site  `json:"instance_sites"`
+	CORS               CORS    `json:"cors"`
+	Proxy              Proxy   `json:"proxy"`
+	TLS                TLS     `json:"tls"`
 }
 
 // LoadInstance loads and parses instance.json
@@ -31,5 +44,188 @@ func LoadInstance(path string) (*Instance, error) {
 	if cfg.Deployment == "" {
 		cfg.Deployment = "develop"
 	}
+	if err := cfg.Scaling.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scaling: %w", err)
+	}
 	return &cfg, nil
 }
+
+// Copy returns a deep copy of cfg, safe to read while cfg changes
+func (cfg *Instance) Copy() Instance {
+	c := *cfg
+	c.Sites = slices.Clone(cfg.Sites)
+	for i := range c.Sites {
+		site := &c.Sites[i]
+		site.Apps = slices.Clone(site.Apps)
+		site.Domains = slices.Clone(site.Domains)
+		if site.Nginx != nil {
+			nginx := *site.Nginx
+			site.Nginx = &nginx
+		}
+	}
+	c.Scaling.Workers = maps.Clone(cfg.Scaling.Workers)
+	if cfg.Scaling.Scheduler != nil {
+		scheduler := *cfg.Scaling.Scheduler
+		c.Scaling.Scheduler = &scheduler
+	}
+	c.CORS.AllowedOrigins = slices.Clone(cfg.CORS.AllowedOrigins)
+	c.CORS.AllowedMethods = slices.Clone(cfg.CORS.AllowedMethods)
+	c.CORS.AllowedHeaders = slices.Clone(cfg.CORS.AllowedHeaders)
+	return c
+}
+
+// instanceFileMu serialises changes to instance.json, each a read-modify-write
+var instanceFileMu sync.Mutex
+
+// SetInstanceKey replaces one top-level key of the instance.json at path with
+// value. Every other key, including keys goftw does not know, is kept in
+// place and defaults filled in by LoadInstance are not written.
+func SetInstanceKey(path, key string, value interface{}) error {
+	return updateInstance(path, func(obj *object) error {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		obj.set(key, raw)
+		return nil
+	})
+}
+
+// SetInstanceSiteKey replaces one key of the site's entry in instance_sites,
+// like SetInstanceKey; a nil value removes the key
+func SetInstanceSiteKey(path, site, key string, value interface{}) error {
+	return updateInstance(path, func(obj *object) error {
+		var sites []json.RawMessage
+		if err := json.Unmarshal(obj.values["instance_sites"], &sites); err != nil {
+			return fmt.Errorf("failed to parse instance_sites: %w", err)
+		}
+		listed := false
+		for i, raw := range sites {
+			entry, err := parseObject(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse instance_sites: %w", err)
+			}
+			var name string
+			if err := json.Unmarshal(entry.values["site_name"], &name); err != nil || name != site {
+				continue
+			}
+			listed = true
+			if value == nil {
+				entry.remove(key)
+			} else {
+				data, err := json.Marshal(value)
+				if err != nil {
+					return err
+				}
+				entry.set(key, data)
+			}
+			sites[i] = entry.marshal()
+		}
+		if !listed {
+			return fmt.Errorf("%s is not listed in instance_sites", site)
+		}
+		data, err := json.Marshal(sites)
+		if err != nil {
+			return err
+		}
+		obj.set("instance_sites", data)
+		return nil
+	})
+}
+
+// updateInstance applies change to the top-level object of the instance.json
+// at path and writes it back with four-space indentation
+func updateInstance(path string, change func(obj *object) error) error {
+	instanceFileMu.Lock()
+	defer instanceFileMu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	obj, err := parseObject(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := change(obj); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, obj.marshal(), "", "    "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	return writeInstance(path, out.Bytes())
+}
+
+// writeInstance replaces the file at path, atomically when its directory is
+// writable and in place otherwise, e.g. for a bind-mounted /instance.json
+func writeInstance(path string, data []byte) error {
+	if err := fns.WriteFileAtomic(path, data, 0644); err == nil {
+		return nil
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// object is a JSON object that keeps its keys in order
+type object struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+// parseObject parses a JSON object, keeping its keys in order
+func parseObject(data []byte) (*object, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	obj := &object{values: map[string]json.RawMessage{}}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		obj.set(tok.(string), raw)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// set replaces the value of key, appending it when it is new
+func (o *object) set(key string, raw json.RawMessage) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = raw
+}
+
+// remove deletes key, if present
+func (o *object) remove(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	o.keys = slices.DeleteFunc(o.keys, func(k string) bool { return k == key })
+	delete(o.values, key)
+}
+
+// marshal writes the object compactly, in key order
+func (o *object) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		name, _ := json.Marshal(key)
+		buf.Write(name)
+		buf.WriteString(":")
+		buf.Write(o.values[key])
+	}
+	buf.WriteString("}")
+	return buf.Bytes()
+}
@@ -0,0 +1,214 @@
+package entity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSetInstanceKey tests that only the given key of instance.json changes
+func TestSetInstanceKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		key      string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:  "replace keeps unknown keys and order",
+			file:  `{"instance_sites": [{"site_name": "a.localhost", "apps": ["erpnext"], "owner": "ops"}], "scaling": {}, "x-notes": "keep me"}`,
+			key:   "scaling",
+			value: Scaling{Gunicorn: GunicornScaling{Workers: 4}},
+			expected: `{
+    "instance_sites": [
+        {
+            "site_name": "a.localhost",
+            "apps": [
+                "erpnext"
+            ],
+            "owner": "ops"
+        }
+    ],
+    "scaling": {
+        "gunicorn": {
+            "workers": 4
+        }
+    },
+    "x-notes": "keep me"
+}
+`,
+		},
+		{
+			name:  "new key is appended without defaults",
+			file:  `{"run_sites_manager": true}`,
+			key:   "scaling",
+			value: Scaling{Workers: map[string]int{"long": 0}},
+			expected: `{
+    "run_sites_manager": true,
+    "scaling": {
+        "gunicorn": {},
+        "workers": {
+            "long": 0
+        }
+    }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "instance.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetInstanceKey(path, tt.key, tt.value); err != nil {
+				t.Fatalf("EXPECTED no error GOT %v", err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.expected {
+				t.Fatalf("UNEXPECTED instance.json\nEXPECTED:\n%s\nGOT:\n%s", tt.expected, got)
+			}
+			if _, err := LoadInstance(path); err != nil {
+				t.Fatalf("EXPECTED the result to load GOT %v", err)
+			}
+			if files, _ := os.ReadDir(filepath.Dir(path)); len(files) != 1 {
+				t.Fatalf("EXPECTED no temporary files left GOT %d files", len(files))
+			}
+		})
+	}
+}
+
+// TestSetInstanceKeyInvalid tests that a file that is not a JSON object is left alone
+func TestSetInstanceKeyInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instance.json")
+	if err := os.WriteFile(path, []byte(`["not", "an", "object"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetInstanceKey(path, "scaling", Scaling{}); err == nil {
+		t.Fatal("EXPECTED an error GOT nil")
+	}
+	if got, _ := os.ReadFile(path); string(got) != `["not", "an", "object"]` {
+		t.Fatalf("EXPECTED the file unchanged GOT %s", got)
+	}
+}
+
+// TestSetInstanceSiteKey tests that only the given key of the listed site changes
+func TestSetInstanceSiteKey(t *testing.T) {
+	file := `{"deployment": "production", "instance_sites": [{"site_name": "a.localhost", "apps": ["erpnext"], "x-team": "sales"}, {"site_name": "b.localhost", "apps": [], "domains": ["b.example.com"]}]}`
+	tests := []struct {
+		name     string
+		site     string
+		value    interface{}
+		expected string
+	}{
+		{
+			name:  "set keeps the other keys of the site",
+			site:  "a.localhost",
+			value: []string{"shop.example.com"},
+			expected: `{
+    "deployment": "production",
+    "instance_sites": [
+        {
+            "site_name": "a.localhost",
+            "apps": [
+                "erpnext"
+            ],
+            "x-team": "sales",
+            "domains": [
+                "shop.example.com"
+            ]
+        },
+        {
+            "site_name": "b.localhost",
+            "apps": [],
+            "domains": [
+                "b.example.com"
+            ]
+        }
+    ]
+}
+`,
+		},
+		{
+			name: "nil removes the key",
+			site: "b.localhost",
+			expected: `{
+    "deployment": "production",
+    "instance_sites": [
+        {
+            "site_name": "a.localhost",
+            "apps": [
+                "erpnext"
+            ],
+            "x-team": "sales"
+        },
+        {
+            "site_name": "b.localhost",
+            "apps": []
+        }
+    ]
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "instance.json")
+			if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := SetInstanceSiteKey(path, tt.site, "domains", tt.value); err != nil {
+				t.Fatalf("EXPECTED no error GOT %v", err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.expected {
+				t.Fatalf("UNEXPECTED instance.json\nEXPECTED:\n%s\nGOT:\n%s", tt.expected, got)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "instance.json")
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetInstanceSiteKey(path, "c.localhost", "domains", []string{"c.example.com"}); err == nil {
+		t.Fatal("EXPECTED an error for a site not listed GOT nil")
+	}
+	if got, _ := os.ReadFile(path); string(got) != file {
+		t.Fatalf("EXPECTED the file unchanged GOT %s", got)
+	}
+}
+
+// TestInstanceCopy tests that changing a copy leaves the instance alone
+func TestInstanceCopy(t *testing.T) {
+	scheduler := true
+	cfg := &Instance{
+		Sites: []Site{{
+			SiteName: "a.localhost",
+			Apps:     []string{"erpnext"},
+			Domains:  []string{"shop.example.com"},
+			Nginx:    &NginxOptions{ClientMaxBodySize: "100m"},
+		}},
+		Scaling: Scaling{Workers: map[string]int{"long": 2}, Scheduler: &scheduler},
+		CORS:    CORS{AllowedOrigins: []string{"https://dash.example.com"}},
+	}
+
+	c := cfg.Copy()
+	c.Sites[0].Apps[0] = "hrms"
+	c.Sites[0].Domains[0] = "erp.example.com"
+	c.Sites[0].Nginx.ClientMaxBodySize = "1m"
+	c.Scaling.Workers["long"] = 0
+	*c.Scaling.Scheduler = false
+	c.CORS.AllowedOrigins[0] = "*"
+
+	site := cfg.Sites[0]
+	if site.Apps[0] != "erpnext" || site.Domains[0] != "shop.example.com" || site.Nginx.ClientMaxBodySize != "100m" {
+		t.Fatalf("EXPECTED the site unchanged GOT %+v", site)
+	}
+	if cfg.Scaling.Workers["long"] != 2 || !*cfg.Scaling.Scheduler || cfg.CORS.AllowedOrigins[0] != "https://dash.example.com" {
+		t.Fatalf("EXPECTED scaling and cors unchanged GOT %+v %+v", cfg.Scaling, cfg.CORS)
+	}
+}
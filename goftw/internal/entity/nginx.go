@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// NginxStatus is the outcome of the last nginx config goftw generated
+type NginxStatus struct {
+	Config    string     `json:"config"` // the active config, the last one nginx -t accepted
+	Valid     bool       `json:"valid"`  // whether the last candidate was accepted
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`    // nginx -t output for a rejected candidate
+	Rejected  string     `json:"rejected,omitempty"` // where the rejected candidate was kept
+	Restored  bool       `json:"restored"`           // whether the previous config is still served
+}
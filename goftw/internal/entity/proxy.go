@@ -0,0 +1,9 @@
+package entity
+
+// Proxy configures the path-based proxy on port 2020 that serves each site under /{site}/
+type Proxy struct {
+	Builtin     bool   `json:"builtin"`      // serve it from goftw instead of the nginx-dynamic-proxy container
+	Listen      string `json:"listen"`       // the built-in proxy's address, default :2020
+	Upstream    string `json:"upstream"`     // the Frappe nginx as the proxy reaches it, default frappe:80 (127.0.0.1:80 built in)
+	DefaultSite string `json:"default_site"` // serves framework paths until a site was visited, default the first site
+}
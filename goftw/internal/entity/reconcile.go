@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// ReconcileStatus is the outcome of the last sites reconcile against instance.json.
+type ReconcileStatus struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	OK         bool      `json:"ok"`
+	Error      string    `json:"error,omitempty"`
+}
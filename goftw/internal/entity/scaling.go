@@ -0,0 +1,36 @@
+package entity
+
+import "fmt"
+
+// Scaling sizes the production programs. Unset values keep what
+// `bench setup supervisor` generates.
+type Scaling struct {
+	Gunicorn GunicornScaling `json:"gunicorn"`
+	// Workers maps a queue to its number of worker processes; 0 removes the queue's worker
+	Workers map[string]int `json:"workers,omitempty"`
+	// Scheduler false removes the scheduler program
+	Scheduler *bool `json:"scheduler,omitempty"`
+}
+
+// GunicornScaling sizes the web program
+type GunicornScaling struct {
+	Workers int `json:"workers,omitempty"`
+	Threads int `json:"threads,omitempty"`
+	Timeout int `json:"timeout,omitempty"` // seconds
+}
+
+// Validate rejects negative sizes and unnamed queues
+func (s Scaling) Validate() error {
+	if s.Gunicorn.Workers < 0 || s.Gunicorn.Threads < 0 || s.Gunicorn.Timeout < 0 {
+		return fmt.Errorf("gunicorn workers, threads and timeout must not be negative")
+	}
+	for queue, n := range s.Workers {
+		if queue == "" {
+			return fmt.Errorf("worker queue name must not be empty")
+		}
+		if n < 0 {
+			return fmt.Errorf("workers for queue %s must not be negative", queue)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,14 @@
 package entity
 
 type Site struct {
-	SiteName string   `json:"site_name"`
-	Apps     []string `json:"apps"`
+	SiteName string        `json:"site_name"`
+	Apps     []string      `json:"apps"`
+	Domains  []string      `json:"domains,omitempty"` // custom domains, see `bench setup add-domain`
+	Nginx    *NginxOptions `json:"nginx,omitempty"`
+}
+
+// NginxOptions tunes how nginx serves a site; zero values keep the defaults
+type NginxOptions struct {
+	ClientMaxBodySize string `json:"client_max_body_size,omitempty"` // e.g. "100m"
+	ProxyReadTimeout  int    `json:"proxy_read_timeout,omitempty"`   // seconds
 }
@@ -0,0 +1,19 @@
+package entity
+
+import "time"
+
+// ProgramStatus is the state of one supervised process, as reported by the
+// native supervisor or supervisord.
+type ProgramStatus struct {
+	Name          string     `json:"name"`  // process name, e.g. frappe-bench-frappe-short-worker-0
+	Group         string     `json:"group"` // e.g. frappe-bench-workers
+	State         string     `json:"state"` // STOPPED, STARTING, RUNNING, BACKOFF, STOPPING, EXITED, FATAL or UNKNOWN
+	PID           int        `json:"pid,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	StoppedAt     *time.Time `json:"stopped_at,omitempty"`
+	UptimeSeconds float64    `json:"uptime_seconds"`
+	ExitStatus    int        `json:"exit_status"`
+	Description   string     `json:"description,omitempty"` // last exit reason or spawn error
+	StdoutLogfile string     `json:"stdout_logfile,omitempty"`
+	StderrLogfile string     `json:"stderr_logfile,omitempty"`
+}
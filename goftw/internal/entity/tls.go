@@ -0,0 +1,52 @@
+package entity
+
+import "time"
+
+// TLS configures the certificates goftw manages for site names and custom
+// domains: ACME for public names, self-signed for .localhost names
+type TLS struct {
+	Enabled       bool   `json:"enabled"`
+	Email         string `json:"email"`             // ACME account contact
+	Directory     string `json:"directory"`         // ACME directory URL, default Let's Encrypt
+	CARoots       string `json:"ca_roots"`          // PEM file of roots trusted for the ACME server, e.g. Pebble's
+	RenewBefore   int    `json:"renew_before_days"` // renew certificates expiring within this many days, default 30
+	AlertDays     int    `json:"alert_days"`        // alert when a certificate expires within this many days, default 14
+	AlertFailures int    `json:"alert_failures"`    // alert after this many renewals of a name failed in a row, default 3
+	AlertWebhook  string `json:"alert_webhook"`     // URL alerts are POSTed to as JSON, optional
+}
+
+// CertificateStatus is the state of the certificate of a site name or domain
+type CertificateStatus struct {
+	Name        string            `json:"name"`
+	Site        string            `json:"site"`
+	Issuer      string            `json:"issuer"` // "acme", "self-signed", or "site_config" when the site brings its own
+	Certificate string            `json:"certificate,omitempty"`
+	NotAfter    *time.Time        `json:"not_after,omitempty"` // of the certificate file
+	DaysLeft    *int              `json:"days_left,omitempty"` // until the file or the served certificate expires, whichever is first
+	Live        *CertificateCheck `json:"live,omitempty"`      // the certificate nginx serves on 443
+	RenewedAt   *time.Time        `json:"renewed_at,omitempty"`
+	CheckedAt   *time.Time        `json:"checked_at,omitempty"`
+	AttemptedAt *time.Time        `json:"attempted_at,omitempty"` // of the last issuance
+	RetryAt     *time.Time        `json:"retry_at,omitempty"`     // when a failing name is tried again
+	Failures    int               `json:"failures"`               // renewals that failed in a row
+	Error       string            `json:"error,omitempty"`        // why the last issuance failed
+}
+
+// CertificateCheck is the outcome of a TLS handshake for a name
+type CertificateCheck struct {
+	NotAfter  *time.Time `json:"not_after,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt time.Time  `json:"checked_at"`
+}
+
+// CertificateAlert is raised once when a certificate gets close to expiry or
+// keeps failing to renew, and again if the condition returns after clearing
+type CertificateAlert struct {
+	Name     string    `json:"name"`
+	Site     string    `json:"site"`
+	Reason   string    `json:"reason"` // "expiring" or "renewal_failing"
+	Message  string    `json:"message"`
+	DaysLeft *int      `json:"days_left,omitempty"`
+	Failures int       `json:"failures,omitempty"`
+	At       time.Time `json:"at"`
+}
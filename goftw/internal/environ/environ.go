@@ -6,6 +6,12 @@ var (
 	frappeHome        = os.Getenv("FRAPPE_HOME")
 	instanceFile      = os.Getenv("INSTANCE_JSON_SOURCE")
 	commonSitesConfig = os.Getenv("COMMON_CONFIG_SOURCE")
+	catalogFile       = os.Getenv("APP_CATALOG_SOURCE")
+	apiKeysFile       = os.Getenv("GOFTW_API_KEYS_FILE")
+	apiPolicyFile     = os.Getenv("GOFTW_API_POLICY_FILE")
+	supervisorOverlay = os.Getenv("GOFTW_SUPERVISOR_OVERLAYS")
+	dynamicProxyConf  = os.Getenv("GOFTW_DYNAMIC_PROXY_CONF")
+	certsDir          = os.Getenv("GOFTW_CERTS_DIR")
 )
 
 // Helper to read env with default
@@ -55,3 +61,51 @@ func GetCommonSitesConfigPath() string {
 	}
 	return commonSitesConfig
 }
+
+// GetCatalogFile returns the path to the app catalog file, defaulting to /catalog.json.
+func GetCatalogFile() string {
+	if catalogFile == "" {
+		catalogFile = "/catalog.json"
+	}
+	return catalogFile
+}
+
+// GetAPIKeysFile returns the path to the hashed API keys file, defaulting to /api_keys.json.
+func GetAPIKeysFile() string {
+	if apiKeysFile == "" {
+		apiKeysFile = "/api_keys.json"
+	}
+	return apiKeysFile
+}
+
+// GetAPIPolicyFile returns the path to the API role policy file, defaulting to /api_policy.json.
+func GetAPIPolicyFile() string {
+	if apiPolicyFile == "" {
+		apiPolicyFile = "/api_policy.json"
+	}
+	return apiPolicyFile
+}
+
+// GetSupervisorOverlayDir returns the directory of supervisor config overlays, defaulting to /patches/supervisor.d.
+func GetSupervisorOverlayDir() string {
+	if supervisorOverlay == "" {
+		supervisorOverlay = "/patches/supervisor.d"
+	}
+	return supervisorOverlay
+}
+
+// GetDynamicProxyConf returns where the path-based proxy config is written, defaulting to config/proxy/dynamic-proxy.conf in the bench.
+func GetDynamicProxyConf() string {
+	if dynamicProxyConf == "" {
+		dynamicProxyConf = GetBenchPath() + "/config/proxy/dynamic-proxy.conf"
+	}
+	return dynamicProxyConf
+}
+
+// GetCertsDir returns where managed certificates are kept, defaulting to config/certs in the bench.
+func GetCertsDir() string {
+	if certsDir == "" {
+		certsDir = GetBenchPath() + "/config/certs"
+	}
+	return certsDir
+}
@@ -0,0 +1,49 @@
+package fns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteFileAtomic tests that a file is created or replaced without
+// leaving its temporary file behind
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "10-a.conf")
+
+	tests := []struct {
+		data string
+		perm os.FileMode
+	}{
+		{"[program:a]\ncommand=a\n", 0644},
+		{"[program:a]\ncommand=a --workers 4\n", 0600},
+	}
+	for _, tt := range tests {
+		if err := WriteFileAtomic(path, []byte(tt.data), tt.perm); err != nil {
+			t.Fatalf("EXPECTED no error GOT %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != tt.data {
+			t.Fatalf("EXPECTED %q GOT %q, %v", tt.data, got, err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != tt.perm {
+			t.Fatalf("EXPECTED mode %v GOT %v", tt.perm, info.Mode().Perm())
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 {
+			t.Fatalf("EXPECTED no temporary files left GOT %d entries", len(entries))
+		}
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "a.conf"), []byte("x"), 0644); err == nil {
+		t.Fatal("EXPECTED an error for a missing directory GOT nil")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("EXPECTED no temporary files left GOT %d entries", len(entries))
+	}
+}
@@ -0,0 +1,121 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check is a named readiness probe. Probe must return once ctx is done.
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// Result is the outcome of one probe
+type Result struct {
+	Name      string  `json:"name"`
+	OK        bool    `json:"ok"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Checker runs readiness probes and serves health endpoints
+type Checker struct {
+	Checks  []Check
+	Timeout time.Duration
+	// Reconcile returns the last reconcile outcome for /status, may be nil
+	Reconcile func() interface{}
+
+	startedAt time.Time
+}
+
+// NewChecker returns a checker with a per-probe timeout
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{Checks: checks, Timeout: timeout, startedAt: time.Now()}
+}
+
+// Run runs every probe concurrently. A probe that does not answer within the
+// timeout, or before ctx is done, fails and its context is cancelled.
+func (c *Checker) Run(ctx context.Context) ([]Result, bool) {
+	results := make([]Result, len(c.Checks))
+	var wg sync.WaitGroup
+	for i, check := range c.Checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			start := time.Now()
+			probeCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- check.Probe(probeCtx) }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-probeCtx.Done():
+				err = fmt.Errorf("timed out after %s", c.Timeout)
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+			}
+
+			results[i] = Result{
+				Name:      check.Name,
+				OK:        err == nil,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, check)
+	}
+	wg.Wait()
+
+	ready := true
+	for _, r := range results {
+		ready = ready && r.OK
+	}
+	return results, ready
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// HealthzHandler reports that the process is alive and serving
+func (c *Checker) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, 200, map[string]string{"status": "ok"})
+}
+
+// ReadyzHandler runs every probe and answers 503 if any fails
+func (c *Checker) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	results, ready := c.Run(r.Context())
+	status, code := "ready", 200
+	if !ready {
+		status, code = "not ready", 503
+	}
+	writeJSON(w, code, map[string]interface{}{"status": status, "checks": results})
+}
+
+// StatusHandler reports per-dependency latency, uptime and the last reconcile outcome
+func (c *Checker) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	results, ready := c.Run(r.Context())
+	resp := map[string]interface{}{
+		"ready":          ready,
+		"dependencies":   results,
+		"started_at":     c.startedAt,
+		"uptime_seconds": int64(time.Since(c.startedAt).Seconds()),
+		"last_reconcile": nil,
+	}
+	if c.Reconcile != nil {
+		resp["last_reconcile"] = c.Reconcile()
+	}
+	writeJSON(w, 200, resp)
+}
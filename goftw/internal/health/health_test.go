@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goftw/internal/whoiam"
+)
+
+// TestReadyz tests aggregation of passing, failing and hanging probes
+func TestReadyz(t *testing.T) {
+	ok := Check{Name: "ok", Probe: func(context.Context) error { return nil }}
+	failing := Check{Name: "failing", Probe: func(context.Context) error { return errors.New("down") }}
+	hanging := Check{Name: "hanging", Probe: func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() }}
+
+	tests := []struct {
+		name   string
+		checks []Check
+		status int
+		body   string
+	}{
+		{"all ok", []Check{ok}, 200, `"status":"ready"`},
+		{"one failing", []Check{ok, failing}, 503, `"error":"down"`},
+		{"timeout", []Check{hanging}, 503, "timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(50*time.Millisecond, tt.checks...)
+			rec := httptest.NewRecorder()
+			c.ReadyzHandler(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("EXPECTED STATUS %d, GOT %d", tt.status, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Fatalf("EXPECTED BODY TO CONTAIN %q, GOT %s", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestRunKillsHungProbe tests that a timed out probe's command is killed, not left running
+func TestRunKillsHungProbe(t *testing.T) {
+	exited := make(chan error, 1)
+	hung := Check{Name: "redis_queue", Probe: func(ctx context.Context) error {
+		_, err := whoiam.ExecRunSwallowIOContext(ctx, "sleep", "30")
+		exited <- err
+		return err
+	}}
+
+	start := time.Now()
+	results, ready := NewChecker(100*time.Millisecond, hung).Run(context.Background())
+	if ready || !strings.Contains(results[0].Error, "timed out") {
+		t.Fatalf("EXPECTED A TIMEOUT, GOT %+v", results)
+	}
+	select {
+	case err := <-exited:
+		if err == nil {
+			t.Fatal("EXPECTED THE COMMAND TO BE KILLED")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("EXPECTED THE PROBE TO RETURN ONCE ITS COMMAND WAS KILLED")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("EXPECTED THE COMMAND KILLED AT THE TIMEOUT, TOOK %s", elapsed)
+	}
+}
@@ -0,0 +1,188 @@
+package ini
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Key is one `name = value` line of a section
+type Key struct {
+	Name  string
+	Value string
+}
+
+// Section is a `[name]` block with its keys in file order
+type Section struct {
+	Name string
+	Keys []Key
+}
+
+// File is a parsed INI file. Sections keep their file order.
+type File struct {
+	Source   string // path it was loaded from, for error messages
+	Sections []*Section
+}
+
+// Load parses the INI file at path
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	file, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	file.Source = path
+	return file, nil
+}
+
+// Parse reads INI in the dialect supervisord accepts: `;` and `#` comment
+// lines, ` ;` inline comments, `=` or `:` separators and indented
+// continuation lines. Duplicate sections or keys are an error.
+func Parse(r io.Reader) (*File, error) {
+	file := &File{}
+	var section *Section
+	var last *Key
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+
+		// Indented lines continue the previous value
+		if raw[0] == ' ' || raw[0] == '\t' {
+			if last == nil {
+				return nil, fmt.Errorf("line %d: continuation without a key", lineNo)
+			}
+			last.Value += "\n" + stripInlineComment(line)
+			continue
+		}
+
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header %q", lineNo, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if file.Section(name) != nil {
+				return nil, fmt.Errorf("line %d: duplicate section [%s]", lineNo, name)
+			}
+			section = &Section{Name: name}
+			file.Sections = append(file.Sections, section)
+			last = nil
+			continue
+		}
+
+		if section == nil {
+			return nil, fmt.Errorf("line %d: key outside of a section", lineNo)
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected name = value, got %q", lineNo, line)
+		}
+		name := strings.TrimSpace(line[:sep])
+		if _, ok := section.Get(name); ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q in [%s]", lineNo, name, section.Name)
+		}
+		section.Keys = append(section.Keys, Key{Name: name, Value: stripInlineComment(strings.TrimSpace(line[sep+1:]))})
+		last = &section.Keys[len(section.Keys)-1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// stripInlineComment removes a ` ;` comment from the end of a value
+func stripInlineComment(value string) string {
+	if i := strings.Index(value, " ;"); i >= 0 {
+		value = value[:i]
+	}
+	if i := strings.Index(value, "\t;"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// Section returns the section called name, or nil
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Get returns the value of key name
+func (s *Section) Get(name string) (string, bool) {
+	for _, k := range s.Keys {
+		if k.Name == name {
+			return k.Value, true
+		}
+	}
+	return "", false
+}
+
+// Value returns the value of key name, or def when it is unset
+func (s *Section) Value(name, def string) string {
+	if value, ok := s.Get(name); ok {
+		return value
+	}
+	return def
+}
+
+// Set adds key name or replaces its value
+func (s *Section) Set(name, value string) {
+	for i := range s.Keys {
+		if s.Keys[i].Name == name {
+			s.Keys[i].Value = value
+			return
+		}
+	}
+	s.Keys = append(s.Keys, Key{Name: name, Value: value})
+}
+
+// AddSection appends an empty section called name, or returns the existing one
+func (f *File) AddSection(name string) *Section {
+	if s := f.Section(name); s != nil {
+		return s
+	}
+	s := &Section{Name: name}
+	f.Sections = append(f.Sections, s)
+	return s
+}
+
+// RemoveSection drops the section called name
+func (f *File) RemoveSection(name string) {
+	for i, s := range f.Sections {
+		if s.Name == name {
+			f.Sections = append(f.Sections[:i], f.Sections[i+1:]...)
+			return
+		}
+	}
+}
+
+// Bytes renders the file, one blank line between sections. Multi-line values
+// are written as indented continuation lines.
+func (f *File) Bytes() []byte {
+	var b strings.Builder
+	for i, s := range f.Sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[%s]\n", s.Name)
+		for _, k := range s.Keys {
+			fmt.Fprintf(&b, "%s=%s\n", k.Name, strings.ReplaceAll(k.Value, "\n", "\n    "))
+		}
+	}
+	return []byte(b.String())
+}
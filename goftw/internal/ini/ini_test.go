@@ -0,0 +1,89 @@
+package ini
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParse tests sections, separators, comments and continuation lines
+func TestParse(t *testing.T) {
+	input := `; supervisord config
+[supervisord]
+nodaemon=true
+
+[program:web]
+command=/env/bin/gunicorn -b 127.0.0.1:8000 ; inline comment
+priority: 4
+environment=A="1",
+  B="2"
+# trailing comment
+`
+	file, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if len(file.Sections) != 2 || file.Sections[0].Name != "supervisord" || file.Sections[1].Name != "program:web" {
+		t.Fatalf("EXPECTED sections [supervisord program:web] GOT %+v", file.Sections)
+	}
+
+	web := file.Section("program:web")
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"command", "/env/bin/gunicorn -b 127.0.0.1:8000"},
+		{"priority", "4"},
+		{"environment", "A=\"1\",\nB=\"2\""},
+	}
+	for _, tt := range tests {
+		if got := web.Value(tt.key, ""); got != tt.expected {
+			t.Fatalf("EXPECTED %s=%q GOT %q", tt.key, tt.expected, got)
+		}
+	}
+}
+
+// TestParseErrors tests malformed input
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		errMatch string
+	}{
+		{"key before section", "a=1\n", "outside of a section"},
+		{"duplicate section", "[a]\n[a]\n", "duplicate section [a]"},
+		{"duplicate key", "[a]\nx=1\nx=2\n", `duplicate key "x" in [a]`},
+		{"unterminated header", "[a\n", "unterminated section header"},
+		{"missing separator", "[a]\nnovalue\n", "expected name = value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Fatalf("EXPECTED error containing %q GOT %v", tt.errMatch, err)
+			}
+		})
+	}
+}
+
+// TestBytesRoundTrip tests that rendering and parsing again gives the same file
+func TestBytesRoundTrip(t *testing.T) {
+	input := "[program:web]\ncommand=gunicorn -w 2\nenvironment=A=\"1\",\n  B=\"2\"\n\n[group:web]\nprograms=web\n"
+	file, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	file.AddSection("program:extra").Set("command", "sleep 1")
+	file.RemoveSection("group:web")
+
+	again, err := Parse(strings.NewReader(string(file.Bytes())))
+	if err != nil {
+		t.Fatalf("EXPECTED rendered file to parse GOT %v\n%s", err, file.Bytes())
+	}
+	if !reflect.DeepEqual(file, again) {
+		t.Fatalf("EXPECTED %+v GOT %+v", file.Sections, again.Sections)
+	}
+	if len(again.Sections) != 2 || again.Sections[1].Name != "program:extra" {
+		t.Fatalf("EXPECTED [program:web program:extra] GOT %+v", again.Sections)
+	}
+}
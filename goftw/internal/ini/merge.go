@@ -0,0 +1,154 @@
+package ini
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Conflict is a key two files set to different values
+type Conflict struct {
+	Section string
+	Key     string
+	Values  []string // one per source, in merge order
+	Sources []string
+}
+
+// ConflictError lists every conflict found by Merge
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	lines := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		var sides []string
+		for i, value := range c.Values {
+			sides = append(sides, fmt.Sprintf("%q in %s", value, sourceName(c.Sources[i])))
+		}
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", c.Section, c.Key, strings.Join(sides, " vs ")))
+	}
+	return "conflicting supervisor config: " + strings.Join(lines, "; ")
+}
+
+func sourceName(source string) string {
+	if source == "" {
+		return "<generated>"
+	}
+	return source
+}
+
+// Merge combines files that are each meant to own their sections, such as the
+// head patch and bench's generated config. A section may appear in several
+// files, but a key set to different values in two of them is a conflict.
+func Merge(files ...*File) (*File, error) {
+	merged := &File{}
+	owners := map[string]map[string]string{} // section -> key -> source that set it
+	var conflicts []Conflict
+
+	for _, file := range files {
+		for _, section := range file.Sections {
+			target := merged.Section(section.Name)
+			if target == nil {
+				target = merged.AddSection(section.Name)
+				owners[section.Name] = map[string]string{}
+			}
+			for _, key := range section.Keys {
+				existing, ok := target.Get(key.Name)
+				switch {
+				case !ok:
+					target.Set(key.Name, key.Value)
+					owners[section.Name][key.Name] = file.Source
+				case existing != key.Value:
+					conflicts = append(conflicts, Conflict{
+						Section: section.Name,
+						Key:     key.Name,
+						Values:  []string{existing, key.Value},
+						Sources: []string{owners[section.Name][key.Name], file.Source},
+					})
+				}
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, &ConflictError{Conflicts: conflicts}
+	}
+	return merged, nil
+}
+
+// Overlay applies overlay on top of file: new sections are added and every
+// key in an existing section replaces or adds to the file's. It returns the
+// changes made, for logging.
+func Overlay(file, overlay *File) []string {
+	var changes []string
+	for _, section := range overlay.Sections {
+		target := file.Section(section.Name)
+		if target == nil {
+			target = file.AddSection(section.Name)
+			changes = append(changes, fmt.Sprintf("+ [%s] from %s", section.Name, sourceName(overlay.Source)))
+		}
+		for _, key := range section.Keys {
+			if existing, ok := target.Get(key.Name); ok && existing != key.Value {
+				changes = append(changes, fmt.Sprintf("~ [%s] %s=%s (was %s)", section.Name, key.Name, key.Value, existing))
+			}
+			target.Set(key.Name, key.Value)
+		}
+	}
+	return changes
+}
+
+// Diff describes what changed from old to new, section by section: added and
+// removed sections, then added, removed and changed keys. A nil old means
+// everything was added.
+func Diff(old, new *File) []string {
+	if old == nil {
+		old = &File{}
+	}
+	var lines []string
+	for _, section := range old.Sections {
+		if new.Section(section.Name) == nil {
+			lines = append(lines, fmt.Sprintf("- [%s]", section.Name))
+		}
+	}
+	for _, section := range new.Sections {
+		previous := old.Section(section.Name)
+		if previous == nil {
+			lines = append(lines, fmt.Sprintf("+ [%s]", section.Name))
+			continue
+		}
+		for _, key := range previous.Keys {
+			if _, ok := section.Get(key.Name); !ok {
+				lines = append(lines, fmt.Sprintf("- [%s] %s=%s", section.Name, key.Name, key.Value))
+			}
+		}
+		for _, key := range section.Keys {
+			value, ok := previous.Get(key.Name)
+			switch {
+			case !ok:
+				lines = append(lines, fmt.Sprintf("+ [%s] %s=%s", section.Name, key.Name, key.Value))
+			case value != key.Value:
+				lines = append(lines, fmt.Sprintf("~ [%s] %s: %s -> %s", section.Name, key.Name, value, key.Value))
+			}
+		}
+	}
+	return lines
+}
+
+// LoadDir loads every *.conf file in dir in name order. A missing dir has none.
+func LoadDir(dir string) ([]*File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var files []*File
+	for _, path := range paths {
+		file, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,119 @@
+package ini
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, source, input string) *File {
+	t.Helper()
+	file, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	file.Source = source
+	return file
+}
+
+// TestMerge tests that files sharing a section merge and disagreeing keys conflict
+func TestMerge(t *testing.T) {
+	head := mustParse(t, "head.conf", "[supervisord]\nnodaemon=true\n\n[program:nginx]\ncommand=nginx\n")
+	bench := mustParse(t, "bench.conf", "[supervisord]\nnodaemon=true\n\n[program:web]\ncommand=gunicorn\n")
+
+	merged, err := Merge(head, bench)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	var names []string
+	for _, section := range merged.Sections {
+		names = append(names, section.Name)
+	}
+	if expected := []string{"supervisord", "program:nginx", "program:web"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("EXPECTED sections %v GOT %v", expected, names)
+	}
+
+	clash := mustParse(t, "clash.conf", "[supervisord]\nnodaemon=false\n\n[program:nginx]\ncommand=nginx\n")
+	_, err = Merge(head, bench, clash)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("EXPECTED *ConflictError GOT %v", err)
+	}
+	expected := []Conflict{{
+		Section: "supervisord",
+		Key:     "nodaemon",
+		Values:  []string{"true", "false"},
+		Sources: []string{"head.conf", "clash.conf"},
+	}}
+	if !reflect.DeepEqual(conflict.Conflicts, expected) {
+		t.Fatalf("EXPECTED %+v GOT %+v", expected, conflict.Conflicts)
+	}
+}
+
+// TestOverlay tests that overlays add sections and override keys
+func TestOverlay(t *testing.T) {
+	file := mustParse(t, "", "[program:web]\ncommand=gunicorn\npriority=4\n")
+	overlay := mustParse(t, "web.conf", "[program:web]\npriority=2\nstartsecs=5\n\n[program:extra]\ncommand=sleep 1\n")
+
+	changes := Overlay(file, overlay)
+	expected := []string{"~ [program:web] priority=2 (was 4)", "+ [program:extra] from web.conf"}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("EXPECTED %q GOT %q", expected, changes)
+	}
+	web := file.Section("program:web")
+	if web.Value("priority", "") != "2" || web.Value("startsecs", "") != "5" || web.Value("command", "") != "gunicorn" {
+		t.Fatalf("EXPECTED overlaid program:web GOT %+v", web.Keys)
+	}
+	if file.Section("program:extra") == nil {
+		t.Fatalf("EXPECTED program:extra added GOT %+v", file.Sections)
+	}
+}
+
+// TestDiff tests added, removed and changed sections and keys
+func TestDiff(t *testing.T) {
+	old := mustParse(t, "", "[program:web]\ncommand=gunicorn -w 2\nautostart=true\n\n[program:old]\ncommand=x\n")
+	new := mustParse(t, "", "[program:web]\ncommand=gunicorn -w 4\npriority=4\n\n[program:new]\ncommand=y\n")
+
+	expected := []string{
+		"- [program:old]",
+		"- [program:web] autostart=true",
+		"~ [program:web] command: gunicorn -w 2 -> gunicorn -w 4",
+		"+ [program:web] priority=4",
+		"+ [program:new]",
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("EXPECTED %q GOT %q", expected, got)
+	}
+	if got := Diff(new, new); len(got) != 0 {
+		t.Fatalf("EXPECTED no diff GOT %q", got)
+	}
+}
+
+// TestLoadDir tests overlay loading order
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "20-b.conf"), []byte("[program:b]\ncommand=b\n"), 0644); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "10-a.conf"), []byte("[program:a]\ncommand=a\n"), 0644); err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if len(files) != 2 || filepath.Base(files[0].Source) != "10-a.conf" || filepath.Base(files[1].Source) != "20-b.conf" {
+		t.Fatalf("EXPECTED [10-a.conf 20-b.conf] GOT %d files", len(files))
+	}
+
+	if files, err := LoadDir(filepath.Join(dir, "missing")); err != nil || len(files) != 0 {
+		t.Fatalf("EXPECTED no files for a missing dir GOT %d, %v", len(files), err)
+	}
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import "time"
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+var (
+	Sites = Default.Gauge("goftw_sites",
+		"Number of sites in the bench.")
+	SiteApps = Default.Gauge("goftw_site_apps",
+		"Number of apps installed on a site, as of the last time they were listed.", "site")
+	JobsTotal = Default.Counter("goftw_jobs_total",
+		"Jobs run by goftw, by type and outcome.", "type", "outcome")
+	JobDuration = Default.Histogram("goftw_job_duration_seconds",
+		"Duration of jobs run by goftw, by type and outcome.", DefaultBuckets, "type", "outcome")
+	BenchCommandDuration = Default.Histogram("goftw_bench_command_duration_seconds",
+		"Duration of commands run in the bench directory, by command and outcome.", DefaultBuckets, "command", "outcome")
+	LastReconcileSuccess = Default.Gauge("goftw_last_reconcile_success_timestamp_seconds",
+		"Unix time of the last successful sites reconcile.")
+	BackupAge = Default.Gauge("goftw_backup_age_seconds",
+		"Age of the newest backup of a site.", "site")
+	DependencyReady = Default.Gauge("goftw_dependency_ready",
+		"Whether a dependency passed its readiness check (1) or not (0).", "dependency")
+	ProcessRestarts = Default.Counter("goftw_process_restarts_total",
+		"Restarts of child processes managed by goftw.", "process")
+	CertificateExpiryDays = Default.Gauge("goftw_certificate_expiry_days",
+		"Days until a certificate expires, by name and source (file or live).", "name", "source")
+	CertificateRenewalFailures = Default.Gauge("goftw_certificate_renewal_failures",
+		"Renewals of a certificate that failed in a row.", "name")
+	CertificateAlerts = Default.Counter("goftw_certificate_alerts_total",
+		"Certificate alerts raised, by reason.", "reason")
+)
+
+// Outcome returns the outcome label for err
+func Outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// ObserveJob records a finished job of jobType that started at start
+func ObserveJob(jobType string, start time.Time, err error) {
+	outcome := Outcome(err)
+	JobsTotal.Inc(jobType, outcome)
+	JobDuration.Observe(time.Since(start).Seconds(), jobType, outcome)
+}
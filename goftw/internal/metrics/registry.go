@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets in seconds, sized for bench commands
+// that take from under a second to tens of minutes.
+var DefaultBuckets = []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800}
+
+// Registry holds metric families and renders them in the Prometheus text format
+type Registry struct {
+	mu         sync.Mutex
+	families   []*family
+	collectors []func()
+}
+
+type family struct {
+	name       string
+	help       string
+	kind       string // counter, gauge or histogram
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64 // histogram counts per bucket, not cumulative
+	sum         float64
+	count       uint64
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f *family) *family {
+	f.series = map[string]*series{}
+	r.mu.Lock()
+	r.families = append(r.families, f)
+	r.mu.Unlock()
+	return f
+}
+
+// OnCollect registers fn to run before every scrape, to refresh gauges that are
+// cheaper to compute on demand than to keep up to date.
+func (r *Registry) OnCollect(fn func()) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, fn)
+	r.mu.Unlock()
+}
+
+// with returns the series for labelValues, creating it if needed. Caller holds f.mu.
+func (f *family) with(labelValues []string) *series {
+	return f.seriesIn(f.series, labelValues)
+}
+
+// seriesIn returns the series for labelValues in set, creating it if needed
+func (f *family) seriesIn(set map[string]*series, labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s: expected %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := set[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, labelValues...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		set[key] = s
+	}
+	return s
+}
+
+// CounterVec is a monotonically increasing value per label set
+type CounterVec struct{ f *family }
+
+// Counter registers a counter
+func (r *Registry) Counter(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{r.register(&family{name: name, help: help, kind: "counter", labelNames: labelNames})}
+}
+
+// Inc adds one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.f.mu.Lock()
+	c.f.with(labelValues).value += v
+	c.f.mu.Unlock()
+}
+
+// GaugeVec is a value that can go up and down per label set
+type GaugeVec struct{ f *family }
+
+// Gauge registers a gauge
+func (r *Registry) Gauge(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{r.register(&family{name: name, help: help, kind: "gauge", labelNames: labelNames})}
+}
+
+// Set sets the value
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	g.f.with(labelValues).value = v
+	g.f.mu.Unlock()
+}
+
+// Reset removes every series, so label sets that no longer exist stop being exported
+func (g *GaugeVec) Reset() {
+	g.f.mu.Lock()
+	g.f.series = map[string]*series{}
+	g.f.mu.Unlock()
+}
+
+// GaugeSet is a set of series built up to replace all of a gauge's series at once.
+// It is not safe for concurrent use.
+type GaugeSet struct {
+	f      *family
+	series map[string]*series
+}
+
+// NewSet returns an empty set of series for g
+func (g *GaugeVec) NewSet() *GaugeSet {
+	return &GaugeSet{f: g.f, series: map[string]*series{}}
+}
+
+// Set sets the value in the set
+func (s *GaugeSet) Set(v float64, labelValues ...string) {
+	s.f.seriesIn(s.series, labelValues).value = v
+}
+
+// Replace makes set the gauge's only series in one step, so a scrape sees
+// either the previous series or set, never a gauge in between
+func (g *GaugeVec) Replace(set *GaugeSet) {
+	if set.f != g.f {
+		panic(fmt.Sprintf("metric %s: replaced with series of %s", g.f.name, set.f.name))
+	}
+	g.f.mu.Lock()
+	g.f.series = set.series
+	g.f.mu.Unlock()
+}
+
+// HistogramVec counts observations into buckets per label set
+type HistogramVec struct{ f *family }
+
+// Histogram registers a histogram with upper bounds buckets, in increasing order
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{r.register(&family{name: name, help: help, kind: "histogram", labelNames: labelNames, buckets: buckets})}
+}
+
+// Observe records v
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.with(labelValues)
+	for i, upper := range h.f.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// WriteTo runs the collectors and writes every family in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := append([]func(){}, r.collectors...)
+	families := append([]*family{}, r.families...)
+	r.mu.Unlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (f *family) write(b *strings.Builder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != "histogram" {
+			fmt.Fprintf(b, "%s%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", formatValue(upper)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, f.labels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", f.name, f.labels(s.labelValues, "", ""), formatValue(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", f.name, f.labels(s.labelValues, "", ""), s.count)
+	}
+}
+
+// labels renders {a="x",b="y"}, with an optional extra label such as le
+func (f *family) labels(values []string, extraName, extraValue string) string {
+	var pairs []string
+	for i, name := range f.labelNames {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabel(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+// Handler serves the registry for Prometheus scrapes
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
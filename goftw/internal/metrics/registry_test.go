@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRegistryExposition tests the text format of each metric kind
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	jobs := r.Counter("test_jobs_total", "Jobs.", "type", "outcome")
+	sites := r.Gauge("test_sites", "Sites.")
+	apps := r.Gauge("test_site_apps", "Apps per site.", "site")
+	durations := r.Histogram("test_duration_seconds", "Durations.", []float64{1, 10}, "type")
+
+	jobs.Inc("migrate", "success")
+	jobs.Add(2, "migrate", "success")
+	apps.Set(3, `a"b.localhost`)
+	durations.Observe(0.5, "migrate")
+	durations.Observe(5, "migrate")
+	durations.Observe(50, "migrate")
+	r.OnCollect(func() { sites.Set(2) })
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+
+	expected := `# HELP test_jobs_total Jobs.
+# TYPE test_jobs_total counter
+test_jobs_total{type="migrate",outcome="success"} 3
+# HELP test_sites Sites.
+# TYPE test_sites gauge
+test_sites 2
+# HELP test_site_apps Apps per site.
+# TYPE test_site_apps gauge
+test_site_apps{site="a\"b.localhost"} 3
+# HELP test_duration_seconds Durations.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{type="migrate",le="1"} 1
+test_duration_seconds_bucket{type="migrate",le="10"} 2
+test_duration_seconds_bucket{type="migrate",le="+Inf"} 3
+test_duration_seconds_sum{type="migrate"} 55.5
+test_duration_seconds_count{type="migrate"} 3
+`
+	if got != expected {
+		t.Fatalf("EXPOSITION MISMATCH\nEXPECTED:\n%s\nGOT:\n%s", expected, got)
+	}
+
+	apps.Reset()
+	b.Reset()
+	_, _ = r.WriteTo(&b)
+	if strings.Contains(b.String(), "test_site_apps{") {
+		t.Fatalf("EXPECTED RESET TO DROP SERIES\n%s", b.String())
+	}
+}
+
+// TestGaugeReplace tests that a gauge's series are swapped in one step, never
+// leaving a scrape without them
+func TestGaugeReplace(t *testing.T) {
+	r := NewRegistry()
+	days := r.Gauge("test_days_left", "Days left.", "name")
+	days.Set(10, "a.localhost")
+	days.Set(20, "b.localhost")
+
+	set := days.NewSet()
+	set.Set(9, "a.localhost")
+	var b strings.Builder
+	_, _ = r.WriteTo(&b)
+	if !strings.Contains(b.String(), `test_days_left{name="b.localhost"} 20`) {
+		t.Fatalf("EXPECTED THE PREVIOUS SERIES UNTIL REPLACE\n%s", b.String())
+	}
+	days.Replace(set)
+	b.Reset()
+	_, _ = r.WriteTo(&b)
+	expected := `# HELP test_days_left Days left.
+# TYPE test_days_left gauge
+test_days_left{name="a.localhost"} 9
+`
+	if b.String() != expected {
+		t.Fatalf("EXPOSITION MISMATCH\nEXPECTED:\n%s\nGOT:\n%s", expected, b.String())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			set := days.NewSet()
+			set.Set(float64(i), "a.localhost")
+			days.Replace(set)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		b.Reset()
+		_, _ = r.WriteTo(&b)
+		if !strings.Contains(b.String(), `test_days_left{name="a.localhost"}`) {
+			t.Fatalf("EXPECTED A SERIES IN EVERY SCRAPE\n%s", b.String())
+		}
+	}
+}
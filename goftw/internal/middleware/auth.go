@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type contextKey string
+
+var keyNameRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+const (
+	keyNameContextKey contextKey = "goftw.api_key_name"
+	hashPrefix                   = "sha256:"
+
+	// APIKeyCookie is the cookie checked for an API key when no header is sent
+	APIKeyCookie = "goftw_api_key"
+)
+
+// APIKey is a named API key as stored in the keys file. Only the SHA-256 hash
+// of the secret is kept, see HashKey.
+type APIKey struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+type keysFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+type storedKey struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// KeyStore holds hashed API keys. Plaintext secrets are never retained.
+type KeyStore struct {
+	keys []storedKey
+}
+
+// HashKey returns the stored form of an API key secret
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// LoadKeyStore loads hashed keys from the keys file at path (skipped if missing)
+// and plaintext keys from env, formatted as comma separated "name:secret".
+// The name ends at the first ':' and must be a valid key name, so a secret may
+// contain ':' but an entry without a name is rejected rather than guessed at.
+func LoadKeyStore(path, env string) (*KeyStore, error) {
+	store := &KeyStore{}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read api keys %s: %w", path, err)
+	default:
+		var file keysFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse api keys %s: %w", path, err)
+		}
+		for i, key := range file.Keys {
+			decoded, err := hex.DecodeString(strings.TrimPrefix(key.Hash, hashPrefix))
+			if err != nil || len(decoded) != sha256.Size {
+				return nil, fmt.Errorf("api key %d (%s) in %s: hash must be sha256 hex", i, key.Name, path)
+			}
+			k := storedKey{name: key.Name}
+			copy(k.hash[:], decoded)
+			store.keys = append(store.keys, k)
+		}
+	}
+
+	for i, entry := range strings.Split(env, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, secret, found := strings.Cut(entry, ":")
+		if !found || !keyNameRegex.MatchString(name) || secret == "" {
+			return nil, fmt.Errorf("api key %d in GOFTW_API_KEYS: must be name:secret with a name of letters, digits, '.', '_' or '-'", i)
+		}
+		store.keys = append(store.keys, storedKey{name: name, hash: sha256.Sum256([]byte(secret))})
+	}
+	return store, nil
+}
+
+// Len returns the number of keys
+func (s *KeyStore) Len() int {
+	return len(s.keys)
+}
+
+// Lookup returns the name of the key matching secret. Every key is compared in
+// constant time so timing does not reveal which key, if any, matched.
+func (s *KeyStore) Lookup(secret string) (string, bool) {
+	sum := sha256.Sum256([]byte(secret))
+	name, ok := "", false
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash[:]) == 1 && !ok {
+			name, ok = k.name, true
+		}
+	}
+	return name, ok
+}
+
+// requestSecret extracts the key from "Authorization: Bearer <key>", "X-API-Key"
+// or the goftw_api_key cookie used by browser dashboards, and whether it came
+// from the cookie
+func requestSecret(r *http.Request) (string, bool) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if scheme, token, ok := strings.Cut(auth, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), false
+		}
+	}
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key, false
+	}
+	if cookie, err := r.Cookie(APIKeyCookie); err == nil {
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// crossSiteSafe reports whether a browser cannot have been made to send r by
+// another site: it sends X-Requested-With, which no form can and which needs
+// a CORS preflight cross-origin, or an Origin that trusted accepts
+func crossSiteSafe(r *http.Request, trusted func(origin string) bool) bool {
+	if r.Header.Get("X-Requested-With") != "" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	return origin != "" && trusted != nil && trusted(origin)
+}
+
+// safeMethod reports whether method is read-only
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// unauthorized rejects a request with 401 and a Bearer challenge
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="goftw"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+}
+
+// badRequest rejects a request with 400 and msg
+func badRequest(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// Auth middleware rejects requests without a valid API key with 401. A key
+// from the cookie is sent by browsers on any site's requests, so it only
+// authorizes unsafe methods that are crossSiteSafe, otherwise 403.
+func Auth(store *KeyStore, trusted func(origin string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secret, fromCookie := requestSecret(r)
+			name, ok := "", false
+			if secret != "" {
+				name, ok = store.Lookup(secret)
+			}
+			if !ok {
+				unauthorized(w)
+				return
+			}
+			if fromCookie && !safeMethod(r.Method) && !crossSiteSafe(r, trusted) {
+				forbidden(w, "cookie authentication requires an X-Requested-With header or a trusted Origin")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), keyNameContextKey, name)))
+		})
+	}
+}
+
+// LoginHandler checks the key of the JSON body {"key": "..."} and stores it in
+// the goftw_api_key cookie, HttpOnly and Secure, for browser dashboards.
+// sameSite is http.SameSiteNoneMode only when dashboards on other sites use
+// the cookie through CORS credentials.
+func LoginHandler(store *KeyStore, trusted func(origin string) bool, sameSite http.SameSite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !crossSiteSafe(r, trusted) {
+			forbidden(w, "login requires an X-Requested-With header or a trusted Origin")
+			return
+		}
+		var body struct {
+			Key string `json:"key"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Key == "" {
+			badRequest(w, `expected {"key": "..."}`)
+			return
+		}
+		name, ok := store.Lookup(body.Key)
+		if !ok {
+			unauthorized(w)
+			return
+		}
+		cookie := &http.Cookie{Name: APIKeyCookie, Value: body.Key, Path: "/", HttpOnly: true, Secure: true, SameSite: sameSite}
+		if err := cookie.Valid(); err != nil {
+			badRequest(w, "this key cannot be stored in a cookie, send it in the Authorization header")
+			return
+		}
+		http.SetCookie(w, cookie)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": name})
+	}
+}
+
+// LogoutHandler expires the goftw_api_key cookie
+func LogoutHandler(sameSite http.SameSite) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: APIKeyCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: true, SameSite: sameSite})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// KeyName returns the name of the API key that authenticated the request
+func KeyName(ctx context.Context) string {
+	name, _ := ctx.Value(keyNameContextKey).(string)
+	return name
+}
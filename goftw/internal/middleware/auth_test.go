@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goftw/internal/entity"
+)
+
+// TestAuth tests key extraction, hashed file keys and env keys
+func TestAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	file := `{"keys": [{"name": "dashboard", "hash": "` + HashKey("file-secret") + `"}]}`
+	if err := os.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadKeyStore(path, "ops:env-secret, ci:token:with:colons")
+	if err != nil {
+		t.Fatalf("UNEXPECTED LOAD ERROR: %v", err)
+	}
+	if store.Len() != 3 {
+		t.Fatalf("EXPECTED 3 KEYS, GOT %d", store.Len())
+	}
+
+	handler := Auth(store, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(KeyName(r.Context())))
+	}))
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		status   int
+		expected string
+	}{
+		{"bearer file key", "Authorization", "Bearer file-secret", 200, "dashboard"},
+		{"api key header", "X-API-Key", "env-secret", 200, "ops"},
+		{"secret with colons", "Authorization", "bearer token:with:colons", 200, "ci"},
+		{"secret suffix only", "Authorization", "Bearer with:colons", 401, ""},
+		{"wrong key", "Authorization", "Bearer nope", 401, ""},
+		{"basic scheme", "Authorization", "Basic file-secret", 401, ""},
+		{"no key", "", "", 401, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/goftw/sites", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("EXPECTED STATUS %d, GOT %d", tt.status, rec.Code)
+			}
+			if tt.status == 200 && rec.Body.String() != tt.expected {
+				t.Fatalf("EXPECTED KEY NAME %q, GOT %q", tt.expected, rec.Body.String())
+			}
+			if tt.status == 401 && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("EXPECTED WWW-Authenticate HEADER ON 401")
+			}
+		})
+	}
+}
+
+// TestLoadKeyStoreRejectsUnnamed tests that env entries must be name:secret
+func TestLoadKeyStoreRejectsUnnamed(t *testing.T) {
+	tests := []string{
+		"bare-secret",
+		"ops:env-secret,bare-secret",
+		":secret",
+		"ops:",
+		"my key:secret",
+	}
+	for _, env := range tests {
+		if _, err := LoadKeyStore(filepath.Join(t.TempDir(), "missing.json"), env); err == nil {
+			t.Fatalf("EXPECTED ERROR FOR %q", env)
+		}
+	}
+}
+
+// TestLoadKeyStoreRejectsPlaintext ensures the keys file only accepts hashes
+func TestLoadKeyStoreRejectsPlaintext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	if err := os.WriteFile(path, []byte(`{"keys": [{"name": "oops", "hash": "plaintext"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyStore(path, ""); err == nil {
+		t.Fatal("EXPECTED ERROR FOR NON-HASH KEY")
+	}
+}
+
+// TestAuthCookieCSRF tests that the cookie only authorizes cross-site safe writes
+func TestAuthCookieCSRF(t *testing.T) {
+	store, err := LoadKeyStore(filepath.Join(t.TempDir(), "missing.json"), "dashboard:cookie-secret")
+	if err != nil {
+		t.Fatalf("UNEXPECTED LOAD ERROR: %v", err)
+	}
+	trusted, err := TrustedOrigins(entity.CORS{AllowedOrigins: []string{"https://dash.example.com"}, AllowCredentials: true})
+	if err != nil {
+		t.Fatalf("UNEXPECTED ERROR: %v", err)
+	}
+	handler := Auth(store, trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name    string
+		method  string
+		cookie  bool
+		headers map[string]string
+		status  int
+	}{
+		{"cookie read", "GET", true, nil, http.StatusTeapot},
+		{"cross-origin form post", "POST", true, map[string]string{"Origin": "https://evil.com", "Content-Type": "text/plain"}, http.StatusForbidden},
+		{"post without origin", "DELETE", true, nil, http.StatusForbidden},
+		{"trusted origin post", "PUT", true, map[string]string{"Origin": "https://dash.example.com"}, http.StatusTeapot},
+		{"requested with header", "POST", true, map[string]string{"X-Requested-With": "XMLHttpRequest"}, http.StatusTeapot},
+		{"header key post", "POST", false, map[string]string{"Origin": "https://evil.com"}, http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/goftw/update", strings.NewReader("{}"))
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: APIKeyCookie, Value: "cookie-secret"})
+			} else {
+				req.Header.Set("X-API-Key", "cookie-secret")
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("EXPECTED STATUS %d, GOT %d", tt.status, rec.Code)
+			}
+		})
+	}
+
+	// "*" allows any origin to read, but trusts none with the cookie
+	anyOrigin, err := TrustedOrigins(entity.CORS{AllowedOrigins: []string{"*"}})
+	if err != nil {
+		t.Fatalf("UNEXPECTED ERROR: %v", err)
+	}
+	if anyOrigin("https://evil.com") {
+		t.Fatal("EXPECTED \"*\" TO TRUST NO ORIGIN")
+	}
+}
+
+// TestLogin tests that a valid key is stored in an HttpOnly, Secure, SameSite cookie
+func TestLogin(t *testing.T) {
+	store, err := LoadKeyStore(filepath.Join(t.TempDir(), "missing.json"), "dashboard:cookie-secret")
+	if err != nil {
+		t.Fatalf("UNEXPECTED LOAD ERROR: %v", err)
+	}
+	handler := LoginHandler(store, nil, http.SameSiteStrictMode)
+
+	tests := []struct {
+		name   string
+		body   string
+		header bool
+		status int
+	}{
+		{"valid key", `{"key": "cookie-secret"}`, true, http.StatusOK},
+		{"wrong key", `{"key": "nope"}`, true, http.StatusUnauthorized},
+		{"missing key", `{}`, true, http.StatusBadRequest},
+		{"cross-site form", `{"key": "cookie-secret"}`, false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/goftw/login", strings.NewReader(tt.body))
+			if tt.header {
+				req.Header.Set("X-Requested-With", "XMLHttpRequest")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("EXPECTED STATUS %d, GOT %d", tt.status, rec.Code)
+			}
+			cookies := rec.Result().Cookies()
+			if tt.status != http.StatusOK {
+				if len(cookies) != 0 {
+					t.Fatalf("EXPECTED NO COOKIE, GOT %v", cookies)
+				}
+				return
+			}
+			if len(cookies) != 1 {
+				t.Fatalf("EXPECTED ONE COOKIE, GOT %v", cookies)
+			}
+			c := cookies[0]
+			if c.Name != APIKeyCookie || c.Value != "cookie-secret" || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode {
+				t.Fatalf("EXPECTED AN HttpOnly, Secure, SameSite=Strict KEY COOKIE, GOT %s", c.String())
+			}
+			if !strings.Contains(rec.Body.String(), `"dashboard"`) {
+				t.Fatalf("EXPECTED THE KEY NAME, GOT %s", rec.Body.String())
+			}
+		})
+	}
+}
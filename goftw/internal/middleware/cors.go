@@ -1,22 +1,211 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"goftw/internal/entity"
+	"goftw/internal/environ"
 )
 
-// CORS middleware handle cors policy
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Adjust this based on your frontend origin
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "X-API-Key", "X-Requested-With"}
+)
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusNoContent)
-			return
+// CORSFromEnv applies defaults and CORS_* environment overrides to the instance.json policy.
+// List variables are comma separated.
+func CORSFromEnv(cfg entity.CORS) entity.CORS {
+	splitList := func(v string) []string {
+		var out []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+
+	if v := environ.GetEnv("CORS_ALLOWED_ORIGINS", ""); v != "" {
+		cfg.AllowedOrigins = splitList(v)
+	}
+	if v := environ.GetEnv("CORS_ALLOWED_METHODS", ""); v != "" {
+		cfg.AllowedMethods = splitList(v)
+	}
+	if v := environ.GetEnv("CORS_ALLOWED_HEADERS", ""); v != "" {
+		cfg.AllowedHeaders = splitList(v)
+	}
+	if v := environ.GetEnv("CORS_ALLOW_CREDENTIALS", ""); v != "" {
+		cfg.AllowCredentials = v == "1" || strings.EqualFold(v, "true")
+	}
+	if v := environ.GetEnv("CORS_MAX_AGE", ""); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MaxAge = n
+		}
+	}
+
+	if len(cfg.AllowedOrigins) == 0 {
+		cfg.AllowedOrigins = []string{"*"}
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = defaultCORSMethods
+	}
+	if len(cfg.AllowedHeaders) == 0 {
+		cfg.AllowedHeaders = defaultCORSHeaders
+	}
+	return cfg
+}
+
+type corsPolicy struct {
+	anyOrigin   bool
+	exact       map[string]bool
+	wildcards   []*url.URL // host holds the suffix after "*."
+	methods     map[string]bool
+	headers     map[string]bool
+	methodsList string
+	headersList string
+	credentials bool
+	maxAge      string
+}
+
+// allowsOrigin reports whether origin is allowed, by "*" or a listed entry
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	return p.anyOrigin || p.listsOrigin(origin)
+}
+
+// listsOrigin reports whether origin matches an exact or wildcard subdomain entry
+func (p *corsPolicy) listsOrigin(origin string) bool {
+	if p.exact[strings.ToLower(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	for _, w := range p.wildcards {
+		if u.Scheme == w.Scheme && strings.HasSuffix(host, "."+w.Host) {
+			return true
 		}
+	}
+	return false
+}
+
+// newCORSPolicy parses cfg. Credentials cannot be combined with "*" origins,
+// since browsers would then send cookies to any site's requests.
+func newCORSPolicy(cfg entity.CORS) (*corsPolicy, error) {
+	p := &corsPolicy{
+		exact:       map[string]bool{},
+		methods:     map[string]bool{},
+		headers:     map[string]bool{},
+		methodsList: strings.Join(cfg.AllowedMethods, ", "),
+		headersList: strings.Join(cfg.AllowedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+			if err != nil || u.Host == "" {
+				return nil, fmt.Errorf("invalid cors origin %q", origin)
+			}
+			p.wildcards = append(p.wildcards, u)
+		default:
+			p.exact[origin] = true
+		}
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, fmt.Errorf("cors: allow_credentials cannot be used with origin \"*\"")
+	}
+	for _, m := range cfg.AllowedMethods {
+		p.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range cfg.AllowedHeaders {
+		p.headers[strings.ToLower(h)] = true
+	}
+	return p, nil
+}
+
+// TrustedOrigins returns whether an origin is listed in cfg by name or
+// wildcard subdomain; "*" trusts no origin in particular
+func TrustedOrigins(cfg entity.CORS) (func(origin string) bool, error) {
+	p, err := newCORSPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return p.listsOrigin, nil
+}
+
+// NewCORS returns a middleware enforcing cfg
+func NewCORS(cfg entity.CORS) (func(http.Handler) http.Handler, error) {
+	p, err := newCORSPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		next.ServeHTTP(w, r)
-	})
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !p.allowsOrigin(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if p.anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if p.credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !p.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+				if h = strings.TrimSpace(h); h != "" && !p.headers[strings.ToLower(h)] {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+			}
+			w.Header().Set("Access-Control-Allow-Methods", p.methodsList)
+			w.Header().Set("Access-Control-Allow-Headers", p.headersList)
+			if p.maxAge != "" {
+				w.Header().Set("Access-Control-Max-Age", p.maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}, nil
 }
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goftw/internal/entity"
+)
+
+// TestCORS tests origin matching, credentials and preflight handling
+func TestCORS(t *testing.T) {
+	cors, err := NewCORS(entity.CORS{
+		AllowedOrigins:   []string{"https://dash.example.com", "https://*.tenants.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		t.Fatalf("UNEXPECTED ERROR: %v", err)
+	}
+	handler := cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		reqMethod   string
+		reqHeaders  string
+		status      int
+		allowOrigin string
+	}{
+		{"exact origin", "GET", "https://dash.example.com", "", "", http.StatusTeapot, "https://dash.example.com"},
+		{"wildcard subdomain", "GET", "https://a.tenants.example.com", "", "", http.StatusTeapot, "https://a.tenants.example.com"},
+		{"wildcard excludes apex", "GET", "https://tenants.example.com", "", "", http.StatusTeapot, ""},
+		{"wildcard checks scheme", "GET", "http://a.tenants.example.com", "", "", http.StatusTeapot, ""},
+		{"unknown origin", "GET", "https://evil.com", "", "", http.StatusTeapot, ""},
+		{"no origin", "GET", "", "", "", http.StatusTeapot, ""},
+		{"preflight", "OPTIONS", "https://dash.example.com", "PUT", "content-type, authorization", http.StatusNoContent, "https://dash.example.com"},
+		{"preflight bad method", "OPTIONS", "https://dash.example.com", "DELETE", "", http.StatusForbidden, "https://dash.example.com"},
+		{"preflight bad header", "OPTIONS", "https://dash.example.com", "PUT", "X-Custom", http.StatusForbidden, "https://dash.example.com"},
+		{"preflight unknown origin", "OPTIONS", "https://evil.com", "PUT", "", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/goftw/sites", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.reqMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.reqMethod)
+			}
+			if tt.reqHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.reqHeaders)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("EXPECTED STATUS %d, GOT %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Fatalf("EXPECTED ALLOW-ORIGIN %q, GOT %q", tt.allowOrigin, got)
+			}
+			if rec.Header().Values("Vary")[0] != "Origin" {
+				t.Fatalf("EXPECTED Vary: Origin, GOT %v", rec.Header().Values("Vary"))
+			}
+			if tt.allowOrigin != "" && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Fatal("EXPECTED CREDENTIALS HEADER")
+			}
+			if tt.status == http.StatusNoContent && rec.Header().Get("Access-Control-Max-Age") != "600" {
+				t.Fatalf("EXPECTED MAX-AGE 600, GOT %q", rec.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+// TestCORSRejectsWildcardCredentials ensures credentials are never sent to any origin
+func TestCORSRejectsWildcardCredentials(t *testing.T) {
+	if _, err := NewCORS(entity.CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Fatal("EXPECTED ERROR FOR * WITH CREDENTIALS")
+	}
+}
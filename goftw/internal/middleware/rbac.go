@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Role is the set of operations an API key may perform. Each role includes the ones below it.
+type Role string
+
+const (
+	RoleReader   Role = "reader"   // list sites, apps and the catalog
+	RoleOperator Role = "operator" // install apps and run migrations
+	RoleAdmin    Role = "admin"    // create and drop sites, run updates and gc
+)
+
+var roleLevels = map[Role]int{
+	RoleReader:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// Grant is the role given to a key, optionally scoped to site name patterns (path.Match syntax).
+type Grant struct {
+	Role  Role     `json:"role"`
+	Sites []string `json:"sites"`
+}
+
+// Policy maps API key names to grants. Keys that are not listed get DefaultRole,
+// or are denied when it is empty.
+type Policy struct {
+	DefaultRole Role             `json:"default_role"`
+	Keys        map[string]Grant `json:"keys"`
+}
+
+// LoadPolicy loads and validates the policy file. A missing file yields
+// nil, in which case every authenticated key is an admin.
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read api policy %s: %w", file, err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse api policy %s: %w", file, err)
+	}
+	if _, ok := roleLevels[policy.DefaultRole]; policy.DefaultRole != "" && !ok {
+		return nil, fmt.Errorf("api policy %s: unknown default_role %q", file, policy.DefaultRole)
+	}
+	for name, grant := range policy.Keys {
+		if _, ok := roleLevels[grant.Role]; !ok {
+			return nil, fmt.Errorf("api policy %s: key %s has unknown role %q", file, name, grant.Role)
+		}
+		for _, pattern := range grant.Sites {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("api policy %s: key %s has invalid site pattern %q", file, name, pattern)
+			}
+		}
+	}
+	return &policy, nil
+}
+
+// grant returns the grant for a key name
+func (p *Policy) grant(name string) (Grant, bool) {
+	if p == nil {
+		return Grant{Role: RoleAdmin}, true
+	}
+	if grant, ok := p.Keys[name]; ok {
+		return grant, true
+	}
+	if p.DefaultRole != "" {
+		return Grant{Role: p.DefaultRole}, true
+	}
+	return Grant{}, false
+}
+
+// allowsSite reports whether a grant may act on site. Unscoped grants allow every site.
+func (g Grant) allowsSite(site string) bool {
+	if len(g.Sites) == 0 {
+		return true
+	}
+	for _, pattern := range g.Sites {
+		if ok, _ := path.Match(pattern, site); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Require middleware allows the request only if the authenticated key's role
+// includes role. Keys scoped to sites may only use routes with a {name} site
+// parameter matching one of their patterns. It must run after Auth.
+func (p *Policy) Require(role Role) func(http.Handler) http.Handler {
+	return p.RequireSite(role, nil)
+}
+
+// RequireSite is Require for routes whose handler acts on siteName({name})
+// rather than {name} itself, e.g. a site created under a normalized name.
+// Scoped keys are checked against the name the handler acts on.
+func (p *Policy) RequireSite(role Role, siteName func(string) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := KeyName(r.Context())
+			grant, ok := p.grant(name)
+			if !ok || roleLevels[grant.Role] < roleLevels[role] {
+				forbidden(w, fmt.Sprintf("key %q requires role %s", name, role))
+				return
+			}
+			if len(grant.Sites) > 0 {
+				site := chi.URLParam(r, "name")
+				if site != "" && siteName != nil {
+					site = siteName(site)
+				}
+				if site == "" || !grant.allowsSite(site) {
+					forbidden(w, fmt.Sprintf("key %q is not allowed to manage this site", name))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forbidden(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TestPolicyRequire tests role levels, default roles and site scoping
+func TestPolicyRequire(t *testing.T) {
+	policy := &Policy{
+		DefaultRole: RoleReader,
+		Keys: map[string]Grant{
+			"ops":    {Role: RoleOperator},
+			"root":   {Role: RoleAdmin},
+			"tenant": {Role: RoleAdmin, Sites: []string{"acme.localhost", "acme-*.localhost"}},
+		},
+	}
+
+	r := chi.NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r.With(policy.Require(RoleReader)).Get("/sites", ok)
+	r.With(policy.Require(RoleOperator)).Post("/site/{name}/migrate", ok)
+	r.With(policy.Require(RoleAdmin)).Put("/site/{name}", ok)
+
+	tests := []struct {
+		key    string
+		method string
+		path   string
+		status int
+	}{
+		{"unlisted", "GET", "/sites", 200},
+		{"unlisted", "POST", "/site/a.localhost/migrate", 403},
+		{"ops", "POST", "/site/a.localhost/migrate", 200},
+		{"ops", "PUT", "/site/a.localhost", 403},
+		{"root", "PUT", "/site/a.localhost", 200},
+		{"tenant", "PUT", "/site/acme.localhost", 200},
+		{"tenant", "POST", "/site/acme-staging.localhost/migrate", 200},
+		{"tenant", "PUT", "/site/other.localhost", 403},
+		{"tenant", "GET", "/sites", 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req = req.WithContext(context.WithValue(req.Context(), keyNameContextKey, tt.key))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("EXPECTED STATUS %d, GOT %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestNilPolicyIsAdmin ensures auth-only deployments keep full access
+func TestNilPolicyIsAdmin(t *testing.T) {
+	var policy *Policy
+	handler := policy.Require(RoleAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/site/a.localhost", nil))
+	if rec.Code != 200 {
+		t.Fatalf("EXPECTED 200 WITHOUT POLICY, GOT %d", rec.Code)
+	}
+}
+
+// TestPolicyRequireSite tests that scoped keys are checked against the name a
+// site is created under, not the requested one
+func TestPolicyRequireSite(t *testing.T) {
+	policy := &Policy{Keys: map[string]Grant{
+		"acme":  {Role: RoleAdmin, Sites: []string{"acme.com"}},
+		"com":   {Role: RoleAdmin, Sites: []string{"*.com"}},
+		"local": {Role: RoleAdmin, Sites: []string{"acme.localhost"}},
+	}}
+	localhost := func(site string) string {
+		return strings.TrimSuffix(site, path.Ext(site)) + ".localhost"
+	}
+
+	r := chi.NewRouter()
+	r.With(policy.RequireSite(RoleAdmin, localhost)).Put("/site/{name}", func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		key    string
+		path   string
+		status int
+	}{
+		{"acme", "/site/acme.com", 403},
+		{"com", "/site/other.com", 403},
+		{"local", "/site/acme.com", 200},
+		{"local", "/site/acme.localhost", 200},
+		{"local", "/site/other.localhost", 403},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("PUT", tt.path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), keyNameContextKey, tt.key))
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Fatalf("%s PUT %s: EXPECTED STATUS %d, GOT %d", tt.key, tt.path, tt.status, rec.Code)
+		}
+	}
+}
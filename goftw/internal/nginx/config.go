@@ -0,0 +1,187 @@
+package nginx
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"goftw/internal/entity"
+)
+
+//go:embed nginx.conf.tmpl
+var confTemplate string
+
+var sizeRegex = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+var tmpl = template.Must(template.New("nginx.conf").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(confTemplate))
+
+const (
+	defaultWebPort           = 8000
+	defaultSocketIOPort      = 9000
+	defaultClientMaxBodySize = "50m"
+	defaultProxyReadTimeout  = 120
+)
+
+// Config describes everything the bench's nginx config is rendered from
+type Config struct {
+	BenchName    string // prefixes the upstream names
+	SitesPath    string // the bench's sites directory, nginx's root
+	WebPort      int    // gunicorn, 8000 when zero
+	SocketIOPort int    // socketio, 9000 when zero
+	ServerName   string // served by the default server; "_" when empty or claimed by a site
+	ACMEWebroot  string // serves /.well-known/acme-challenge/ from this directory over HTTP when set
+	Sites        []Site
+}
+
+// Site is a bench site and the names it answers to
+type Site struct {
+	Name           string
+	Certificate    string // for Name; served over TLS when set
+	CertificateKey string
+	Domains        []entity.SiteDomain
+	Options        entity.NginxOptions
+}
+
+// server is one rendered server block
+type server struct {
+	Names             []string
+	Site              string // the site directory and X-Frappe-Site-Name, $host for the default server
+	Default           bool
+	Certificate       string
+	CertificateKey    string
+	ClientMaxBodySize string
+	ProxyReadTimeout  int
+}
+
+// Render renders cfg. Sites and their domains are sorted, so the same sites
+// always give the same bytes.
+func Render(cfg Config) ([]byte, error) {
+	if strings.ContainsAny(cfg.ACMEWebroot, " \t\r\n;{}'\"") {
+		return nil, fmt.Errorf("invalid acme webroot %q", cfg.ACMEWebroot)
+	}
+	servers, err := buildServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.WebPort == 0 {
+		cfg.WebPort = defaultWebPort
+	}
+	if cfg.SocketIOPort == 0 {
+		cfg.SocketIOPort = defaultSocketIOPort
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Config
+		Servers []server
+	}{cfg, servers})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// buildServers gives each site a plain HTTP server for all its names and a
+// TLS server per certificate, then adds the default server
+func buildServers(cfg Config) ([]server, error) {
+	sites := append([]Site(nil), cfg.Sites...)
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Name < sites[j].Name })
+
+	claimed := map[string]string{} // server name -> site
+	var servers []server
+	for _, site := range sites {
+		if err := checkName(site.Name); err != nil {
+			return nil, err
+		}
+		names := []entity.SiteDomain{{Domain: site.Name, SSLCertificate: site.Certificate, SSLCertificateKey: site.CertificateKey}}
+		domains := append([]entity.SiteDomain(nil), site.Domains...)
+		sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+		names = append(names, domains...)
+
+		plain := server{Site: site.Name}
+		if err := applyOptions(&plain, site.Options); err != nil {
+			return nil, fmt.Errorf("site %s: %w", site.Name, err)
+		}
+		var tls []server
+		for _, name := range names {
+			if err := checkName(name.Domain); err != nil {
+				return nil, err
+			}
+			if owner, ok := claimed[name.Domain]; ok {
+				if owner == site.Name {
+					continue
+				}
+				return nil, fmt.Errorf("%s is a name of both %s and %s", name.Domain, owner, site.Name)
+			}
+			claimed[name.Domain] = site.Name
+			plain.Names = append(plain.Names, name.Domain)
+
+			if name.SSLCertificate == "" {
+				continue
+			}
+			if name.SSLCertificateKey == "" {
+				return nil, fmt.Errorf("%s has a certificate but no key", name.Domain)
+			}
+			if strings.ContainsAny(name.SSLCertificate+name.SSLCertificateKey, " \t\r\n;{}'\"") {
+				return nil, fmt.Errorf("invalid certificate path for %s", name.Domain)
+			}
+			i := 0
+			for i < len(tls) && (tls[i].Certificate != name.SSLCertificate || tls[i].CertificateKey != name.SSLCertificateKey) {
+				i++
+			}
+			if i == len(tls) {
+				secure := plain
+				secure.Names = nil
+				secure.Certificate, secure.CertificateKey = name.SSLCertificate, name.SSLCertificateKey
+				tls = append(tls, secure)
+			}
+			tls[i].Names = append(tls[i].Names, name.Domain)
+		}
+		servers = append(servers, plain)
+		servers = append(servers, tls...)
+	}
+
+	defaultName := cfg.ServerName
+	if _, ok := claimed[defaultName]; ok || defaultName == "" {
+		defaultName = "_"
+	} else if err := checkName(defaultName); err != nil {
+		return nil, err
+	}
+	fallback := server{Names: []string{defaultName}, Site: "$host", Default: true}
+	_ = applyOptions(&fallback, entity.NginxOptions{})
+	return append(servers, fallback), nil
+}
+
+// applyOptions fills a server's tunables, falling back to the defaults
+func applyOptions(s *server, options entity.NginxOptions) error {
+	s.ClientMaxBodySize = options.ClientMaxBodySize
+	if s.ClientMaxBodySize == "" {
+		s.ClientMaxBodySize = defaultClientMaxBodySize
+	}
+	if !sizeRegex.MatchString(s.ClientMaxBodySize) {
+		return fmt.Errorf("invalid client_max_body_size %q", s.ClientMaxBodySize)
+	}
+	s.ProxyReadTimeout = options.ProxyReadTimeout
+	if s.ProxyReadTimeout == 0 {
+		s.ProxyReadTimeout = defaultProxyReadTimeout
+	}
+	if s.ProxyReadTimeout < 0 {
+		return fmt.Errorf("invalid proxy_read_timeout %d", s.ProxyReadTimeout)
+	}
+	return nil
+}
+
+// checkName refuses names that would break out of a server_name directive
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n;{}$'\"\\/") {
+		return fmt.Errorf("invalid server name %q", name)
+	}
+	return nil
+}
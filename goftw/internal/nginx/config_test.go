@@ -0,0 +1,105 @@
+package nginx
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goftw/internal/entity"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestRender tests rendered configs against the golden files in testdata
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{
+			name: "no_sites",
+			cfg:  Config{BenchName: "frappe-bench", SitesPath: "/home/frappe/frappe-bench/sites"},
+		},
+		{
+			name: "sites",
+			cfg: Config{
+				BenchName:    "frappe-bench",
+				SitesPath:    "/home/frappe/frappe-bench/sites",
+				WebPort:      8080,
+				SocketIOPort: 9001,
+				ServerName:   "example.com",
+				ACMEWebroot:  "/home/frappe/frappe-bench/config/certs/webroot",
+				Sites: []Site{
+					// Out of order on purpose, output is sorted
+					{
+						Name:    "hrms.localhost",
+						Options: entity.NginxOptions{ClientMaxBodySize: "200m", ProxyReadTimeout: 300},
+					},
+					{
+						Name:           "home.example.com",
+						Certificate:    "/etc/ssl/home.pem",
+						CertificateKey: "/etc/ssl/home.key",
+						Domains: []entity.SiteDomain{
+							{Domain: "www.tenant.com", SSLCertificate: "/etc/ssl/tenant.pem", SSLCertificateKey: "/etc/ssl/tenant.key"},
+							{Domain: "erp.tenant.com"},
+							{Domain: "tenant.com", SSLCertificate: "/etc/ssl/tenant.pem", SSLCertificateKey: "/etc/ssl/tenant.key"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.cfg)
+			if err != nil {
+				t.Fatalf("EXPECTED no error GOT %v", err)
+			}
+			golden := filepath.Join("testdata", tt.name+".conf")
+			if *update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("EXPECTED golden file %s GOT %v (run go test -update)", golden, err)
+			}
+			if string(got) != string(expected) {
+				t.Fatalf("EXPECTED %s GOT\n%s", golden, got)
+			}
+
+			again, _ := Render(tt.cfg)
+			if string(again) != string(got) {
+				t.Fatalf("EXPECTED deterministic output GOT a different render")
+			}
+		})
+	}
+}
+
+// TestRenderErrors tests names and options that must not reach nginx
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"injected server name", Config{Sites: []Site{{Name: "a.localhost; include /etc/passwd"}}}},
+		{"name claimed twice", Config{Sites: []Site{
+			{Name: "a.localhost", Domains: []entity.SiteDomain{{Domain: "shop.com"}}},
+			{Name: "b.localhost", Domains: []entity.SiteDomain{{Domain: "shop.com"}}},
+		}}},
+		{"certificate without key", Config{Sites: []Site{{Name: "a.localhost", Certificate: "/etc/ssl/a.pem"}}}},
+		{"invalid body size", Config{Sites: []Site{{Name: "a.localhost", Options: entity.NginxOptions{ClientMaxBodySize: "1m; deny all"}}}}},
+		{"invalid server name", Config{ServerName: "{example.com}"}},
+		{"invalid acme webroot", Config{ACMEWebroot: "/srv/acme; autoindex on"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Render(tt.cfg); err == nil {
+				t.Fatalf("EXPECTED an error GOT none")
+			}
+		})
+	}
+}
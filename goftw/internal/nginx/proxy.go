@@ -0,0 +1,114 @@
+package nginx
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"regexp"
+	"sort"
+	"text/template"
+)
+
+//go:embed proxy.conf.tmpl
+var proxyTemplate string
+
+var proxyTmpl = template.Must(template.New("dynamic-proxy.conf").Parse(proxyTemplate))
+
+// hostPortRegex is an upstream nginx can proxy to, e.g. frappe:80
+var hostPortRegex = regexp.MustCompile(`^[a-zA-Z0-9.\-]+(:[0-9]+)?$`)
+
+// pathRegex is what a site may be routed under: one plain path segment
+var pathRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.\-]*$`)
+
+// FrameworkPaths are the first path segments Frappe serves itself. A page of
+// any site links to them from the root, so no site may be routed under them.
+var FrameworkPaths = []string{
+	"api", "app", "assets", "desk", "files", "private", "socket.io",
+	"login", "logout", "method", "update-password", "website_script.js", "health",
+}
+
+// ProxyConfig describes the path-based proxy that serves each site under /{path}/
+type ProxyConfig struct {
+	Upstream    string // the Frappe nginx, e.g. frappe:80
+	ServerName  string // "_" when empty
+	DefaultSite string // Host for framework paths until a site was visited
+	Sites       []ProxySite
+}
+
+// ProxySite routes /{Path}/ to the site Name
+type ProxySite struct {
+	Path string
+	Name string
+}
+
+// RoutableSites returns sites sorted by path, without those that cannot be
+// routed: invalid names or paths, framework paths and paths an earlier site
+// already takes. Each site left out comes with the reason.
+func RoutableSites(sites []ProxySite) ([]ProxySite, []error) {
+	sorted := append([]ProxySite(nil), sites...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	seen := map[string]string{}
+	for _, path := range FrameworkPaths {
+		seen[path] = "frappe"
+	}
+	var routable []ProxySite
+	var skipped []error
+	for _, site := range sorted {
+		if err := checkName(site.Name); err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+		if !pathRegex.MatchString(site.Path) {
+			skipped = append(skipped, fmt.Errorf("invalid proxy path %q for %s", site.Path, site.Name))
+			continue
+		}
+		if owner, ok := seen[site.Path]; ok {
+			skipped = append(skipped, fmt.Errorf("/%s/ of %s is already routed to %s", site.Path, site.Name, owner))
+			continue
+		}
+		seen[site.Path] = site.Name
+		routable = append(routable, site)
+	}
+	return routable, skipped
+}
+
+// RenderProxy renders cfg. Sites are sorted by path, so the same sites always
+// give the same bytes, and those RoutableSites leaves out are not routed
+// while the others still are.
+func RenderProxy(cfg ProxyConfig) ([]byte, error) {
+	if cfg.Upstream == "" || !hostPortRegex.MatchString(cfg.Upstream) {
+		return nil, fmt.Errorf("invalid proxy upstream %q", cfg.Upstream)
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = "_"
+	}
+	if err := checkName(cfg.ServerName); err != nil {
+		return nil, err
+	}
+
+	sites, _ := RoutableSites(cfg.Sites)
+	cfg.Sites = sites
+
+	switch {
+	case cfg.DefaultSite != "":
+		if err := checkName(cfg.DefaultSite); err != nil {
+			return nil, err
+		}
+	case len(sites) > 0:
+		cfg.DefaultSite = sites[0].Name
+	default:
+		cfg.DefaultSite = "$host"
+	}
+
+	var buf bytes.Buffer
+	if err := proxyTmpl.Execute(&buf, cfg); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRenderProxy tests the rendered proxy config against the golden file in testdata
+func TestRenderProxy(t *testing.T) {
+	cfg := ProxyConfig{
+		Upstream:   "frappe:80",
+		ServerName: "example.com",
+		Sites: []ProxySite{
+			{Path: "hrms", Name: "hrms.localhost"},
+			{Path: "home.example.com", Name: "home.example.com"},
+		},
+	}
+	got, err := RenderProxy(cfg)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	golden := filepath.Join("testdata", "dynamic-proxy.conf")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("EXPECTED golden file %s GOT %v (run go test -update)", golden, err)
+	}
+	if string(got) != string(expected) {
+		t.Fatalf("EXPECTED %s GOT\n%s", golden, got)
+	}
+}
+
+// TestRenderProxyErrors tests configs that cannot be rendered at all
+func TestRenderProxyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ProxyConfig
+	}{
+		{"no upstream", ProxyConfig{}},
+		{"injected upstream", ProxyConfig{Upstream: "frappe:80; }"}},
+		{"injected server name", ProxyConfig{Upstream: "frappe:80", ServerName: "a; }"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RenderProxy(tt.cfg); err == nil {
+				t.Fatalf("EXPECTED an error GOT none")
+			}
+		})
+	}
+}
+
+// TestRoutableSites tests that sites which cannot be routed by path are left out with a reason
+func TestRoutableSites(t *testing.T) {
+	sites := []ProxySite{
+		{Path: "hrms", Name: "hrms.localhost"},
+		{Path: "api", Name: "api.localhost"},
+		{Path: "a", Name: "a.localhost"},
+		{Path: "a", Name: "a.example.com"},
+		{Path: "a/b", Name: "ab.localhost"},
+	}
+	routable, skipped := RoutableSites(sites)
+	expected := []ProxySite{
+		{Path: "a", Name: "a.example.com"},
+		{Path: "hrms", Name: "hrms.localhost"},
+	}
+	if !reflect.DeepEqual(routable, expected) {
+		t.Fatalf("EXPECTED %+v GOT %+v", expected, routable)
+	}
+	if len(skipped) != 3 {
+		t.Fatalf("EXPECTED 3 sites left out GOT %v", skipped)
+	}
+
+	data, err := RenderProxy(ProxyConfig{Upstream: "frappe:80", Sites: sites})
+	if err != nil {
+		t.Fatalf("EXPECTED the other sites rendered GOT %v", err)
+	}
+	if !strings.Contains(string(data), "hrms.localhost") || strings.Contains(string(data), "api.localhost") {
+		t.Fatalf("EXPECTED hrms.localhost routed and api.localhost not GOT\n%s", data)
+	}
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"goftw/internal/nginx"
+)
+
+// cookieName remembers the site last visited under /{path}/
+const cookieName = "goftw_site"
+
+// Route serves Site under /{Path}/
+type Route struct {
+	Path string
+	Site string
+}
+
+// Proxy serves each site of the bench under /{path}/ by forwarding to the
+// Frappe nginx with the site's Host header, like the generated
+// dynamic-proxy.conf. Framework paths requested from the root (/api, /app,
+// /socket.io, ...) go to the site the page came from.
+type Proxy struct {
+	upstream *url.URL
+	reverse  *httputil.ReverseProxy
+
+	mu          sync.RWMutex // guards the routing table
+	routes      map[string]string
+	defaultSite string
+}
+
+// target is where a request is forwarded, carried in its context
+type target struct {
+	site   string // Host header
+	prefix string // /{path} stripped from the request, added back to redirects and cookies
+	path   string
+}
+
+type targetKey struct{}
+
+// New returns a proxy to upstream, e.g. 127.0.0.1:80, with no routes yet
+func New(upstream string) (*Proxy, error) {
+	if !strings.Contains(upstream, "://") {
+		upstream = "http://" + upstream
+	}
+	u, err := url.Parse(upstream)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy upstream %q", upstream)
+	}
+	p := &Proxy{upstream: u, routes: map[string]string{}}
+	p.reverse = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		ModifyResponse: p.modifyResponse,
+	}
+	return p, nil
+}
+
+// SetRoutes replaces the routing table. Framework paths need a site before
+// any /{path}/ was visited: defaultSite, or the first route by path.
+func (p *Proxy) SetRoutes(routes []Route, defaultSite string) {
+	table := make(map[string]string, len(routes))
+	paths := make([]string, 0, len(routes))
+	for _, route := range routes {
+		table[route.Path] = route.Site
+		paths = append(paths, route.Path)
+	}
+	if defaultSite == "" && len(paths) > 0 {
+		sort.Strings(paths)
+		defaultSite = table[paths[0]]
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.routes = table
+	p.defaultSite = defaultSite
+}
+
+// Routes returns the routing table, sorted by path
+func (p *Proxy) Routes() []Route {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	routes := make([]Route, 0, len(p.routes))
+	for path, site := range p.routes {
+		routes = append(routes, Route{Path: path, Site: site})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return routes
+}
+
+// route returns the site served under path
+func (p *Proxy) route(path string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	site, ok := p.routes[path]
+	return site, ok
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "healthy\n")
+		return
+	}
+
+	segment, rest := splitPath(r.URL.Path)
+	if site, ok := p.route(segment); ok {
+		if rest == "" {
+			http.Redirect(w, r, "/"+segment+"/"+query(r), http.StatusMovedPermanently)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: cookieName, Value: segment, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		p.forward(w, r, target{site: site, prefix: "/" + segment, path: rest})
+		return
+	}
+
+	// A framework path requested by a page of /{path}/: move it under that
+	// path so the browser sends the site's cookies. Assets need no cookies and
+	// WebSockets cannot follow redirects, so those are forwarded as they are.
+	path := p.referringPath(r)
+	if path != "" && slices.Contains(nginx.FrameworkPaths, segment) && segment != "assets" && !isUpgrade(r) {
+		http.Redirect(w, r, "/"+path+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+		return
+	}
+	site, ok := p.route(path)
+	if !ok {
+		p.mu.RLock()
+		site = p.defaultSite
+		p.mu.RUnlock()
+	}
+	if site == "" {
+		site = r.Host
+	}
+	p.forward(w, r, target{site: site, path: r.URL.Path})
+}
+
+// referringPath is the routed path a root request came from: the first
+// segment of its Referer, or the site last visited
+func (p *Proxy) referringPath(r *http.Request) string {
+	if referer, err := url.Parse(r.Referer()); err == nil && referer.Host == r.Host {
+		if segment, _ := splitPath(referer.Path); segment != "" {
+			if _, ok := p.route(segment); ok {
+				return segment
+			}
+		}
+	}
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		if _, ok := p.route(cookie.Value); ok {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, t target) {
+	p.reverse.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), targetKey{}, t)))
+}
+
+// rewrite points the outgoing request at the Frappe nginx as the site
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	t := pr.In.Context().Value(targetKey{}).(target)
+	pr.SetURL(p.upstream)
+	pr.Out.URL.Path = strings.TrimSuffix(p.upstream.Path, "/") + t.path
+	pr.Out.URL.RawPath = ""
+	pr.Out.Host = t.site
+	pr.SetXForwarded()
+}
+
+// modifyResponse keeps redirects and cookies of a site under its /{path}
+func (p *Proxy) modifyResponse(resp *http.Response) error {
+	t, _ := resp.Request.Context().Value(targetKey{}).(target)
+	if t.prefix == "" {
+		return nil
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		resp.Header.Set("Location", prefixLocation(location, t))
+	}
+	cookies := resp.Header.Values("Set-Cookie")
+	if len(cookies) == 0 {
+		return nil
+	}
+	resp.Header.Del("Set-Cookie")
+	for _, line := range cookies {
+		resp.Header.Add("Set-Cookie", prefixCookie(line, t.prefix))
+	}
+	return nil
+}
+
+// prefixLocation moves a redirect to the site's own pages under prefix
+func prefixLocation(location string, t target) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	switch {
+	case u.Host == "" && strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(location, "//"):
+	case u.Host == t.site:
+		// An absolute URL of the site, e.g. from host_name: keep it relative to the proxy
+		u.Scheme, u.Host, u.User = "", "", nil
+	default:
+		return location
+	}
+	u.Path = t.prefix + u.Path
+	u.RawPath = ""
+	return u.String()
+}
+
+// prefixCookie scopes a cookie of the site to prefix; the Domain is the
+// site's own name, which the browser would reject on the proxy's host
+func prefixCookie(line, prefix string) string {
+	cookie, err := http.ParseSetCookie(line)
+	if err != nil {
+		return line
+	}
+	cookie.Path = prefix + "/" + strings.TrimPrefix(cookie.Path, "/")
+	cookie.Domain = ""
+	return cookie.String()
+}
+
+// splitPath splits /{segment}/rest into segment and /rest; rest is empty
+// when the path has no slash after the segment
+func splitPath(path string) (string, string) {
+	path = strings.TrimPrefix(path, "/")
+	segment, rest, found := strings.Cut(path, "/")
+	if !found {
+		return segment, ""
+	}
+	return segment, "/" + rest
+}
+
+// query returns the request's query string with its leading ?
+func query(r *http.Request) string {
+	if r.URL.RawQuery == "" {
+		return ""
+	}
+	return "?" + r.URL.RawQuery
+}
+
+// isUpgrade reports whether r asks to switch protocols, e.g. to a WebSocket
+func isUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" && strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade")
+}
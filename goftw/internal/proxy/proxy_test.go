@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestProxy serves the routes through a proxy to a fake Frappe nginx that
+// echoes the Host and path it got
+func newTestProxy(t *testing.T) *httptest.Server {
+	t.Helper()
+	frappe := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "abc", Path: "/", Domain: r.Host})
+			http.Redirect(w, r, "/app/home", http.StatusFound)
+			return
+		case "/ws":
+			conn, buf, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Errorf("hijack: %v", err)
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+			buf.Flush()
+			line, _ := buf.ReadString('\n')
+			fmt.Fprintf(conn, "%s %s", r.Host, line)
+			return
+		}
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.RequestURI())
+	}))
+	t.Cleanup(frappe.Close)
+
+	p, err := New(frappe.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	p.SetRoutes([]Route{{Path: "hrms", Site: "hrms.localhost"}, {Path: "erp", Site: "erp.example.com"}}, "")
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestProxyRouting tests path routing, framework paths and redirect and cookie rewriting
+func TestProxyRouting(t *testing.T) {
+	server := newTestProxy(t)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	tests := []struct {
+		name     string
+		path     string
+		header   map[string]string
+		status   int
+		body     string
+		location string
+	}{
+		{name: "site path", path: "/hrms/app/employee?x=1", status: 200, body: "hrms.localhost /app/employee?x=1"},
+		{name: "site root", path: "/erp/", status: 200, body: "erp.example.com /"},
+		{name: "site without slash", path: "/hrms", status: 301, location: "/hrms/"},
+		{name: "default site", path: "/", status: 200, body: "erp.example.com /"},
+		{name: "assets from a page", path: "/assets/frappe/app.js", header: map[string]string{"Referer": server.URL + "/hrms/app"}, status: 200, body: "hrms.localhost /assets/frappe/app.js"},
+		{name: "api from a page", path: "/api/method/ping", header: map[string]string{"Referer": server.URL + "/hrms/app"}, status: 307, location: "/hrms/api/method/ping"},
+		{name: "api after a visit", path: "/app/home", header: map[string]string{"Cookie": "goftw_site=hrms"}, status: 307, location: "/hrms/app/home"},
+		{name: "unknown visited site", path: "/app/home", header: map[string]string{"Cookie": "goftw_site=gone"}, status: 200, body: "erp.example.com /app/home"},
+		{name: "redirect under the site", path: "/hrms/login", status: 302, location: "/hrms/app/home"},
+		{name: "health", path: "/health", status: 200, body: "healthy\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", server.URL+tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("EXPECTED no error GOT %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("EXPECTED %d GOT %d %s", tt.status, resp.StatusCode, body)
+			}
+			if tt.body != "" && string(body) != tt.body {
+				t.Fatalf("EXPECTED %q GOT %q", tt.body, body)
+			}
+			if location := resp.Header.Get("Location"); location != tt.location {
+				t.Fatalf("EXPECTED Location %q GOT %q", tt.location, location)
+			}
+		})
+	}
+
+	resp, err := client.Get(server.URL + "/hrms/login")
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	resp.Body.Close()
+	cookies := resp.Header.Values("Set-Cookie")
+	expected := []string{"goftw_site=hrms; Path=/; HttpOnly; SameSite=Lax", "sid=abc; Path=/hrms/"}
+	if strings.Join(cookies, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("EXPECTED cookies %q GOT %q", expected, cookies)
+	}
+}
+
+// TestProxyWebSocket tests that upgraded connections are relayed both ways
+func TestProxyWebSocket(t *testing.T) {
+	server := newTestProxy(t)
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET /hrms/ws HTTP/1.1\r\nHost: proxy\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("EXPECTED 101 GOT %d", resp.StatusCode)
+	}
+
+	fmt.Fprintf(conn, "ping\n")
+	echo, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if echo != "hrms.localhost ping\n" {
+		t.Fatalf("EXPECTED %q GOT %q", "hrms.localhost ping\n", echo)
+	}
+}
+
+// TestSetRoutes tests that the routing table is replaced as sites change
+func TestSetRoutes(t *testing.T) {
+	p, err := New("127.0.0.1:80")
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	p.SetRoutes([]Route{{Path: "b", Site: "b.localhost"}, {Path: "a", Site: "a.localhost"}}, "")
+	if p.defaultSite != "a.localhost" {
+		t.Fatalf("EXPECTED default a.localhost GOT %s", p.defaultSite)
+	}
+	p.SetRoutes([]Route{{Path: "b", Site: "b.localhost"}}, "")
+	if routes := p.Routes(); len(routes) != 1 || routes[0].Path != "b" || p.defaultSite != "b.localhost" {
+		t.Fatalf("EXPECTED only b GOT %+v, default %s", routes, p.defaultSite)
+	}
+}
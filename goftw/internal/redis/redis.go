@@ -1,13 +1,18 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	"goftw/internal/whoiam"
 )
 
+// pingTimeout bounds each attempt of WaitForRedis
+const pingTimeout = 10 * time.Second
+
 type Config struct {
 	URL   string
 	Debug bool
@@ -39,7 +44,10 @@ func WaitForRedis(cfg Config) error {
 
 	fmt.Printf("[REDIS] waiting for Redis at %s:%s...\n", host, port)
 	for {
-		if _, err := whoiam.ExecRunSwallowIO("redis-cli", "-h", host, "-p", port, "ping"); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := Ping(ctx, cfg)
+		cancel()
+		if err == nil {
 			fmt.Printf("[REDIS] Redis %s:%s reachable.\n", host, port)
 			return nil
 		}
@@ -49,3 +57,19 @@ func WaitForRedis(cfg Config) error {
 		time.Sleep(2 * time.Second)
 	}
 }
+
+// Ping checks once whether Redis answers PING, killing redis-cli when ctx is done
+func Ping(ctx context.Context, cfg Config) error {
+	host, port := parseHostPort(cfg.URL)
+	if host == "" || port == "" {
+		return fmt.Errorf("invalid redis url: %s", cfg.URL)
+	}
+	out, err := whoiam.ExecRunSwallowIOContext(ctx, "redis-cli", "-h", host, "-p", port, "ping")
+	if err != nil {
+		return fmt.Errorf("redis-cli ping failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if reply := strings.TrimSpace(string(out)); reply != "PONG" {
+		return fmt.Errorf("unexpected ping reply from %s:%s: %s", host, port, reply)
+	}
+	return nil
+}
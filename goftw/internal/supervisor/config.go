@@ -0,0 +1,397 @@
+package supervisor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"goftw/internal/ini"
+)
+
+// ProgramConfig is one [program:x] section of a supervisord config
+type ProgramConfig struct {
+	Name           string
+	Command        string
+	Directory      string
+	Environment    []string // KEY=value pairs added to goftw's environment
+	User           string
+	Priority       int
+	AutoStart      bool
+	AutoRestart    string // true, false or unexpected
+	ExitCodes      []int  // expected exit codes for autorestart=unexpected
+	StartSecs      time.Duration
+	StartRetries   int
+	StopSignal     syscall.Signal
+	StopWaitSecs   time.Duration
+	StopAsGroup    bool
+	KillAsGroup    bool
+	NumProcs       int
+	ProcessName    string
+	StdoutLogfile  string
+	StderrLogfile  string
+	RedirectStderr bool
+	LogMaxBytes    int64
+	LogBackups     int
+}
+
+// Config is the set of programs and groups in a supervisord config
+type Config struct {
+	Programs []ProgramConfig
+	Groups   map[string][]string // group name -> program names
+	Here     string              // directory of the config file, for %(here)s
+}
+
+// LoadConfig reads the programs and groups of the supervisord config at path.
+// Sections other than [program:x] and [group:x] are ignored.
+func LoadConfig(path string) (*Config, error) {
+	file, err := ini.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	here, _ := filepath.Abs(filepath.Dir(path))
+	return ParseConfig(file, here)
+}
+
+// ParseConfig extracts programs and groups from a parsed supervisord config
+func ParseConfig(file *ini.File, here string) (*Config, error) {
+	cfg := &Config{Groups: map[string][]string{}, Here: here}
+	for _, section := range file.Sections {
+		switch {
+		case strings.HasPrefix(section.Name, "program:"):
+			program, err := parseProgram(section)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Programs = append(cfg.Programs, program)
+		case strings.HasPrefix(section.Name, "group:"):
+			name := strings.TrimPrefix(section.Name, "group:")
+			cfg.Groups[name] = splitList(section.Value("programs", ""))
+		case section.Name == "include":
+			fmt.Printf("[SUPERVISOR] [include] is not supported, ignoring files=%s\n", section.Value("files", ""))
+		}
+	}
+
+	for group, programs := range cfg.Groups {
+		for _, name := range programs {
+			if cfg.program(name) == nil {
+				return nil, fmt.Errorf("group %s: unknown program %s", group, name)
+			}
+		}
+	}
+	sort.SliceStable(cfg.Programs, func(i, j int) bool { return cfg.Programs[i].Priority < cfg.Programs[j].Priority })
+	return cfg, nil
+}
+
+// program returns the program called name, or nil
+func (c *Config) program(name string) *ProgramConfig {
+	for i := range c.Programs {
+		if c.Programs[i].Name == name {
+			return &c.Programs[i]
+		}
+	}
+	return nil
+}
+
+// GroupOf returns the group a program belongs to; ungrouped programs are their own group
+func (c *Config) GroupOf(program string) string {
+	groups := make([]string, 0, len(c.Groups))
+	for group := range c.Groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for _, name := range c.Groups[group] {
+			if name == program {
+				return group
+			}
+		}
+	}
+	return program
+}
+
+// parseProgram reads a [program:x] section, applying supervisord's defaults
+func parseProgram(section *ini.Section) (ProgramConfig, error) {
+	name := strings.TrimPrefix(section.Name, "program:")
+	p := ProgramConfig{
+		Name:          name,
+		Command:       section.Value("command", ""),
+		Directory:     section.Value("directory", ""),
+		User:          section.Value("user", ""),
+		AutoRestart:   strings.ToLower(section.Value("autorestart", "unexpected")),
+		ProcessName:   section.Value("process_name", "%(program_name)s"),
+		StdoutLogfile: section.Value("stdout_logfile", "AUTO"),
+		StderrLogfile: section.Value("stderr_logfile", "AUTO"),
+	}
+	if p.Command == "" {
+		return p, fmt.Errorf("program %s: command is required", name)
+	}
+
+	var err error
+	fail := func(key string, e error) error {
+		return fmt.Errorf("program %s: invalid %s: %v", name, key, e)
+	}
+	if p.Priority, err = strconv.Atoi(section.Value("priority", "999")); err != nil {
+		return p, fail("priority", err)
+	}
+	if p.AutoStart, err = parseBool(section.Value("autostart", "true")); err != nil {
+		return p, fail("autostart", err)
+	}
+	if p.AutoRestart != "unexpected" {
+		restart, err := parseBool(p.AutoRestart)
+		if err != nil {
+			return p, fail("autorestart", err)
+		}
+		p.AutoRestart = strconv.FormatBool(restart)
+	}
+	for _, code := range splitList(section.Value("exitcodes", "0")) {
+		n, err := strconv.Atoi(code)
+		if err != nil {
+			return p, fail("exitcodes", err)
+		}
+		p.ExitCodes = append(p.ExitCodes, n)
+	}
+	startSecs, err := strconv.Atoi(section.Value("startsecs", "1"))
+	if err != nil {
+		return p, fail("startsecs", err)
+	}
+	p.StartSecs = time.Duration(startSecs) * time.Second
+	if p.StartRetries, err = strconv.Atoi(section.Value("startretries", "3")); err != nil {
+		return p, fail("startretries", err)
+	}
+	if p.StopSignal, err = parseSignal(section.Value("stopsignal", "TERM")); err != nil {
+		return p, fail("stopsignal", err)
+	}
+	stopWait, err := strconv.Atoi(section.Value("stopwaitsecs", "10"))
+	if err != nil {
+		return p, fail("stopwaitsecs", err)
+	}
+	p.StopWaitSecs = time.Duration(stopWait) * time.Second
+	if p.StopAsGroup, err = parseBool(section.Value("stopasgroup", "false")); err != nil {
+		return p, fail("stopasgroup", err)
+	}
+	if p.KillAsGroup, err = parseBool(section.Value("killasgroup", strconv.FormatBool(p.StopAsGroup))); err != nil {
+		return p, fail("killasgroup", err)
+	}
+	if p.NumProcs, err = strconv.Atoi(section.Value("numprocs", "1")); err != nil || p.NumProcs < 1 {
+		return p, fail("numprocs", fmt.Errorf("%q", section.Value("numprocs", "")))
+	}
+	if p.NumProcs > 1 && !strings.Contains(p.ProcessName, "process_num") {
+		return p, fmt.Errorf("program %s: numprocs > 1 requires %%(process_num) in process_name", name)
+	}
+	if p.RedirectStderr, err = parseBool(section.Value("redirect_stderr", "false")); err != nil {
+		return p, fail("redirect_stderr", err)
+	}
+	if p.LogMaxBytes, err = parseBytes(section.Value("stdout_logfile_maxbytes", "50MB")); err != nil {
+		return p, fail("stdout_logfile_maxbytes", err)
+	}
+	if p.LogBackups, err = strconv.Atoi(section.Value("stdout_logfile_backups", "10")); err != nil {
+		return p, fail("stdout_logfile_backups", err)
+	}
+	if p.Environment, err = parseEnvironment(section.Value("environment", "")); err != nil {
+		return p, fail("environment", err)
+	}
+	return p, nil
+}
+
+// parseBool accepts supervisord's boolean spellings
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("not a boolean: %q", value)
+}
+
+// signals are the signal names supervisord accepts
+var signals = map[string]syscall.Signal{
+	"TERM": syscall.SIGTERM, "HUP": syscall.SIGHUP, "INT": syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT, "KILL": syscall.SIGKILL, "USR1": syscall.SIGUSR1, "USR2": syscall.SIGUSR2,
+}
+
+// parseSignal accepts TERM, SIGTERM, HUP, INT, QUIT, KILL, USR1 and USR2
+func parseSignal(value string) (syscall.Signal, error) {
+	if sig, ok := signals[strings.TrimPrefix(strings.ToUpper(value), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", value)
+}
+
+// signalName returns supervisord's name for sig, e.g. HUP
+func signalName(sig syscall.Signal) (string, error) {
+	for name, s := range signals {
+		if s == sig {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported signal %v", sig)
+}
+
+// parseBytes reads sizes like 1024, 10KB, 50MB or 1GB
+func parseBytes(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if strings.HasSuffix(value, suffix) {
+			value, multiplier = strings.TrimSuffix(value, suffix), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	return n * multiplier, err
+}
+
+// splitList splits a comma separated list, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseEnvironment reads `KEY="value",KEY2=value2` into KEY=value pairs
+func parseEnvironment(value string) ([]string, error) {
+	var env []string
+	var key, current strings.Builder
+	inValue, quote := false, rune(0)
+	flush := func() error {
+		k := strings.TrimSpace(key.String())
+		if k == "" && current.Len() == 0 {
+			return nil
+		}
+		if k == "" || !inValue {
+			return fmt.Errorf("expected KEY=value in %q", value)
+		}
+		env = append(env, k+"="+current.String())
+		key.Reset()
+		current.Reset()
+		inValue = false
+		return nil
+	}
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case !inValue && r == '=':
+			inValue = true
+		case !inValue:
+			key.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',' || r == '\n':
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		case r == ' ' && current.Len() == 0:
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", value)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+var expansionRegex = regexp.MustCompile(`%\(([A-Za-z0-9_]+)\)(-?\d*)([sd])`)
+
+// expand replaces supervisord %(name)s and %(name)02d expressions using vars;
+// %(ENV_X)s reads the environment
+func expand(value string, vars map[string]string) (string, error) {
+	var err error
+	out := expansionRegex.ReplaceAllStringFunc(value, func(match string) string {
+		parts := expansionRegex.FindStringSubmatch(match)
+		name, width, verb := parts[1], parts[2], parts[3]
+		v, ok := vars[name]
+		if !ok && strings.HasPrefix(name, "ENV_") {
+			v, ok = os.LookupEnv(strings.TrimPrefix(name, "ENV_"))
+		}
+		if !ok {
+			err = fmt.Errorf("unknown expansion %%(%s)", name)
+			return match
+		}
+		if verb == "d" {
+			n, convErr := strconv.Atoi(v)
+			if convErr != nil {
+				err = fmt.Errorf("%%(%s)d is not a number: %q", name, v)
+				return match
+			}
+			return fmt.Sprintf("%"+width+"d", n)
+		}
+		return fmt.Sprintf("%"+width+"s", v)
+	})
+	return out, err
+}
+
+// SplitCommand splits a command line like a POSIX shell would, without expansions
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg, quote, escaped := false, rune(0), false
+	for _, r := range command {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+// JoinCommand is the inverse of SplitCommand, quoting arguments that need it
+func JoinCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n\"';\\") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
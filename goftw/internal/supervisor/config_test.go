@@ -0,0 +1,163 @@
+package supervisor
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"goftw/internal/ini"
+)
+
+// benchConf is trimmed from `bench setup supervisor --skip-redis` output
+const benchConf = `
+[program:nginx]
+command=/usr/sbin/nginx -g "daemon off;"
+priority=10
+
+[program:frappe-bench-frappe-web]
+command=/home/frappe/frappe-bench/env/bin/gunicorn -b 127.0.0.1:8000 -w 2 frappe.app:application --preload
+priority=4
+autostart=true
+autorestart=true
+stdout_logfile=/home/frappe/frappe-bench/logs/web.log
+stopwaitsecs=40
+killasgroup=true
+user=frappe
+directory=/home/frappe/frappe-bench/sites
+startretries=10
+
+[program:frappe-bench-frappe-short-worker]
+command=/usr/local/bin/bench worker --queue short,default
+environment=FRAPPE_QUEUE="short",DEBUG=1
+stopwaitsecs=360
+numprocs=2
+process_name=%(program_name)s-%(process_num)d
+
+[group:frappe-bench-web]
+programs=frappe-bench-frappe-web
+
+[group:frappe-bench-workers]
+programs=frappe-bench-frappe-short-worker
+`
+
+// TestParseConfig tests defaults, priorities and groups of a bench config
+func TestParseConfig(t *testing.T) {
+	file, err := ini.Parse(strings.NewReader(benchConf))
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	cfg, err := ParseConfig(file, "/tmp")
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+
+	var order []string
+	for _, p := range cfg.Programs {
+		order = append(order, p.Name)
+	}
+	expectedOrder := []string{"frappe-bench-frappe-web", "nginx", "frappe-bench-frappe-short-worker"}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Fatalf("EXPECTED priority order %v GOT %v", expectedOrder, order)
+	}
+
+	web := cfg.Programs[0]
+	if web.AutoRestart != "true" || web.StopWaitSecs != 40*time.Second || !web.KillAsGroup || web.StartRetries != 10 {
+		t.Fatalf("EXPECTED web settings from config GOT %+v", web)
+	}
+	worker := cfg.Programs[2]
+	if worker.AutoRestart != "unexpected" || worker.StopSignal != syscall.SIGTERM || worker.StartSecs != time.Second {
+		t.Fatalf("EXPECTED supervisord defaults GOT %+v", worker)
+	}
+	if !reflect.DeepEqual(worker.Environment, []string{"FRAPPE_QUEUE=short", "DEBUG=1"}) {
+		t.Fatalf("EXPECTED parsed environment GOT %v", worker.Environment)
+	}
+	if group := cfg.GroupOf("frappe-bench-frappe-short-worker"); group != "frappe-bench-workers" {
+		t.Fatalf("EXPECTED group frappe-bench-workers GOT %s", group)
+	}
+	if group := cfg.GroupOf("nginx"); group != "nginx" {
+		t.Fatalf("EXPECTED ungrouped program to be its own group GOT %s", group)
+	}
+
+	sup, err := New(cfg, "/tmp/logs")
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	workers, err := sup.match("frappe-bench-workers:*")
+	if err != nil || len(workers) != 2 || workers[1].name != "frappe-bench-frappe-short-worker-1" {
+		t.Fatalf("EXPECTED two numbered workers GOT %v %v", workers, err)
+	}
+	if nginx := sup.processes[1]; !reflect.DeepEqual(nginx.args, []string{"/usr/sbin/nginx", "-g", "daemon off;"}) {
+		t.Fatalf("EXPECTED quoted argument kept whole GOT %q", nginx.args)
+	}
+}
+
+// TestExpand tests supervisord %(name)s expansions
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"program_name": "worker", "process_num": "3"}
+	tests := []struct {
+		input    string
+		expected string
+		errMatch string
+	}{
+		{"%(program_name)s-%(process_num)d", "worker-3", ""},
+		{"%(program_name)s_%(process_num)02d", "worker_03", ""},
+		{"no expansion", "no expansion", ""},
+		{"%(missing)s", "", "unknown expansion"},
+	}
+	for _, tt := range tests {
+		got, err := expand(tt.input, vars)
+		if tt.errMatch != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Fatalf("EXPECTED error containing %q GOT %v", tt.errMatch, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expected {
+			t.Fatalf("EXPECTED %q GOT %q (%v)", tt.expected, got, err)
+		}
+	}
+}
+
+// TestParseProgramErrors tests invalid program sections
+func TestParseProgramErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		errMatch string
+	}{
+		{"missing command", "[program:a]\npriority=1\n", "command is required"},
+		{"bad bool", "[program:a]\ncommand=x\nautostart=maybe\n", "invalid autostart"},
+		{"numprocs without process_num", "[program:a]\ncommand=x\nnumprocs=2\n", "requires %(process_num)"},
+		{"unknown group member", "[program:a]\ncommand=x\n[group:g]\nprograms=a,b\n", "unknown program b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ini.Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("EXPECTED valid ini GOT %v", err)
+			}
+			_, err = ParseConfig(file, "/tmp")
+			if err == nil || !strings.Contains(err.Error(), tt.errMatch) {
+				t.Fatalf("EXPECTED error containing %q GOT %v", tt.errMatch, err)
+			}
+		})
+	}
+}
+
+// TestJoinCommand tests that joined commands split back into the same arguments
+func TestJoinCommand(t *testing.T) {
+	tests := [][]string{
+		{"/usr/sbin/nginx", "-g", "daemon off;"},
+		{"gunicorn", "-w", "2", "frappe.app:application"},
+		{"sh", "-c", `echo "quoted" \\ done`},
+		{"bench", ""},
+	}
+	for _, args := range tests {
+		got, err := SplitCommand(JoinCommand(args))
+		if err != nil || !reflect.DeepEqual(got, args) {
+			t.Fatalf("EXPECTED %q GOT %q (%v) from %s", args, got, err, JoinCommand(args))
+		}
+	}
+}
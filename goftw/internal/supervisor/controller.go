@@ -0,0 +1,57 @@
+package supervisor
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"goftw/internal/entity"
+)
+
+// Process states, named as supervisord names them
+const (
+	StateStopped  = "STOPPED"
+	StateStarting = "STARTING"
+	StateRunning  = "RUNNING"
+	StateBackoff  = "BACKOFF"
+	StateStopping = "STOPPING"
+	StateExited   = "EXITED"
+	StateFatal    = "FATAL"
+	StateUnknown  = "UNKNOWN"
+)
+
+// Controller starts, stops and inspects supervised programs. It is implemented
+// by the native Supervisor and by a supervisord client.
+//
+// Names follow supervisorctl: `group:process`, `group:*` for a whole group, or a
+// bare program or group name.
+type Controller interface {
+	Programs() ([]entity.ProgramStatus, error)
+	StartProgram(name string) error
+	StopProgram(name string) error
+	RestartProgram(name string) error
+	// SignalProgram sends sig to running processes, e.g. HUP to gunicorn
+	SignalProgram(name string, sig syscall.Signal) error
+	// Update applies cfg like `supervisorctl update`, restarting only the groups
+	// that were added, removed or changed, and returns their names. supervisord
+	// rereads its own config file, which must already hold cfg.
+	Update(cfg *Config) ([]string, error)
+	// TailLog returns up to the last n bytes of a process's stdout or stderr log
+	TailLog(name, stream string, n int64) ([]byte, error)
+}
+
+// ErrNoSuchProcess is returned for names matching no process
+type ErrNoSuchProcess struct{ Name string }
+
+func (e ErrNoSuchProcess) Error() string {
+	return fmt.Sprintf("no such process: %s", e.Name)
+}
+
+// splitName splits a supervisorctl name into group and process; a bare name
+// has an empty process
+func splitName(name string) (group, process string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
@@ -0,0 +1,109 @@
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// logFile is an append-only log that rotates to path.1 … path.N at maxBytes
+type logFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	backups  int
+	file     *os.File
+	size     int64
+}
+
+// openLog opens path for appending, creating its directory
+func openLog(path string, maxBytes int64, backups int) (*logFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	l := &logFile{path: path, maxBytes: maxBytes, backups: backups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if it would exceed maxBytes
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return 0, os.ErrClosed
+	}
+	if l.maxBytes > 0 && l.size+int64(len(p)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			fmt.Printf("[SUPERVISOR] Failed to rotate %s: %v\n", l.path, err)
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.i to path.i+1 and starts a new file. Caller holds l.mu.
+func (l *logFile) rotate() error {
+	l.file.Close()
+	l.file = nil
+	if l.backups > 0 {
+		for i := l.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+		}
+		if err := os.Rename(l.path, l.path+".1"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := os.Truncate(l.path, 0); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return l.open()
+}
+
+// Close closes the file
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// tailFile returns up to the last n bytes of the file at path
+func tailFile(path string, n int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-n, 0)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
@@ -0,0 +1,377 @@
+package supervisor
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"goftw/internal/entity"
+)
+
+// process is one instance of a program; numprocs > 1 gives several
+type process struct {
+	name    string
+	group   string
+	program ProgramConfig // with command, directory and log paths expanded
+	args    []string
+
+	mu         sync.Mutex
+	state      string
+	cmd        *exec.Cmd
+	startedAt  time.Time
+	stoppedAt  time.Time
+	exitStatus int
+	desc       string
+	loop       *loop // current supervision loop, nil when idle
+}
+
+// loop is one run of supervise, from start until stop, FATAL or a final exit
+type loop struct {
+	stop chan struct{} // closed to ask the loop to stop
+	done chan struct{} // closed when the loop has returned
+}
+
+// newProcess expands the program's settings for instance num of group
+func newProcess(program ProgramConfig, group string, num int, here string) (*process, error) {
+	vars := map[string]string{
+		"program_name":   program.Name,
+		"group_name":     group,
+		"process_num":    strconv.Itoa(num),
+		"numprocs":       strconv.Itoa(program.NumProcs),
+		"here":           here,
+		"host_node_name": hostname(),
+	}
+	name, err := expand(program.ProcessName, vars)
+	if err != nil {
+		return nil, fmt.Errorf("program %s: process_name: %v", program.Name, err)
+	}
+	vars["process_name"] = name
+	for _, field := range []*string{&program.Command, &program.Directory, &program.StdoutLogfile, &program.StderrLogfile} {
+		if *field, err = expand(*field, vars); err != nil {
+			return nil, fmt.Errorf("program %s: %v", program.Name, err)
+		}
+	}
+	args, err := SplitCommand(program.Command)
+	if err != nil {
+		return nil, fmt.Errorf("program %s: command: %v", program.Name, err)
+	}
+	return &process{name: name, group: group, program: program, args: args, state: StateStopped}, nil
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// start begins supervising the process. It is an error if it is already started.
+func (p *process) start(logDir string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loop != nil {
+		return fmt.Errorf("%s:%s already started (%s)", p.group, p.name, p.state)
+	}
+	l := &loop{stop: make(chan struct{}), done: make(chan struct{})}
+	p.loop = l
+	p.state = StateStarting
+	go p.supervise(l, logDir)
+	return nil
+}
+
+// stop asks the supervision loop to end and waits for it. The loop sends the
+// stop signal and SIGKILL after stopwaitsecs, or at once when force is closed.
+func (p *process) stop(force <-chan struct{}) {
+	p.mu.Lock()
+	l := p.loop
+	p.mu.Unlock()
+	if l == nil {
+		return
+	}
+	select {
+	case <-l.stop:
+	default:
+		close(l.stop)
+	}
+	select {
+	case <-l.done:
+	case <-force:
+		p.kill()
+		<-l.done
+	}
+}
+
+// supervise runs the process until stopped, restarting it per the program's
+// policy. Exits before startsecs back off and retry up to startretries times.
+func (p *process) supervise(l *loop, logDir string) {
+	defer func() {
+		p.mu.Lock()
+		p.loop = nil
+		p.mu.Unlock()
+		close(l.done)
+	}()
+
+	retries := 0
+	for {
+		cmd, exited, err := p.spawn(logDir)
+		if err == nil {
+			running, stopped := p.wait(l, cmd, exited)
+			if stopped {
+				p.setState(StateStopped, "")
+				return
+			}
+			status := cmd.ProcessState
+			p.mu.Lock()
+			p.exitStatus = status.ExitCode()
+			p.mu.Unlock()
+			if running {
+				retries = 0
+				if !p.shouldRestart(status.ExitCode()) {
+					p.setState(StateExited, status.String())
+					fmt.Printf("[SUPERVISOR] %s exited (%s), not restarting\n", p.name, status)
+					return
+				}
+				p.setState(StateExited, status.String())
+				fmt.Printf("[SUPERVISOR] %s exited (%s), restarting\n", p.name, status)
+				continue
+			}
+			err = fmt.Errorf("exited too quickly (%s)", status)
+		}
+
+		retries++
+		if retries > p.program.StartRetries {
+			p.setState(StateFatal, err.Error())
+			fmt.Printf("[SUPERVISOR] %s entered FATAL state, too many start retries: %v\n", p.name, err)
+			return
+		}
+		p.setState(StateBackoff, err.Error())
+		fmt.Printf("[SUPERVISOR] %s: %v, retrying in %ds\n", p.name, err, retries)
+		select {
+		case <-time.After(time.Duration(retries) * time.Second):
+		case <-l.stop:
+			p.setState(StateStopped, "")
+			return
+		}
+	}
+}
+
+// spawn starts the process with its logs attached. exited is closed once it
+// has been reaped.
+func (p *process) spawn(logDir string) (*exec.Cmd, chan struct{}, error) {
+	stdout, stderr, closeLogs, err := p.openLogs(logDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open logs: %v", err)
+	}
+
+	cmd := exec.Command(p.args[0], p.args[1:]...)
+	cmd.Dir = p.program.Directory
+	cmd.Env = append(os.Environ(), p.program.Environment...)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	// Own process group so stopasgroup/killasgroup reach gunicorn's workers
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := p.setUser(cmd); err != nil {
+		closeLogs()
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	p.state = StateStarting
+	p.mu.Unlock()
+	if err := cmd.Start(); err != nil {
+		closeLogs()
+		return nil, nil, fmt.Errorf("spawn error: %v", err)
+	}
+
+	exited := make(chan struct{})
+	p.mu.Lock()
+	p.cmd = cmd
+	p.startedAt = time.Now()
+	p.desc = ""
+	p.mu.Unlock()
+	go func() {
+		cmd.Wait()
+		closeLogs()
+		p.mu.Lock()
+		p.cmd = nil
+		p.stoppedAt = time.Now()
+		p.mu.Unlock()
+		close(exited)
+	}()
+	return cmd, exited, nil
+}
+
+// wait watches a spawned process until it exits or the loop is stopped.
+// running reports whether it survived startsecs.
+func (p *process) wait(l *loop, cmd *exec.Cmd, exited chan struct{}) (running, stopped bool) {
+	startTimer := time.NewTimer(p.program.StartSecs)
+	defer startTimer.Stop()
+	for {
+		select {
+		case <-startTimer.C:
+			running = true
+			p.setState(StateRunning, "")
+		case <-exited:
+			// A process that exits exactly as startsecs elapses still counts as started
+			return running || p.program.StartSecs == 0, false
+		case <-l.stop:
+			p.terminate(cmd, exited)
+			return running, true
+		}
+	}
+}
+
+// terminate sends the stop signal, then SIGKILL after stopwaitsecs
+func (p *process) terminate(cmd *exec.Cmd, exited chan struct{}) {
+	p.setState(StateStopping, "")
+	p.signal(cmd, p.program.StopSignal, p.program.StopAsGroup)
+	select {
+	case <-exited:
+	case <-time.After(p.program.StopWaitSecs):
+		fmt.Printf("[SUPERVISOR] %s did not stop within %s, killing\n", p.name, p.program.StopWaitSecs)
+		p.signal(cmd, syscall.SIGKILL, p.program.KillAsGroup)
+		<-exited
+	}
+}
+
+// kill sends SIGKILL to the running process, if any
+func (p *process) kill() {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.mu.Unlock()
+	if cmd != nil {
+		p.signal(cmd, syscall.SIGKILL, p.program.KillAsGroup)
+	}
+}
+
+// signal sends sig to the process, or to its whole process group
+func (p *process) signal(cmd *exec.Cmd, sig syscall.Signal, group bool) {
+	pid := cmd.Process.Pid
+	if group {
+		pid = -pid
+	}
+	if err := syscall.Kill(pid, sig); err != nil && err != syscall.ESRCH {
+		fmt.Printf("[SUPERVISOR] Failed to signal %s: %v\n", p.name, err)
+	}
+}
+
+// shouldRestart applies autorestart to an exit with code
+func (p *process) shouldRestart(code int) bool {
+	switch p.program.AutoRestart {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	for _, expected := range p.program.ExitCodes {
+		if code == expected {
+			return false
+		}
+	}
+	return true
+}
+
+// setUser runs the process as the program's user when goftw is root. Otherwise
+// it runs as goftw's own user.
+func (p *process) setUser(cmd *exec.Cmd) error {
+	if p.program.User == "" || os.Geteuid() != 0 {
+		return nil
+	}
+	u, err := user.Lookup(p.program.User)
+	if err != nil {
+		return fmt.Errorf("unknown user %s: %v", p.program.User, err)
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	cmd.Env = append(cmd.Env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+	return nil
+}
+
+// logPath resolves a configured log file: AUTO logs to logDir, NONE discards
+func (p *process) logPath(configured, stream, logDir string) string {
+	switch configured {
+	case "NONE":
+		return ""
+	case "", "AUTO":
+		return fmt.Sprintf("%s/%s-%s.log", logDir, p.name, stream)
+	}
+	return configured
+}
+
+// openLogs opens the stdout and stderr logs; closeLogs closes both
+func (p *process) openLogs(logDir string) (stdout, stderr io.Writer, closeLogs func(), err error) {
+	var opened []*logFile
+	closeLogs = func() {
+		for _, l := range opened {
+			l.Close()
+		}
+	}
+	open := func(path string) (io.Writer, error) {
+		if path == "" {
+			return nil, nil
+		}
+		l, err := openLog(path, p.program.LogMaxBytes, p.program.LogBackups)
+		if err != nil {
+			return nil, err
+		}
+		opened = append(opened, l)
+		return l, nil
+	}
+
+	if stdout, err = open(p.logPath(p.program.StdoutLogfile, "stdout", logDir)); err != nil {
+		closeLogs()
+		return nil, nil, nil, err
+	}
+	if p.program.RedirectStderr {
+		return stdout, stdout, closeLogs, nil
+	}
+	if stderr, err = open(p.logPath(p.program.StderrLogfile, "stderr", logDir)); err != nil {
+		closeLogs()
+		return nil, nil, nil, err
+	}
+	return stdout, stderr, closeLogs, nil
+}
+
+// setState records state and, when set, a description of why
+func (p *process) setState(state, desc string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = state
+	if desc != "" {
+		p.desc = desc
+	}
+}
+
+// status returns the process's state for the API
+func (p *process) status(logDir string) entity.ProgramStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := entity.ProgramStatus{
+		Name:          p.name,
+		Group:         p.group,
+		State:         p.state,
+		ExitStatus:    p.exitStatus,
+		Description:   p.desc,
+		StdoutLogfile: p.logPath(p.program.StdoutLogfile, "stdout", logDir),
+		StderrLogfile: p.logPath(p.program.StderrLogfile, "stderr", logDir),
+	}
+	if p.program.RedirectStderr {
+		status.StderrLogfile = status.StdoutLogfile
+	}
+	if !p.startedAt.IsZero() {
+		startedAt := p.startedAt
+		status.StartedAt = &startedAt
+	}
+	if p.cmd != nil {
+		status.PID = p.cmd.Process.Pid
+		status.UptimeSeconds = time.Since(p.startedAt).Seconds()
+	} else if !p.stoppedAt.IsZero() {
+		stoppedAt := p.stoppedAt
+		status.StoppedAt = &stoppedAt
+	}
+	return status
+}
@@ -0,0 +1,335 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+
+	"goftw/internal/entity"
+)
+
+// Supervisor runs the programs of a supervisord config as goftw's own children
+type Supervisor struct {
+	logDir string // where AUTO logs go
+	done   chan struct{}
+
+	mu        sync.Mutex // guards the fields below
+	config    *Config
+	processes []*process // in priority order
+	shutdown  bool
+}
+
+// New prepares every process of cfg without starting any
+func New(cfg *Config, logDir string) (*Supervisor, error) {
+	processes, err := newProcesses(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Supervisor{config: cfg, logDir: logDir, processes: processes, done: make(chan struct{})}, nil
+}
+
+// newProcesses prepares the processes of cfg's programs, only those in groups
+// when it is not nil
+func newProcesses(cfg *Config, groups map[string]bool) ([]*process, error) {
+	var processes []*process
+	for _, program := range cfg.Programs {
+		group := cfg.GroupOf(program.Name)
+		if groups != nil && !groups[group] {
+			continue
+		}
+		for num := 0; num < program.NumProcs; num++ {
+			p, err := newProcess(program, group, num, cfg.Here)
+			if err != nil {
+				return nil, err
+			}
+			processes = append(processes, p)
+		}
+	}
+	return processes, nil
+}
+
+// list returns the current processes
+func (s *Supervisor) list() []*process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*process(nil), s.processes...)
+}
+
+// Start starts every autostart program in priority order
+func (s *Supervisor) Start() error {
+	for _, p := range s.list() {
+		if !p.program.AutoStart {
+			continue
+		}
+		if err := p.start(s.logDir); err != nil {
+			return err
+		}
+		fmt.Printf("[SUPERVISOR] Started %s:%s\n", p.group, p.name)
+	}
+	return nil
+}
+
+// Shutdown stops every process in reverse priority order, killing whatever is
+// left when ctx is done. Done is closed once it returns.
+func (s *Supervisor) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		<-s.done
+		return nil
+	}
+	s.shutdown = true
+	s.mu.Unlock()
+	defer close(s.done)
+
+	stopProcesses(s.list(), ctx.Done())
+	if ctx.Err() != nil {
+		return fmt.Errorf("programs killed after shutdown deadline")
+	}
+	return nil
+}
+
+// Done is closed once Shutdown has stopped every process
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// stopProcesses stops processes from the highest priority value down, those
+// sharing a priority in parallel
+func stopProcesses(processes []*process, force <-chan struct{}) {
+	levels := map[int][]*process{}
+	var priorities []int
+	for _, p := range processes {
+		if _, ok := levels[p.program.Priority]; !ok {
+			priorities = append(priorities, p.program.Priority)
+		}
+		levels[p.program.Priority] = append(levels[p.program.Priority], p)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	for _, priority := range priorities {
+		var wg sync.WaitGroup
+		for _, p := range levels[priority] {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.stop(force)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// match resolves a supervisorctl name to processes
+func (s *Supervisor) match(name string) ([]*process, error) {
+	group, member := splitName(name)
+	var matched []*process
+	for _, p := range s.list() {
+		switch {
+		case member == "*" && p.group == group,
+			member != "" && p.group == group && p.name == member,
+			member == "" && (p.group == group || p.name == group):
+			matched = append(matched, p)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, ErrNoSuchProcess{Name: name}
+	}
+	return matched, nil
+}
+
+// checkRunning refuses control once shutdown has begun
+func (s *Supervisor) checkRunning() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		return fmt.Errorf("supervisor is shutting down")
+	}
+	return nil
+}
+
+// Programs returns the state of every process
+func (s *Supervisor) Programs() ([]entity.ProgramStatus, error) {
+	processes := s.list()
+	statuses := make([]entity.ProgramStatus, 0, len(processes))
+	for _, p := range processes {
+		statuses = append(statuses, p.status(s.logDir))
+	}
+	return statuses, nil
+}
+
+// StartProgram starts the named processes. Every process is tried; those that
+// could not be started, e.g. because they already were, are reported together.
+func (s *Supervisor) StartProgram(name string) error {
+	if err := s.checkRunning(); err != nil {
+		return err
+	}
+	processes, err := s.match(name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range processes {
+		if err := p.start(s.logDir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		fmt.Printf("[SUPERVISOR] Starting %s failed for %d of %d processes\n", name, len(errs), len(processes))
+		return err
+	}
+	fmt.Printf("[SUPERVISOR] Started %s\n", name)
+	return nil
+}
+
+// StopProgram stops the named processes, waiting up to their stopwaitsecs
+func (s *Supervisor) StopProgram(name string) error {
+	if err := s.checkRunning(); err != nil {
+		return err
+	}
+	processes, err := s.match(name)
+	if err != nil {
+		return err
+	}
+	stopProcesses(processes, nil)
+	fmt.Printf("[SUPERVISOR] Stopped %s\n", name)
+	return nil
+}
+
+// RestartProgram stops and starts the named processes
+func (s *Supervisor) RestartProgram(name string) error {
+	if err := s.StopProgram(name); err != nil {
+		return err
+	}
+	return s.StartProgram(name)
+}
+
+// Update applies a new config like `supervisorctl update`: groups that were
+// removed or whose programs changed are stopped, and added or changed groups
+// are started from the new config. Untouched groups keep running.
+func (s *Supervisor) Update(cfg *Config) ([]string, error) {
+	if err := s.checkRunning(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	changed := changedGroups(s.config, cfg)
+	s.mu.Unlock()
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	groups := map[string]bool{}
+	for _, group := range changed {
+		groups[group] = true
+	}
+	added, err := newProcesses(cfg, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []*process
+	for _, p := range s.list() {
+		if groups[p.group] {
+			stale = append(stale, p)
+		}
+	}
+	stopProcesses(stale, nil)
+
+	s.mu.Lock()
+	kept := s.processes[:0:0]
+	for _, p := range s.processes {
+		if !groups[p.group] {
+			kept = append(kept, p)
+		}
+	}
+	s.processes = append(kept, added...)
+	sort.SliceStable(s.processes, func(i, j int) bool {
+		return s.processes[i].program.Priority < s.processes[j].program.Priority
+	})
+	s.config = cfg
+	s.mu.Unlock()
+
+	for _, p := range added {
+		if p.program.AutoStart {
+			if err := p.start(s.logDir); err != nil {
+				return changed, err
+			}
+		}
+	}
+	fmt.Printf("[SUPERVISOR] Updated groups %v\n", changed)
+	return changed, nil
+}
+
+// changedGroups lists the groups added, removed or changed between two configs
+func changedGroups(current, next *Config) []string {
+	programs := func(cfg *Config) map[string][]ProgramConfig {
+		groups := map[string][]ProgramConfig{}
+		for _, program := range cfg.Programs {
+			group := cfg.GroupOf(program.Name)
+			groups[group] = append(groups[group], program)
+		}
+		return groups
+	}
+	before, after := programs(current), programs(next)
+
+	var changed []string
+	for group, list := range after {
+		if !reflect.DeepEqual(before[group], list) {
+			changed = append(changed, group)
+		}
+	}
+	for group := range before {
+		if _, ok := after[group]; !ok {
+			changed = append(changed, group)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// SignalProgram sends sig to the named processes that are running
+func (s *Supervisor) SignalProgram(name string, sig syscall.Signal) error {
+	if err := s.checkRunning(); err != nil {
+		return err
+	}
+	processes, err := s.match(name)
+	if err != nil {
+		return err
+	}
+	for _, p := range processes {
+		p.mu.Lock()
+		cmd := p.cmd
+		p.mu.Unlock()
+		if cmd != nil {
+			p.signal(cmd, sig, false)
+		}
+	}
+	return nil
+}
+
+// TailLog returns up to the last n bytes of a single process's log
+func (s *Supervisor) TailLog(name, stream string, n int64) ([]byte, error) {
+	processes, err := s.match(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(processes) != 1 {
+		return nil, fmt.Errorf("%s matches %d processes, name one", name, len(processes))
+	}
+	status := processes[0].status(s.logDir)
+	path := status.StdoutLogfile
+	switch stream {
+	case "", "stdout":
+	case "stderr":
+		path = status.StderrLogfile
+	default:
+		return nil, fmt.Errorf("unknown log stream %q, want stdout or stderr", stream)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("%s %s is not logged", name, stream)
+	}
+	return tailFile(path, n)
+}
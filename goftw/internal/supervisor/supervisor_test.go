@@ -0,0 +1,210 @@
+package supervisor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"goftw/internal/ini"
+)
+
+// newTestSupervisor builds a supervisor from conf with logs in a temp dir
+func newTestSupervisor(t *testing.T, conf string) *Supervisor {
+	t.Helper()
+	file, err := ini.Parse(strings.NewReader(conf))
+	if err != nil {
+		t.Fatalf("EXPECTED valid ini GOT %v", err)
+	}
+	cfg, err := ParseConfig(file, t.TempDir())
+	if err != nil {
+		t.Fatalf("EXPECTED valid config GOT %v", err)
+	}
+	sup, err := New(cfg, t.TempDir())
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	return sup
+}
+
+// waitForProcess polls until the named process reaches state
+func waitForProcess(t *testing.T, sup *Supervisor, name, state string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		processes, _ := sup.match(name)
+		if processes[0].status(sup.logDir).State == state {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	processes, _ := sup.match(name)
+	t.Fatalf("EXPECTED %s to be %s GOT %+v", name, state, processes[0].status(sup.logDir))
+}
+
+// TestSupervisorLifecycle tests start, logs, restart by group and shutdown
+func TestSupervisorLifecycle(t *testing.T) {
+	sup := newTestSupervisor(t, `
+[program:echo]
+command=sh -c "echo hello; echo oops >&2; exec sleep 30"
+startsecs=0
+numprocs=2
+process_name=%(program_name)s-%(process_num)d
+
+[program:manual]
+command=sleep 30
+autostart=false
+
+[group:web]
+programs=echo
+`)
+	if err := sup.Start(); err != nil {
+		t.Fatalf("EXPECTED start to succeed GOT %v", err)
+	}
+	waitForProcess(t, sup, "web:echo-0", StateRunning)
+	waitForProcess(t, sup, "web:echo-1", StateRunning)
+	waitForProcess(t, sup, "manual", StateStopped)
+
+	// Logs are captured per process and stream
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, _ := sup.TailLog("web:echo-0", "stdout", 1024)
+		errOut, _ := sup.TailLog("web:echo-0", "stderr", 1024)
+		if string(out) == "hello\n" && string(errOut) == "oops\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("EXPECTED stdout hello and stderr oops GOT %q %q", out, errOut)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := sup.TailLog("web:*", "stdout", 1024); err == nil {
+		t.Fatalf("EXPECTED tail of a whole group to fail")
+	}
+
+	programs, _ := sup.Programs()
+	firstPID := programs[0].PID
+	if err := sup.RestartProgram("web:*"); err != nil {
+		t.Fatalf("EXPECTED group restart to succeed GOT %v", err)
+	}
+	waitForProcess(t, sup, "web:echo-0", StateRunning)
+	if programs, _ := sup.Programs(); programs[0].PID == firstPID {
+		t.Fatalf("EXPECTED a new PID after restart GOT %d", firstPID)
+	}
+
+	if err := sup.StartProgram("nope"); err == nil || !strings.Contains(err.Error(), "no such process") {
+		t.Fatalf("EXPECTED no such process GOT %v", err)
+	}
+
+	// A group start tries every process and reports those that did not start
+	if err := sup.StopProgram("web:echo-1"); err != nil {
+		t.Fatalf("EXPECTED stop to succeed GOT %v", err)
+	}
+	err := sup.StartProgram("web:*")
+	if err == nil || !strings.Contains(err.Error(), "web:echo-0 already started") || strings.Contains(err.Error(), "echo-1") {
+		t.Fatalf("EXPECTED only web:echo-0 reported GOT %v", err)
+	}
+	waitForProcess(t, sup, "web:echo-1", StateRunning)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sup.Shutdown(ctx); err != nil {
+		t.Fatalf("EXPECTED clean shutdown GOT %v", err)
+	}
+	programs, _ = sup.Programs()
+	for _, p := range programs {
+		if p.State != StateStopped || p.PID != 0 {
+			t.Fatalf("EXPECTED every process stopped GOT %+v", p)
+		}
+	}
+	if err := sup.StartProgram("manual"); err == nil {
+		t.Fatalf("EXPECTED control to be refused after shutdown")
+	}
+}
+
+// TestSupervisorRestartPolicy tests autorestart and FATAL after startretries
+func TestSupervisorRestartPolicy(t *testing.T) {
+	sup := newTestSupervisor(t, `
+[program:clean]
+command=sh -c "sleep 0.2; exit 0"
+startsecs=0
+
+[program:crashy]
+command=sh -c "exit 2"
+startretries=1
+`)
+	if err := sup.Start(); err != nil {
+		t.Fatalf("EXPECTED start to succeed GOT %v", err)
+	}
+	// exit 0 is expected under autorestart=unexpected
+	waitForProcess(t, sup, "clean", StateExited)
+	// Exits before startsecs back off and then give up
+	waitForProcess(t, sup, "crashy", StateFatal)
+
+	processes, _ := sup.match("crashy")
+	if status := processes[0].status(sup.logDir); !strings.Contains(status.Description, "exited too quickly") {
+		t.Fatalf("EXPECTED exit reason in description GOT %q", status.Description)
+	}
+	sup.Shutdown(context.Background())
+}
+
+// TestSupervisorUpdate tests that only added, removed and changed groups restart
+func TestSupervisorUpdate(t *testing.T) {
+	sup := newTestSupervisor(t, `
+[program:web]
+command=sleep 30
+startsecs=0
+
+[program:worker]
+command=sleep 30
+startsecs=0
+
+[program:old]
+command=sleep 30
+startsecs=0
+`)
+	if err := sup.Start(); err != nil {
+		t.Fatalf("EXPECTED start to succeed GOT %v", err)
+	}
+	defer sup.Shutdown(context.Background())
+	waitForProcess(t, sup, "web", StateRunning)
+	waitForProcess(t, sup, "worker", StateRunning)
+	before, _ := sup.Programs()
+
+	file, _ := ini.Parse(strings.NewReader(`
+[program:web]
+command=sleep 30
+startsecs=0
+
+[program:worker]
+command=sleep 31
+startsecs=0
+numprocs=2
+process_name=%(program_name)s-%(process_num)d
+
+[program:new]
+command=sleep 30
+startsecs=0
+`))
+	cfg, err := ParseConfig(file, t.TempDir())
+	if err != nil {
+		t.Fatalf("EXPECTED valid config GOT %v", err)
+	}
+	updated, err := sup.Update(cfg)
+	if err != nil {
+		t.Fatalf("EXPECTED update to succeed GOT %v", err)
+	}
+	if strings.Join(updated, ",") != "new,old,worker" {
+		t.Fatalf("EXPECTED groups new,old,worker updated GOT %v", updated)
+	}
+	waitForProcess(t, sup, "worker:worker-1", StateRunning)
+	waitForProcess(t, sup, "new", StateRunning)
+	if _, err := sup.match("old"); err == nil {
+		t.Fatalf("EXPECTED removed program to be gone")
+	}
+
+	after, _ := sup.Programs()
+	if after[0].Name != "web" || after[0].PID != before[0].PID {
+		t.Fatalf("EXPECTED unchanged web to keep running GOT %+v (was PID %d)", after[0], before[0].PID)
+	}
+}
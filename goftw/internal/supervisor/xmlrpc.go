@@ -0,0 +1,340 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"goftw/internal/entity"
+)
+
+// supervisord fault codes (supervisor/xmlrpc.py)
+const (
+	faultBadName        = 10
+	faultAlreadyStarted = 60
+	faultNotRunning     = 70
+)
+
+// Fault is an XML-RPC fault returned by supervisord
+type Fault struct {
+	Code   int
+	String string
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("supervisord fault %d: %s", f.Code, f.String)
+}
+
+// RPCClient talks to supervisord's XML-RPC interface. It implements Controller.
+type RPCClient struct {
+	url  string
+	http *http.Client
+}
+
+// NewRPCClient connects to supervisord's [unix_http_server] socket
+func NewRPCClient(socket string) *RPCClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return newRPCClient("http://supervisord/RPC2", &http.Client{Transport: transport, Timeout: 30 * time.Second})
+}
+
+func newRPCClient(url string, client *http.Client) *RPCClient {
+	return &RPCClient{url: url, http: client}
+}
+
+// call invokes method with string, int and bool arguments
+func (c *RPCClient) call(method string, args ...interface{}) (interface{}, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?><methodCall><methodName>`)
+	xml.EscapeText(&body, []byte(method))
+	body.WriteString(`</methodName><params>`)
+	for _, arg := range args {
+		body.WriteString("<param><value>")
+		switch v := arg.(type) {
+		case string:
+			body.WriteString("<string>")
+			xml.EscapeText(&body, []byte(v))
+			body.WriteString("</string>")
+		case int:
+			fmt.Fprintf(&body, "<int>%d</int>", v)
+		case bool:
+			b := 0
+			if v {
+				b = 1
+			}
+			fmt.Fprintf(&body, "<boolean>%d</boolean>", b)
+		default:
+			return nil, fmt.Errorf("unsupported xml-rpc argument %T", arg)
+		}
+		body.WriteString("</value></param>")
+	}
+	body.WriteString(`</params></methodCall>`)
+
+	resp, err := c.http.Post(c.url, "text/xml", &body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: HTTP %d: %s", method, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var response struct {
+		Params []xmlValue `xml:"params>param>value"`
+		Fault  *xmlValue  `xml:"fault>value"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("%s: invalid xml-rpc response: %v", method, err)
+	}
+	if response.Fault != nil {
+		fault, _ := response.Fault.decode().(map[string]interface{})
+		code, _ := fault["faultCode"].(int)
+		text, _ := fault["faultString"].(string)
+		return nil, &Fault{Code: code, String: text}
+	}
+	if len(response.Params) == 0 {
+		return nil, nil
+	}
+	return response.Params[0].decode(), nil
+}
+
+// xmlValue is an XML-RPC <value>
+type xmlValue struct {
+	Int     *string `xml:"int"`
+	I4      *string `xml:"i4"`
+	Boolean *string `xml:"boolean"`
+	String  *string `xml:"string"`
+	Double  *string `xml:"double"`
+	Array   *struct {
+		Values []xmlValue `xml:"data>value"`
+	} `xml:"array"`
+	Struct *struct {
+		Members []struct {
+			Name  string   `xml:"name"`
+			Value xmlValue `xml:"value"`
+		} `xml:"member"`
+	} `xml:"struct"`
+	Text string `xml:",chardata"`
+}
+
+// decode converts the value to int, bool, float64, string, []interface{} or map[string]interface{}
+func (v xmlValue) decode() interface{} {
+	switch {
+	case v.Int != nil:
+		n, _ := strconv.Atoi(strings.TrimSpace(*v.Int))
+		return n
+	case v.I4 != nil:
+		n, _ := strconv.Atoi(strings.TrimSpace(*v.I4))
+		return n
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1"
+	case v.Double != nil:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+		return f
+	case v.String != nil:
+		return *v.String
+	case v.Array != nil:
+		values := make([]interface{}, 0, len(v.Array.Values))
+		for _, item := range v.Array.Values {
+			values = append(values, item.decode())
+		}
+		return values
+	case v.Struct != nil:
+		members := map[string]interface{}{}
+		for _, m := range v.Struct.Members {
+			members[m.Name] = m.Value.decode()
+		}
+		return members
+	}
+	// A bare <value>text</value> is a string
+	return v.Text
+}
+
+// rpcError maps supervisord's BAD_NAME fault to ErrNoSuchProcess
+func rpcError(name string, err error) error {
+	if fault, ok := err.(*Fault); ok && fault.Code == faultBadName {
+		return ErrNoSuchProcess{Name: name}
+	}
+	return err
+}
+
+// Programs returns supervisord's getAllProcessInfo
+func (c *RPCClient) Programs() ([]entity.ProgramStatus, error) {
+	result, err := c.call("supervisor.getAllProcessInfo")
+	if err != nil {
+		return nil, err
+	}
+	infos, _ := result.([]interface{})
+	statuses := make([]entity.ProgramStatus, 0, len(infos))
+	for _, item := range infos {
+		info, _ := item.(map[string]interface{})
+		statuses = append(statuses, processInfoStatus(info))
+	}
+	return statuses, nil
+}
+
+// processInfoStatus converts one getProcessInfo struct
+func processInfoStatus(info map[string]interface{}) entity.ProgramStatus {
+	str := func(key string) string { s, _ := info[key].(string); return s }
+	num := func(key string) int { n, _ := info[key].(int); return n }
+
+	status := entity.ProgramStatus{
+		Name:          str("name"),
+		Group:         str("group"),
+		State:         str("statename"),
+		PID:           num("pid"),
+		ExitStatus:    num("exitstatus"),
+		Description:   str("description"),
+		StdoutLogfile: str("stdout_logfile"),
+		StderrLogfile: str("stderr_logfile"),
+	}
+	if spawnErr := str("spawnerr"); spawnErr != "" {
+		status.Description = spawnErr
+	}
+	if start := num("start"); start > 0 {
+		startedAt := time.Unix(int64(start), 0)
+		status.StartedAt = &startedAt
+		if status.State == StateRunning {
+			status.UptimeSeconds = float64(num("now") - start)
+		}
+	}
+	if stop := num("stop"); stop > 0 && status.State != StateRunning {
+		stoppedAt := time.Unix(int64(stop), 0)
+		status.StoppedAt = &stoppedAt
+	}
+	return status
+}
+
+// groupName returns the group of a `group:*` name
+func groupName(name string) (string, bool) {
+	group, member := splitName(name)
+	return group, member == "*"
+}
+
+// StartProgram starts a process, or every process of `group:*`
+func (c *RPCClient) StartProgram(name string) error {
+	var err error
+	if group, ok := groupName(name); ok {
+		_, err = c.call("supervisor.startProcessGroup", group, true)
+	} else {
+		_, err = c.call("supervisor.startProcess", name, true)
+	}
+	return rpcError(name, err)
+}
+
+// StopProgram stops a process, or every process of `group:*`
+func (c *RPCClient) StopProgram(name string) error {
+	var err error
+	if group, ok := groupName(name); ok {
+		_, err = c.call("supervisor.stopProcessGroup", group, true)
+	} else {
+		_, err = c.call("supervisor.stopProcess", name, true)
+	}
+	return rpcError(name, err)
+}
+
+// RestartProgram stops and starts, like supervisorctl restart
+func (c *RPCClient) RestartProgram(name string) error {
+	err := c.StopProgram(name)
+	if fault, ok := err.(*Fault); err != nil && !(ok && fault.Code == faultNotRunning) {
+		return err
+	}
+	err = c.StartProgram(name)
+	if fault, ok := err.(*Fault); ok && fault.Code == faultAlreadyStarted {
+		return nil
+	}
+	return err
+}
+
+// SignalProgram sends sig to a process, or every process of `group:*`
+func (c *RPCClient) SignalProgram(name string, sig syscall.Signal) error {
+	signal, err := signalName(sig)
+	if err != nil {
+		return err
+	}
+	if group, ok := groupName(name); ok {
+		_, err = c.call("supervisor.signalProcessGroup", group, signal)
+	} else {
+		_, err = c.call("supervisor.signalProcess", name, signal)
+	}
+	return rpcError(name, err)
+}
+
+// Update asks supervisord to reread its config file, then stops and removes
+// removed and changed groups and adds changed and added ones
+func (c *RPCClient) Update(*Config) ([]string, error) {
+	result, err := c.call("supervisor.reloadConfig")
+	if err != nil {
+		return nil, err
+	}
+	// [[added, changed, removed]]
+	outer, _ := result.([]interface{})
+	if len(outer) != 1 {
+		return nil, fmt.Errorf("unexpected reloadConfig result %v", result)
+	}
+	lists, _ := outer[0].([]interface{})
+	if len(lists) != 3 {
+		return nil, fmt.Errorf("unexpected reloadConfig result %v", result)
+	}
+	names := func(v interface{}) []string {
+		var out []string
+		items, _ := v.([]interface{})
+		for _, item := range items {
+			if name, ok := item.(string); ok {
+				out = append(out, name)
+			}
+		}
+		return out
+	}
+	added, changed, removed := names(lists[0]), names(lists[1]), names(lists[2])
+
+	for _, group := range append(append([]string(nil), removed...), changed...) {
+		if _, err := c.call("supervisor.stopProcessGroup", group, true); err != nil {
+			fmt.Printf("[SUPERVISOR] Stopping %s before update: %v\n", group, err)
+		}
+		if _, err := c.call("supervisor.removeProcessGroup", group); err != nil {
+			return nil, fmt.Errorf("failed to remove group %s: %v", group, err)
+		}
+	}
+	for _, group := range append(append([]string(nil), changed...), added...) {
+		if _, err := c.call("supervisor.addProcessGroup", group); err != nil {
+			return nil, fmt.Errorf("failed to add group %s: %v", group, err)
+		}
+	}
+	updated := append(append(added, changed...), removed...)
+	sort.Strings(updated)
+	return updated, nil
+}
+
+// TailLog reads the last n bytes of a process's stdout or stderr log
+func (c *RPCClient) TailLog(name, stream string, n int64) ([]byte, error) {
+	method := "supervisor.readProcessStdoutLog"
+	switch stream {
+	case "", "stdout":
+	case "stderr":
+		method = "supervisor.readProcessStderrLog"
+	default:
+		return nil, fmt.Errorf("unknown log stream %q, want stdout or stderr", stream)
+	}
+	// A negative offset with length 0 reads from the end of the log
+	result, err := c.call(method, name, -int(n), 0)
+	if err != nil {
+		return nil, rpcError(name, err)
+	}
+	data, _ := result.(string)
+	return []byte(data), nil
+}
@@ -0,0 +1,148 @@
+package supervisor
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSupervisord answers XML-RPC calls with canned responses by method name
+func fakeSupervisord(t *testing.T, calls *[]string, responses map[string]string) *RPCClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*calls = append(*calls, string(body))
+		for method, response := range responses {
+			if strings.Contains(string(body), "<methodName>"+method+"</methodName>") {
+				w.Header().Set("Content-Type", "text/xml")
+				io.WriteString(w, `<?xml version="1.0"?><methodResponse>`+response+`</methodResponse>`)
+				return
+			}
+		}
+		t.Errorf("unexpected call %s", body)
+	}))
+	t.Cleanup(server.Close)
+	return newRPCClient(server.URL+"/RPC2", server.Client())
+}
+
+// faultResponse is a supervisord fault body
+func faultResponse(code int, text string) string {
+	return `<fault><value><struct>
+<member><name>faultCode</name><value><int>` + strconv.Itoa(code) + `</int></value></member>
+<member><name>faultString</name><value><string>` + text + `</string></value></member>
+</struct></value></fault>`
+}
+
+// TestRPCClientPrograms tests decoding getAllProcessInfo
+func TestRPCClientPrograms(t *testing.T) {
+	var calls []string
+	client := fakeSupervisord(t, &calls, map[string]string{
+		"supervisor.getAllProcessInfo": `<params><param><value><array><data>
+<value><struct>
+<member><name>name</name><value><string>frappe-bench-frappe-web</string></value></member>
+<member><name>group</name><value><string>frappe-bench-web</string></value></member>
+<member><name>statename</name><value><string>RUNNING</string></value></member>
+<member><name>pid</name><value><int>42</int></value></member>
+<member><name>start</name><value><int>1000</int></value></member>
+<member><name>now</name><value><int>1060</int></value></member>
+<member><name>stop</name><value><int>0</int></value></member>
+<member><name>exitstatus</name><value><int>0</int></value></member>
+<member><name>spawnerr</name><value><string></string></value></member>
+<member><name>description</name><value>pid 42, uptime 0:01:00</value></member>
+<member><name>stdout_logfile</name><value><string>/home/frappe/frappe-bench/logs/web.log</string></value></member>
+</struct></value>
+</data></array></value></param></params>`,
+	})
+
+	programs, err := client.Programs()
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if len(programs) != 1 {
+		t.Fatalf("EXPECTED 1 program GOT %d", len(programs))
+	}
+	web := programs[0]
+	if web.Name != "frappe-bench-frappe-web" || web.Group != "frappe-bench-web" || web.State != StateRunning ||
+		web.PID != 42 || web.UptimeSeconds != 60 || web.Description != "pid 42, uptime 0:01:00" || web.StartedAt == nil {
+		t.Fatalf("EXPECTED decoded process info GOT %+v", web)
+	}
+}
+
+// TestRPCClientControl tests group calls, fault mapping and restart of a stopped process
+func TestRPCClientControl(t *testing.T) {
+	var calls []string
+	client := fakeSupervisord(t, &calls, map[string]string{
+		"supervisor.stopProcessGroup":     `<params><param><value><array><data></data></array></value></param></params>`,
+		"supervisor.startProcessGroup":    `<params><param><value><array><data></data></array></value></param></params>`,
+		"supervisor.stopProcess":          faultResponse(faultNotRunning, "NOT_RUNNING: nginx"),
+		"supervisor.startProcess":         `<params><param><value><boolean>1</boolean></value></param></params>`,
+		"supervisor.readProcessStderrLog": faultResponse(faultBadName, "BAD_NAME: nope"),
+	})
+
+	if err := client.RestartProgram("frappe-bench-workers:*"); err != nil {
+		t.Fatalf("EXPECTED group restart to succeed GOT %v", err)
+	}
+	if !strings.Contains(calls[0], "<string>frappe-bench-workers</string>") {
+		t.Fatalf("EXPECTED group name without :* GOT %s", calls[0])
+	}
+	if err := client.RestartProgram("nginx"); err != nil {
+		t.Fatalf("EXPECTED restart of a stopped process to start it GOT %v", err)
+	}
+
+	var fault *Fault
+	if err := client.StopProgram("nginx"); !errors.As(err, &fault) || fault.Code != faultNotRunning {
+		t.Fatalf("EXPECTED NOT_RUNNING fault GOT %v", err)
+	}
+	_, err := client.TailLog("nope", "stderr", 100)
+	var missing ErrNoSuchProcess
+	if !errors.As(err, &missing) {
+		t.Fatalf("EXPECTED ErrNoSuchProcess GOT %v", err)
+	}
+	if !strings.Contains(calls[len(calls)-1], "<int>-100</int><") {
+		t.Fatalf("EXPECTED negative offset to read the tail GOT %s", calls[len(calls)-1])
+	}
+}
+
+// TestRPCClientUpdate tests the reloadConfig, remove and add sequence of an update
+func TestRPCClientUpdate(t *testing.T) {
+	var calls []string
+	ok := `<params><param><value><boolean>1</boolean></value></param></params>`
+	client := fakeSupervisord(t, &calls, map[string]string{
+		"supervisor.reloadConfig": `<params><param><value><array><data><value><array><data>
+<value><array><data><value><string>added</string></value></data></array></value>
+<value><array><data><value><string>changed</string></value></data></array></value>
+<value><array><data><value><string>removed</string></value></data></array></value>
+</data></array></value></data></array></value></param></params>`,
+		"supervisor.stopProcessGroup":   `<params><param><value><array><data></data></array></value></param></params>`,
+		"supervisor.removeProcessGroup": ok,
+		"supervisor.addProcessGroup":    ok,
+	})
+
+	updated, err := client.Update(nil)
+	if err != nil {
+		t.Fatalf("EXPECTED no error GOT %v", err)
+	}
+	if !reflect.DeepEqual(updated, []string{"added", "changed", "removed"}) {
+		t.Fatalf("EXPECTED added, changed and removed GOT %v", updated)
+	}
+
+	var sequence []string
+	for _, call := range calls[1:] {
+		method := call[strings.Index(call, "<methodName>")+len("<methodName>supervisor.") : strings.Index(call, "</methodName>")]
+		group := call[strings.Index(call, "<string>")+len("<string>") : strings.Index(call, "</string>")]
+		sequence = append(sequence, method+" "+group)
+	}
+	expected := []string{
+		"stopProcessGroup removed", "removeProcessGroup removed",
+		"stopProcessGroup changed", "removeProcessGroup changed",
+		"addProcessGroup changed", "addProcessGroup added",
+	}
+	if !reflect.DeepEqual(sequence, expected) {
+		t.Fatalf("EXPECTED %v GOT %v", expected, sequence)
+	}
+}
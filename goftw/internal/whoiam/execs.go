@@ -1,8 +1,10 @@
 package whoiam
 
 import (
+	"context"
 	"os"
 	"os/exec"
+	"time"
 )
 
 // ExecRunSwallowIO runs a command with sudo privileges, returning the output
@@ -13,6 +15,16 @@ func ExecRunSwallowIO(args ...string) ([]byte, error) {
 	return out, err
 }
 
+// ExecRunSwallowIOContext is ExecRunSwallowIO killing the command once ctx is done
+func ExecRunSwallowIOContext(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	// Do not wait for children that inherited the output pipe
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	return out, err
+}
+
 // ExecRunPrintIO runs a command with sudo privileges and prints its output and error.
 func ExecRunPrintIO(args ...string) error {
 	cmd := exec.Command(args[0], args[1:]...)
@@ -0,0 +1,30 @@
+#!/bin/sh
+# Runs nginx and reloads it whenever goftw rewrites the dynamic proxy config.
+# nginx keeps serving the previous config when the new one fails `nginx -t`.
+# conf.d is polled every PROXY_RELOAD_INTERVAL seconds, so a change is picked
+# up to one interval late, and a failed reload is only logged here: goftw
+# writes the config but does not see the result.
+CONF_DIR="${PROXY_CONF_DIR:-/etc/nginx/conf.d}"
+INTERVAL="${PROXY_RELOAD_INTERVAL:-2}"
+
+checksum() {
+    cat "$CONF_DIR"/*.conf 2>/dev/null | md5sum
+}
+
+watch_conf() {
+    LAST=$(checksum)
+    while sleep "$INTERVAL"; do
+        CURRENT=$(checksum)
+        [ "$CURRENT" = "$LAST" ] && continue
+        LAST="$CURRENT"
+
+        if nginx -t; then
+            nginx -s reload && echo "[INFO] Reloaded nginx for the new proxy config."
+        else
+            echo "[ERROR] Nginx config test failed; keeping the previous config."
+        fi
+    done
+}
+
+watch_conf &
+exec nginx -g 'daemon off;'